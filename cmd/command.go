@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"github.com/couchbaselabs/cbmigrate/cmd/common"
+	"github.com/couchbaselabs/cbmigrate/cmd/couchbase"
 	"github.com/couchbaselabs/cbmigrate/cmd/dynamodb"
 	"github.com/couchbaselabs/cbmigrate/cmd/huggingface"
 	"github.com/couchbaselabs/cbmigrate/cmd/mongo"
@@ -39,6 +40,7 @@ func Execute() {
 	cmd.AddCommand(mongo.GetMongoMigrateCommand())
 	cmd.AddCommand(dynamodb.GetDynamoDBMigrateCommand())
 	cmd.AddCommand(huggingface.GetHuggingFaceMigrateCommand())
+	cmd.AddCommand(couchbase.GetCouchbaseMigrateCommand())
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		if cmd.Flags().Changed(Version) {
 			fmt.Println("Version: " + common.Version)
@@ -15,6 +15,7 @@ const (
 	DynamoDB    = "dynamodb"
 	CBMigrate   = "cbmigrate"
 	HuggingFace = "hugging-face"
+	Couchbase   = "couchbase"
 )
 
 var BetaCommands = []Command{
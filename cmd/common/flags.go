@@ -1,6 +1,9 @@
 package common
 
-import "github.com/couchbaselabs/cbmigrate/cmd/flag"
+import (
+	"github.com/couchbaselabs/cbmigrate/cmd/flag"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+)
 
 const (
 	CBCluster            = "cb-cluster"
@@ -17,8 +20,15 @@ const (
 	CBScope              = "cb-scope"
 	CBCollection         = "cb-collection"
 	CBBatchSize          = "cb-batch-size"
+	CBBinaryThreshold    = "cb-binary-threshold"
+	CBTransactional      = "cb-transactional"
+	CBTransactionLimit   = "cb-transaction-limit"
+
+	// SourceFlagPrefix is prepended to the couchbase connection flags when couchbase is the source of the migration.
+	SourceFlagPrefix = "src-"
 
 	CopyIndexes     = "copy-indexes"
+	IndexDDLFile    = "index-ddl-file"
 	BufferSize      = "buffer-size"
 	KeepPrimaryKey  = "keep-primary-key"
 	HashDocumentKey = "hash-document-key"
@@ -121,12 +131,33 @@ var batchSize = &flag.IntFlag{
 	Value: 200,
 }
 
+var cbTransactional = &flag.EnumFlag{
+	Name: CBTransactional,
+	Usage: "Writes the documents inside couchbase distributed transactions, meant for small critical collections." +
+		" With batch each batch is written in its own transaction, with run the whole migration is written in a single" +
+		" transaction which leaves the target collection untouched if any error occurs.",
+	Values: []string{option.TransactionalBatch, option.TransactionalRun},
+}
+
+var cbTransactionLimit = &flag.IntFlag{
+	Name:  CBTransactionLimit,
+	Usage: "The maximum number of documents migrated with --cb-transactional run. The migration fails without writing any document when the limit is exceeded.",
+	Value: 1000,
+}
+
 var copyIndexes = &flag.BoolFlag{
 	Name:  CopyIndexes,
 	Usage: "Copy indexes for the collection",
 	Value: true,
 }
 
+var indexDDLFile = &flag.StringFlag{
+	Name: IndexDDLFile,
+	Usage: "Append the index definitions to this file instead of creating the indexes, requires --copy-indexes: the GSI " +
+		"statements are terminated by a semicolon, and the search index definitions are appended as JSON documents, one " +
+		"per line, to the same file name suffixed with .search.json.",
+}
+
 var bufferSize = &flag.IntFlag{
 	Name:  BufferSize,
 	Usage: "Buffer size",
@@ -165,6 +196,8 @@ func GetCBFlags() []flag.Flag {
 		cbScope,
 		cbCollection,
 		batchSize,
+		cbTransactional,
+		cbTransactionLimit,
 		keepPrimaryKey,
 		hashDocumentKey,
 		GetDebugFlag(),
@@ -172,9 +205,20 @@ func GetCBFlags() []flag.Flag {
 	return flags
 }
 
+// GetCBBinaryThresholdFlag is only added to the commands whose source can produce binary fields.
+func GetCBBinaryThresholdFlag() flag.Flag {
+	return &flag.IntFlag{
+		Name: CBBinaryThreshold,
+		Usage: "Binary fields with a size (in bytes) greater than or equal to this value are stored as separate couchbase binary documents" +
+			" keyed by \"<document key>::<field path>\", and the field is replaced by a {key, size, subtype} reference object." +
+			" By default binary fields are kept in the document.",
+	}
+}
+
 func GetCommonFlags() []flag.Flag {
 	return []flag.Flag{
 		copyIndexes,
+		indexDDLFile,
 		bufferSize,
 	}
 }
@@ -127,59 +127,88 @@ func ParesCouchbaseOptions(cmd *cobra.Command, collection string) (*option.Optio
 	if debug {
 		logger.EnableDebugLevel()
 	}
+	cbopts, err := ParseCouchbaseConnectionOptions(cmd, "")
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.Flags().Changed(CBCollection) {
+		cbopts.NameSpace.Collection = collection
+	}
+
+	cbopts.GeneratedKey, _ = cmd.Flags().GetString(CBGenerateKey)
+	cbopts.KeepPrimaryKey, _ = cmd.Flags().GetBool(KeepPrimaryKey)
+	cbopts.HashDocumentKey, _ = cmd.Flags().GetString(HashDocumentKey)
+	if cmd.Flags().Changed(HashDocumentKey) {
+		if err = ValueMustBeOneOf(cbopts.HashDocumentKey, hashDocumentKey.Values); err != nil {
+			return nil, err
+		}
+	}
+	cbopts.BatchSize, _ = cmd.Flags().GetInt(CBBatchSize)
+	cbopts.BinaryThreshold, _ = cmd.Flags().GetInt(CBBinaryThreshold)
+	if cbopts.BinaryThreshold < 0 {
+		return nil, fmt.Errorf("value of --%s must not be negative", CBBinaryThreshold)
+	}
+	cbopts.Transactional, _ = cmd.Flags().GetString(CBTransactional)
+	if cmd.Flags().Changed(CBTransactional) {
+		if err = ValueMustBeOneOf(cbopts.Transactional, cbTransactional.Values); err != nil {
+			return nil, err
+		}
+		if cbopts.BinaryThreshold > 0 {
+			return nil, fmt.Errorf("error: \"--%s\" cannot be used with \"--%s\"", CBTransactional, CBBinaryThreshold)
+		}
+	}
+	if cbopts.Transactional == option.TransactionalRun {
+		cbopts.TransactionLimit, _ = cmd.Flags().GetInt(CBTransactionLimit)
+	}
+	cbopts.IndexDDLFile, _ = cmd.Flags().GetString(IndexDDLFile)
+	if copyIndexes, _ := cmd.Flags().GetBool(CopyIndexes); cbopts.IndexDDLFile != "" && !copyIndexes {
+		return nil, fmt.Errorf("error: \"--%s\" requires \"--%s\"", IndexDDLFile, CopyIndexes)
+	}
+	return cbopts, nil
+}
+
+// ParseCouchbaseConnectionOptions parses the cluster, credentials, ssl and keyspace flags. The prefix is prepended to
+// the flag names, so the same parser serves the destination flags ("") and the source flags (SourceFlagPrefix).
+func ParseCouchbaseConnectionOptions(cmd *cobra.Command, prefix string) (*option.Options, error) {
 	var err error
 	cbopts := &option.Options{
 		Auth:      &option.Auth{},
 		SSL:       &option.SSL{},
 		NameSpace: &option.NameSpace{},
 	}
-	cbopts.Cluster, _ = cmd.Flags().GetString(CBCluster)
-	cbopts.Auth.Username, _ = cmd.Flags().GetString(CBUsername)
-	cbopts.Auth.Password, _ = cmd.Flags().GetString(CBPassword)
-	cbClientCert, _ := cmd.Flags().GetString(CBClientCert)
+	cbopts.Cluster, _ = cmd.Flags().GetString(prefix + CBCluster)
+	cbopts.Auth.Username, _ = cmd.Flags().GetString(prefix + CBUsername)
+	cbopts.Auth.Password, _ = cmd.Flags().GetString(prefix + CBPassword)
+	cbClientCert, _ := cmd.Flags().GetString(prefix + CBClientCert)
 	if cbClientCert != "" {
 		cbopts.Auth.ClientCert, err = os.ReadFile(cbClientCert)
 		if err != nil {
 			return nil, err
 		}
 	}
-	cbopts.Auth.ClientCertPassword, _ = cmd.Flags().GetString(CBClientCertPassword)
+	cbopts.Auth.ClientCertPassword, _ = cmd.Flags().GetString(prefix + CBClientCertPassword)
 
-	cbClientKey, _ := cmd.Flags().GetString(CBClientKey)
+	cbClientKey, _ := cmd.Flags().GetString(prefix + CBClientKey)
 	if cbClientKey != "" {
 		cbopts.Auth.ClientKey, err = os.ReadFile(cbClientKey)
 		if err != nil {
 			return nil, err
 		}
 	}
-	cbopts.Auth.ClientKeyPassword, _ = cmd.Flags().GetString(CBClientKeyPassword)
+	cbopts.Auth.ClientKeyPassword, _ = cmd.Flags().GetString(prefix + CBClientKeyPassword)
 
-	cbCACert, _ := cmd.Flags().GetString(CBCACert)
+	cbCACert, _ := cmd.Flags().GetString(prefix + CBCACert)
 	if cbCACert != "" {
 		cbopts.SSL.CaCert, err = os.ReadFile(cbCACert)
 		if err != nil {
 			return nil, err
 		}
 	}
-	cbopts.SSL.NoSSLVerify, _ = cmd.Flags().GetBool(CBNoSSLVerify)
+	cbopts.SSL.NoSSLVerify, _ = cmd.Flags().GetBool(prefix + CBNoSSLVerify)
 
-	cbopts.NameSpace.Bucket, _ = cmd.Flags().GetString(CBBucket)
-	cbopts.NameSpace.Scope, _ = cmd.Flags().GetString(CBScope)
-	if cmd.Flags().Changed(CBCollection) {
-		cbopts.NameSpace.Collection, _ = cmd.Flags().GetString(CBCollection)
-	} else {
-		cbopts.NameSpace.Collection = collection
-	}
-
-	cbopts.GeneratedKey, _ = cmd.Flags().GetString(CBGenerateKey)
-	cbopts.KeepPrimaryKey, _ = cmd.Flags().GetBool(KeepPrimaryKey)
-	cbopts.HashDocumentKey, _ = cmd.Flags().GetString(HashDocumentKey)
-	if cmd.Flags().Changed(HashDocumentKey) {
-		if err = ValueMustBeOneOf(cbopts.HashDocumentKey, hashDocumentKey.Values); err != nil {
-			return nil, err
-		}
-	}
-	cbopts.BatchSize, _ = cmd.Flags().GetInt(CBBatchSize)
+	cbopts.NameSpace.Bucket, _ = cmd.Flags().GetString(prefix + CBBucket)
+	cbopts.NameSpace.Scope, _ = cmd.Flags().GetString(prefix + CBScope)
+	cbopts.NameSpace.Collection, _ = cmd.Flags().GetString(prefix + CBCollection)
 	return cbopts, nil
 }
 
@@ -0,0 +1,127 @@
+package couchbase
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/couchbaselabs/cbmigrate/cmd/common"
+	"github.com/couchbaselabs/cbmigrate/cmd/couchbase/command"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase"
+	cRepo "github.com/couchbaselabs/cbmigrate/internal/couchbase/repo"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source"
+	sOpts "github.com/couchbaselabs/cbmigrate/internal/couchbase/source/option"
+	sRepo "github.com/couchbaselabs/cbmigrate/internal/couchbase/source/repo"
+	"github.com/couchbaselabs/cbmigrate/internal/migrater"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type Action struct {
+	Migrate migrater.IMigrate[sOpts.Options]
+}
+
+func NewAction() *Action {
+	return &Action{
+		Migrate: migrater.NewMigrator(
+			source.NewCouchbase(sRepo.NewRepo()),
+			couchbase.NewCouchbase(cRepo.NewRepo()),
+		),
+	}
+}
+
+func (a *Action) RunE(cmd *cobra.Command, args []string) error {
+
+	var missingRequiredOptions []string
+	switch {
+	case !cmd.Flags().Changed(command.SrcCBCluster):
+		missingRequiredOptions = append(missingRequiredOptions, command.SrcCBCluster)
+		fallthrough
+	case !cmd.Flags().Changed(command.SrcCBBucket):
+		missingRequiredOptions = append(missingRequiredOptions, command.SrcCBBucket)
+	}
+	missingRequiredOptions = append(missingRequiredOptions, common.CouchBaseMissingRequiredOptions(cmd)...)
+	if len(missingRequiredOptions) > 0 {
+		err := common.ReqFieldsError(missingRequiredOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	srcCBOpts, err := common.ParseCouchbaseConnectionOptions(cmd, common.SourceFlagPrefix)
+	if err != nil {
+		return err
+	}
+	sopts := &sOpts.Options{
+		Options: srcCBOpts,
+	}
+	sopts.ScanMode, _ = cmd.Flags().GetString(command.SrcCBScanMode)
+	if err = common.ValueMustBeOneOf(sopts.ScanMode, []string{sOpts.ScanModeRangeScan, sOpts.ScanModeQuery}); err != nil {
+		return err
+	}
+
+	cbOpts, err := common.ParesCouchbaseOptions(cmd, sopts.Collection)
+	if err != nil {
+		return err
+	}
+	// the range scan would read the documents it is rewriting, and the generator key would keep re-keying them
+	if sameCluster(sopts.Cluster, cbOpts.Cluster) && *sopts.NameSpace == *cbOpts.NameSpace {
+		return fmt.Errorf("the source and target keyspaces are the same %s.%s.%s, a collection cannot be copied onto "+
+			"itself", cbOpts.Bucket, cbOpts.Scope, cbOpts.Collection)
+	}
+	// the keys referencing the source key are generated by the source, so the documents are not polluted with the
+	// source key
+	sopts.IncludeSourceKey = cbOpts.GeneratedKey == "" ||
+		strings.Contains(cbOpts.GeneratedKey, "%"+source.SourceKeyField+"%")
+	if sopts.IncludeSourceKey {
+		sopts.KeyHash = cbOpts.HashDocumentKey
+	}
+
+	copyIndexes, _ := cmd.Flags().GetBool(common.CopyIndexes)
+	sopts.CopyIndexes = copyIndexes
+	bufferSize, _ := cmd.Flags().GetInt(common.BufferSize)
+	err = a.Migrate.Copy(sopts, cbOpts, copyIndexes, bufferSize)
+	if err != nil {
+		zap.S().Fatal(err)
+	}
+	return nil
+}
+
+// sameCluster reports whether the two connection strings share a host, regardless of their scheme, ports and
+// parameters.
+func sameCluster(a, b string) bool {
+	hosts := clusterHosts(a)
+	for host := range clusterHosts(b) {
+		if _, ok := hosts[host]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func clusterHosts(connStr string) map[string]struct{} {
+	if i := strings.Index(connStr, "://"); i >= 0 {
+		connStr = connStr[i+3:]
+	}
+	if i := strings.IndexAny(connStr, "/?"); i >= 0 {
+		connStr = connStr[:i]
+	}
+	hosts := make(map[string]struct{})
+	for _, host := range strings.Split(connStr, ",") {
+		host = strings.TrimSpace(host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host != "" {
+			hosts[strings.ToLower(host)] = struct{}{}
+		}
+	}
+	return hosts
+}
+
+func GetCouchbaseMigrateCommand() *cobra.Command {
+	cmd := command.NewCommand()
+	action := NewAction()
+	cmd.RunE = action.RunE
+	return cmd
+}
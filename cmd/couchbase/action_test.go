@@ -0,0 +1,180 @@
+package couchbase_test
+
+import (
+	"github.com/couchbaselabs/cbmigrate/cmd/common"
+	"github.com/couchbaselabs/cbmigrate/cmd/couchbase"
+	"github.com/couchbaselabs/cbmigrate/cmd/couchbase/command"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	sOpts "github.com/couchbaselabs/cbmigrate/internal/couchbase/source/option"
+	mocktest "github.com/couchbaselabs/cbmigrate/testhelper/mock"
+	"github.com/spf13/cobra"
+	"go.uber.org/mock/gomock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("couchbase", func() {
+
+	Describe("couchbase command", func() {
+		srcCBClusterOption := "--" + command.SrcCBCluster
+		srcCBUserOption := "--" + command.SrcCBUsername
+		srcCBPasswordOption := "--" + command.SrcCBPassword
+		srcCBBucketOption := "--" + command.SrcCBBucket
+		srcCBCollectionOption := "--" + command.SrcCBCollection
+		srcCBScanModeOption := "--" + command.SrcCBScanMode
+
+		cbClusterOption := "--" + common.CBCluster
+		cbUserOption := "--" + common.CBUsername
+		cbPasswordOption := "--" + common.CBPassword
+		cbBucketOption := "--" + common.CBBucket
+		cbScopeOption := "--" + common.CBScope
+		cbGeneratorKeyOption := "--" + common.CBGenerateKey
+
+		srcCBCluster := "source-host"
+		srcCBUser := "source-admin"
+		srcCBPassword := "source-password"
+		srcCBBucket := "source-bucket"
+		srcCBCollection := "source-collection"
+
+		cbCluster := "localhost"
+		cbUser := "admin"
+		cbPassword := "password"
+		cbBucket := "cb-bucket"
+		cbScope := "scope"
+
+		var (
+			ctrl    *gomock.Controller
+			migrate *mocktest.MockIMigrate[sOpts.Options]
+			cmd     *cobra.Command
+			action  *couchbase.Action
+		)
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			migrate = mocktest.NewMockIMigrate[sOpts.Options](ctrl)
+			action = &couchbase.Action{Migrate: migrate}
+			cmd = command.NewCommand()
+			cmd.RunE = action.RunE
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		Context("success", func() {
+			It("Input assertion case1", func() {
+
+				var sOptsGot *sOpts.Options
+				var cbOptsGot *option.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(sOpts *sOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					sOptsGot = sOpts
+					cbOptsGot = cbOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, srcCBCluster, srcCBUserOption, srcCBUser,
+					srcCBPasswordOption, srcCBPassword, srcCBBucketOption, srcCBBucket,
+					srcCBCollectionOption, srcCBCollection,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				expectedSopts := &sOpts.Options{
+					Options: &option.Options{
+						Cluster: srcCBCluster,
+						Auth: &option.Auth{
+							Username: srcCBUser,
+							Password: srcCBPassword,
+						},
+						SSL: &option.SSL{},
+						NameSpace: &option.NameSpace{
+							Bucket:     srcCBBucket,
+							Scope:      "_default",
+							Collection: srcCBCollection,
+						},
+					},
+					ScanMode:         sOpts.ScanModeRangeScan,
+					IncludeSourceKey: true,
+					CopyIndexes:      true,
+				}
+				expectedCbOpts := &option.Options{
+					Cluster: cbCluster,
+					Auth: &option.Auth{
+						Username: cbUser,
+						Password: cbPassword,
+					},
+					NameSpace: &option.NameSpace{
+						Bucket:     cbBucket,
+						Scope:      cbScope,
+						Collection: srcCBCollection,
+					},
+					SSL:       &option.SSL{},
+					BatchSize: 200,
+				}
+
+				Expect(sOptsGot).To(Equal(expectedSopts))
+				Expect(cbOptsGot).To(Equal(expectedCbOpts))
+			})
+
+			It("keys referencing the source key are hashed by the source", func() {
+
+				var sOptsGot *sOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(sOpts *sOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					sOptsGot = sOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, srcCBCluster, srcCBUserOption, srcCBUser,
+					srcCBPasswordOption, srcCBPassword, srcCBBucketOption, srcCBBucket,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, cbGeneratorKeyOption, "%meta().id%::x",
+					"--"+common.HashDocumentKey, "sha256")
+				Expect(err).To(BeNil())
+				Expect(sOptsGot.IncludeSourceKey).To(BeTrue())
+				Expect(sOptsGot.KeyHash).To(Equal("sha256"))
+			})
+
+			It("source key is not included when the generator key does not reference it", func() {
+
+				var sOptsGot *sOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(sOpts *sOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					sOptsGot = sOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, srcCBCluster, srcCBUserOption, srcCBUser,
+					srcCBPasswordOption, srcCBPassword, srcCBBucketOption, srcCBBucket,
+					srcCBScanModeOption, sOpts.ScanModeQuery,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, cbGeneratorKeyOption, "key::%type%::%id%")
+				Expect(err).To(BeNil())
+				Expect(sOptsGot.ScanMode).To(Equal(sOpts.ScanModeQuery))
+				Expect(sOptsGot.IncludeSourceKey).To(BeFalse())
+			})
+		})
+
+		Context("failure", func() {
+			It("invalid scan mode", func() {
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, srcCBCluster, srcCBUserOption, srcCBUser,
+					srcCBPasswordOption, srcCBPassword, srcCBBucketOption, srcCBBucket,
+					srcCBScanModeOption, "full",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("index ddl file without copying the indexes", func() {
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, srcCBCluster, srcCBUserOption, srcCBUser,
+					srcCBPasswordOption, srcCBPassword, srcCBBucketOption, srcCBBucket,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope,
+					"--"+common.IndexDDLFile, "indexes.ddl", "--"+common.CopyIndexes+"=false")
+				Expect(err).To(MatchError(ContainSubstring(common.IndexDDLFile)))
+			})
+			It("the source and target keyspaces are the same", func() {
+				_, err := common.ExecuteCommand(cmd, srcCBClusterOption, "couchbase://"+cbCluster, srcCBUserOption,
+					srcCBUser, srcCBPasswordOption, srcCBPassword, srcCBBucketOption, cbBucket,
+					"--"+command.SrcCBScope, cbScope, srcCBCollectionOption, srcCBCollection,
+					cbClusterOption, cbCluster+":8091", cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, cbGeneratorKeyOption, "key::%meta().id%")
+				Expect(err).To(MatchError(ContainSubstring("the source and target keyspaces are the same")))
+			})
+		})
+	})
+})
@@ -0,0 +1,162 @@
+package command
+
+import (
+	"github.com/couchbaselabs/cbmigrate/cmd/common"
+	"github.com/couchbaselabs/cbmigrate/cmd/flag"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source/option"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SrcCBCluster            = common.SourceFlagPrefix + common.CBCluster
+	SrcCBUsername           = common.SourceFlagPrefix + common.CBUsername
+	SrcCBPassword           = common.SourceFlagPrefix + common.CBPassword
+	SrcCBClientCert         = common.SourceFlagPrefix + common.CBClientCert
+	SrcCBClientCertPassword = common.SourceFlagPrefix + common.CBClientCertPassword
+	SrcCBClientKey          = common.SourceFlagPrefix + common.CBClientKey
+	SrcCBClientKeyPassword  = common.SourceFlagPrefix + common.CBClientKeyPassword
+	SrcCBCACert             = common.SourceFlagPrefix + common.CBCACert
+	SrcCBNoSSLVerify        = common.SourceFlagPrefix + common.CBNoSSLVerify
+	SrcCBBucket             = common.SourceFlagPrefix + common.CBBucket
+	SrcCBScope              = common.SourceFlagPrefix + common.CBScope
+	SrcCBCollection         = common.SourceFlagPrefix + common.CBCollection
+	SrcCBScanMode           = common.SourceFlagPrefix + "cb-scan-mode"
+)
+
+var srcCBCluster = &flag.StringFlag{
+	Name:     SrcCBCluster,
+	Usage:    "The hostname of a node in the cluster to export data from.",
+	Required: true,
+}
+
+var srcCBUsername = &flag.StringFlag{
+	Name:     SrcCBUsername,
+	Usage:    "The username for source cluster authentication.",
+	Required: true,
+}
+
+var srcCBPassword = &flag.StringFlag{
+	Name:     SrcCBPassword,
+	Usage:    "The password for source cluster authentication.",
+	Required: true,
+}
+
+var srcCBClientCert = &flag.StringFlag{
+	Name: SrcCBClientCert,
+	Usage: "The path to a client certificate used to authenticate when connecting to the source cluster. " +
+		"May be supplied with --src-cb-client-key as an alternative to the --src-cb-username and --src-cb-password flags.",
+	Required: true,
+}
+
+var srcCBClientCertPassword = &flag.StringFlag{
+	Name:  SrcCBClientCertPassword,
+	Usage: "The password for the certificate provided to the --src-cb-client-cert flag, when using this flag, the certificate/key pair is expected to be in the PKCS#12 format.",
+}
+
+var srcCBClientKey = &flag.StringFlag{
+	Name: SrcCBClientKey,
+	Usage: "The path to the client private key whose public key is contained in the certificate provided to the --src-cb-client-cert flag." +
+		" May be supplied with --src-cb-client-cert as an alternative to the --src-cb-username and --src-cb-password flags.",
+}
+
+var srcCBClientKeyPassword = &flag.StringFlag{
+	Name:  SrcCBClientKeyPassword,
+	Usage: "The password for the key provided to the --src-cb-client-key flag, when using this flag, the key is expected to be in the PKCS#8 format.",
+}
+
+var srcCBCACert = &flag.StringFlag{
+	Name: SrcCBCACert,
+	Usage: "Specifies a CA certificate that will be used to verify the identity of the source cluster. " +
+		"Either this flag or the --src-cb-no-ssl-verify flag must be specified when using an SSL encrypted connection.",
+}
+
+var srcCBNoSSLVerify = &flag.BoolFlag{
+	Name: SrcCBNoSSLVerify,
+	Usage: "Skips the SSL verification phase for the source cluster. " +
+		"You are vulnerable to a man-in-the-middle attack if you use this flag.",
+}
+
+var srcCBBucket = &flag.StringFlag{
+	Name:     SrcCBBucket,
+	Usage:    "The name of the source couchbase bucket.",
+	Required: true,
+}
+
+var srcCBScope = &flag.StringFlag{
+	Name:  SrcCBScope,
+	Usage: "The name of the scope of the source collection.",
+	Value: "_default",
+}
+
+var srcCBCollection = &flag.StringFlag{
+	Name:  SrcCBCollection,
+	Usage: "The name of the source collection.",
+	Value: "_default",
+}
+
+var srcCBScanMode = &flag.EnumFlag{
+	Name: SrcCBScanMode,
+	Usage: "How the source collection is read. range-scan streams the documents with a KV range scan and does not need " +
+		"any index, query streams them with a N1QL cursor and needs a primary index on the source collection.",
+	Values:       []string{option.ScanModeRangeScan, option.ScanModeQuery},
+	DefaultValue: option.ScanModeRangeScan,
+}
+
+func NewCommand() *cobra.Command {
+
+	flags := []flag.Flag{
+		srcCBCluster,
+		&flag.CompositeFlag{
+			Flags: []flag.Flag{
+				&flag.CompositeFlag{
+					Flags: []flag.Flag{
+						srcCBUsername,
+						srcCBPassword,
+					},
+					Required: true,
+				},
+				&flag.CompositeFlag{
+					Flags: []flag.Flag{
+						srcCBClientCert,
+						srcCBClientCertPassword,
+						srcCBClientKey,
+						srcCBClientKeyPassword,
+					},
+					Required: true,
+				},
+			},
+			Type:          flag.RelationshipOR,
+			RequiredBrace: true,
+			Required:      true,
+		},
+		srcCBCACert,
+		srcCBNoSSLVerify,
+		srcCBBucket,
+		srcCBScope,
+		srcCBCollection,
+		srcCBScanMode,
+	}
+	flags = append(flags, common.GetCBFlags()...)
+	flags = append(flags, common.GetCBGenerateKeyOption(""))
+	flags = append(flags, common.GetCommonFlags()...)
+	examples := []common.Example{
+		{
+			Value: "cbmigrate couchbase --src-cb-cluster url --src-cb-username username --src-cb-password password --src-cb-bucket bucket-name --src-cb-scope scope-name --src-cb-collection collection-name --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Copies a couchbase collection to another collection, keeping the document keys and recreating the GSI indexes.",
+		},
+		{
+			Value: "cbmigrate couchbase --src-cb-cluster url --src-cb-username username --src-cb-password password --src-cb-bucket bucket-name --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name --cb-collection collection-name --cb-generate-key key::%type%::%id%",
+			Usage: "Re-keys the documents of the default collection with the generator key.",
+		},
+		{
+			Value: "cbmigrate couchbase --src-cb-cluster url --src-cb-username username --src-cb-password password --src-cb-bucket bucket-name --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name --cb-generate-key %meta().id% --hash-document-key sha256",
+			Usage: "Hashes the source document keys.",
+		},
+		{
+			Value: "cbmigrate couchbase --src-cb-cluster url --src-cb-username username --src-cb-password password --src-cb-bucket bucket-name --src-cb-scan-mode query --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Reads the source collection with a N1QL cursor instead of a KV range scan.",
+		},
+	}
+	usage := "Migrate data from a Couchbase collection to another Couchbase collection"
+	return common.NewCommand(common.Couchbase, []string{"cb"}, examples, usage, usage, flags)
+}
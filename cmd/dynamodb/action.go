@@ -1,9 +1,15 @@
 package dynamodb
 
 import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/couchbaselabs/cbmigrate/cmd/common"
 	"github.com/couchbaselabs/cbmigrate/cmd/dynamodb/command"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
 	cRepo "github.com/couchbaselabs/cbmigrate/internal/couchbase/repo"
 	"github.com/couchbaselabs/cbmigrate/internal/dynamodb"
 	dOpts "github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
@@ -15,21 +21,31 @@ import (
 
 type Action struct {
 	Migrate migrater.IMigrate[dOpts.Options]
+	// useExport replaces Migrate by one reading the table export, when --dynamodb-export is set.
+	useExport func()
 }
 
 func NewAction() *Action {
-	return &Action{
-		Migrate: migrater.NewMigrator(
-			dynamodb.NewDynamoDB(dRepo.NewRepo()),
-			couchbase.NewCouchbase(cRepo.NewRepo()),
-		),
+	cbRepo := cRepo.NewRepo()
+	newMigrate := func(dynamoRepo dRepo.IRepo) migrater.IMigrate[dOpts.Options] {
+		return migrater.NewMigrator(
+			dynamodb.NewDynamoDB(dynamoRepo),
+			couchbase.NewCouchbase(cbRepo),
+		)
+	}
+	a := &Action{
+		Migrate: newMigrate(dRepo.NewRepo()),
 	}
+	a.useExport = func() {
+		a.Migrate = newMigrate(dRepo.NewExportRepo())
+	}
+	return a
 }
 
 func (a *Action) RunE(cmd *cobra.Command, args []string) (err error) {
 
 	var missingRequiredOptions []string
-	if !cmd.Flags().Changed(command.DynamoDBTableName) {
+	if !cmd.Flags().Changed(command.DynamoDBTableName) && !cmd.Flags().Changed(command.DynamoDBExport) {
 		missingRequiredOptions = append(missingRequiredOptions, command.DynamoDBTableName)
 	}
 	missingRequiredOptions = append(missingRequiredOptions, common.CouchBaseMissingRequiredOptions(cmd)...)
@@ -59,11 +75,35 @@ func (a *Action) RunE(cmd *cobra.Command, args []string) (err error) {
 	dopts.Limit, _ = cmd.Flags().GetInt(command.DynamoDBLimit)
 	insecure, _ := cmd.Flags().GetBool(command.DynamoDBNoVerifySSL)
 	dopts.NoSSLVerify = insecure
+	if err = parseExportOptions(cmd, dopts); err != nil {
+		return err
+	}
 
 	cbOpts, err := common.ParesCouchbaseOptions(cmd, dopts.TableName)
 	if err != nil {
 		return err
 	}
+	if err = parseExpressionOptions(cmd, dopts); err != nil {
+		return err
+	}
+	dopts.Follow, _ = cmd.Flags().GetBool(command.DynamoDBFollow)
+	if dopts.ExportPath != "" {
+		if err = validateExportOptions(dopts); err != nil {
+			return err
+		}
+		if a.useExport != nil {
+			a.useExport()
+		}
+	}
+	if dopts.Follow {
+		if err = validateFollowOptions(cbOpts, dopts); err != nil {
+			return err
+		}
+		dopts.CheckpointFile, _ = cmd.Flags().GetString(command.DynamoDBCheckpoint)
+		if dopts.CheckpointFile == "" {
+			dopts.CheckpointFile = tableName(dopts.TableName) + ".stream-checkpoint"
+		}
+	}
 	copyIndexes, _ := cmd.Flags().GetBool(common.CopyIndexes)
 	bufferSize, _ := cmd.Flags().GetInt(common.BufferSize)
 	err = a.Migrate.Copy(dopts, cbOpts, copyIndexes, bufferSize)
@@ -73,6 +113,106 @@ func (a *Action) RunE(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
+// validateFollowOptions rejects the options the changes can not be applied with. The document key must also be
+// generated from the primary key only, which is checked once the key of the table is known.
+func validateFollowOptions(cbOpts *option.Options, dopts *dOpts.Options) error {
+	if cbOpts.Transactional == option.TransactionalRun {
+		return fmt.Errorf("--%s can not be used with --%s %s", command.DynamoDBFollow, common.CBTransactional,
+			option.TransactionalRun)
+	}
+	// the changes of the stream are neither filtered nor projected
+	if dopts.FilterExpression != "" || dopts.ProjectionExpression != "" || len(dopts.PartitionKeys) > 0 {
+		return fmt.Errorf("--%s can not be used with --%s, --%s or --%s", command.DynamoDBFollow,
+			command.DynamoDBFilterExpression, command.DynamoDBProjectionExpression, command.DynamoDBPartitionKeys)
+	}
+	for _, part := range strings.Split(strings.TrimSpace(cbOpts.GeneratedKey), "::") {
+		if strings.HasPrefix(part, "#") {
+			return fmt.Errorf("with --%s the document key can only be generated from the primary key and static "+
+				"text, %s is not supported", command.DynamoDBFollow, part)
+		}
+	}
+	return nil
+}
+
+// parseExportOptions reads the export directory and the table description, the table name defaults to the name of
+// the described table.
+func parseExportOptions(cmd *cobra.Command, dopts *dOpts.Options) error {
+	dopts.ExportPath, _ = cmd.Flags().GetString(command.DynamoDBExport)
+	dopts.TableDescriptionFile, _ = cmd.Flags().GetString(command.DynamoDBTableDescription)
+	switch {
+	case dopts.ExportPath == "" && dopts.TableDescriptionFile == "":
+		return nil
+	case dopts.ExportPath == "":
+		return fmt.Errorf("--%s can only be used with --%s", command.DynamoDBTableDescription, command.DynamoDBExport)
+	case dopts.TableDescriptionFile == "":
+		return fmt.Errorf("--%s requires --%s, the keys of the table are not in the export", command.DynamoDBExport,
+			command.DynamoDBTableDescription)
+	}
+	if dopts.TableName != "" {
+		return nil
+	}
+	table, err := dRepo.ReadTableDescription(dopts.TableDescriptionFile)
+	if err != nil {
+		return err
+	}
+	if table.TableName == "" {
+		return fmt.Errorf("the table description %s has no table name, set --%s", dopts.TableDescriptionFile,
+			command.DynamoDBTableName)
+	}
+	dopts.TableName = table.TableName
+	return nil
+}
+
+// validateExportOptions rejects the options that need the table, an export can only be read as a whole.
+func validateExportOptions(dopts *dOpts.Options) error {
+	if dopts.Follow || dopts.FilterExpression != "" || dopts.ProjectionExpression != "" || len(dopts.PartitionKeys) > 0 {
+		return fmt.Errorf("--%s can not be used with --%s, --%s, --%s or --%s", command.DynamoDBExport,
+			command.DynamoDBFollow, command.DynamoDBFilterExpression, command.DynamoDBProjectionExpression,
+			command.DynamoDBPartitionKeys)
+	}
+	return nil
+}
+
+// parseExpressionOptions parses the filter and projection of the items, and the partition key values to query.
+func parseExpressionOptions(cmd *cobra.Command, dopts *dOpts.Options) error {
+	dopts.FilterExpression, _ = cmd.Flags().GetString(command.DynamoDBFilterExpression)
+	dopts.ProjectionExpression, _ = cmd.Flags().GetString(command.DynamoDBProjectionExpression)
+	if names, _ := cmd.Flags().GetString(command.DynamoDBExpressionAttributeNames); names != "" {
+		if dopts.FilterExpression == "" && dopts.ProjectionExpression == "" {
+			return fmt.Errorf("--%s is only used with --%s or --%s", command.DynamoDBExpressionAttributeNames,
+				command.DynamoDBFilterExpression, command.DynamoDBProjectionExpression)
+		}
+		if err := json.Unmarshal([]byte(names), &dopts.ExpressionAttributeNames); err != nil {
+			return fmt.Errorf("invalid --%s: %w", command.DynamoDBExpressionAttributeNames, err)
+		}
+	}
+	if values, _ := cmd.Flags().GetString(command.DynamoDBExpressionAttributeValues); values != "" {
+		if dopts.FilterExpression == "" {
+			return fmt.Errorf("--%s is only used with --%s", command.DynamoDBExpressionAttributeValues,
+				command.DynamoDBFilterExpression)
+		}
+		var err error
+		if dopts.ExpressionAttributeValues, err = dRepo.UnmarshalAttributeValues([]byte(values)); err != nil {
+			return fmt.Errorf("invalid --%s: %w", command.DynamoDBExpressionAttributeValues, err)
+		}
+	}
+	partitionKeys, _ := cmd.Flags().GetStringSlice(command.DynamoDBPartitionKeys)
+	for _, pk := range partitionKeys {
+		if !slices.Contains(dopts.PartitionKeys, pk) {
+			dopts.PartitionKeys = append(dopts.PartitionKeys, pk)
+		}
+	}
+	return nil
+}
+
+// tableName returns the name of the table, which can be given by its ARN (arn:aws:dynamodb:<region>:<account>:table/<name>).
+func tableName(table string) string {
+	if i := strings.LastIndex(table, "table/"); i >= 0 {
+		return table[i+len("table/"):]
+	}
+	return table
+}
+
 func GetDynamoDBMigrateCommand() *cobra.Command {
 	cmd := command.NewCommand()
 	action := NewAction()
@@ -2,6 +2,7 @@ package dynamodb_test
 
 import (
 	_ "embed"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/couchbaselabs/cbmigrate/cmd/common"
 	"github.com/couchbaselabs/cbmigrate/cmd/dynamodb"
 	"github.com/couchbaselabs/cbmigrate/cmd/dynamodb/command"
@@ -10,6 +11,8 @@ import (
 	mocktest "github.com/couchbaselabs/cbmigrate/testhelper/mock"
 	"github.com/spf13/cobra"
 	"go.uber.org/mock/gomock"
+	"os"
+	"path/filepath"
 	"strconv"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -36,6 +39,7 @@ var _ = Describe("mongo", func() {
 		dynamoDBRegionOption := "--" + command.DynamoDBRegion
 		dynamoDBCaBundleOption := "--" + command.DynamoDBCaBundle
 		dynamoDBNoVerifySSLOption := "--" + command.DynamoDBNoVerifySSL
+		dynamoDBFollowOption := "--" + command.DynamoDBFollow
 
 		cbClusterOption := "--" + common.CBCluster
 		cbUserOption := "--" + common.CBUsername
@@ -226,6 +230,75 @@ var _ = Describe("mongo", func() {
 				Expect(copyIndexesGot).To(Equal(true))
 				Expect(bufferSizeGot).To(Equal(bufferSize.Int()))
 			})
+
+			It("the changes are followed with the default checkpoint file", func() {
+				var dOptsGot *dOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(dOpts *dOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					dOptsGot = dOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption,
+					"arn:aws:dynamodb:us-east-1:123456789012:table/"+dynamoDBTableName, dynamoDBFollowOption,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(dOptsGot.Follow).To(BeTrue())
+				Expect(dOptsGot.CheckpointFile).To(Equal(dynamoDBTableName + ".stream-checkpoint"))
+			})
+
+			It("the items are filtered, projected or queried by partition key", func() {
+				var dOptsGot *dOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(dOpts *dOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					dOptsGot = dOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName,
+					"--"+command.DynamoDBFilterExpression, "#s = :active AND age > :min",
+					"--"+command.DynamoDBProjectionExpression, "id, #s",
+					"--"+command.DynamoDBExpressionAttributeNames, `{"#s": "status"}`,
+					"--"+command.DynamoDBExpressionAttributeValues,
+					`{":active": {"S": "active"}, ":min": {"N": "18"}, ":tags": {"L": [{"SS": ["a"]}, {"NULL": true}]}}`,
+					"--"+command.DynamoDBPartitionKeys, "p1,p2,p1",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(dOptsGot.FilterExpression).To(Equal("#s = :active AND age > :min"))
+				Expect(dOptsGot.ProjectionExpression).To(Equal("id, #s"))
+				Expect(dOptsGot.ExpressionAttributeNames).To(Equal(map[string]string{"#s": "status"}))
+				Expect(dOptsGot.ExpressionAttributeValues).To(Equal(map[string]types.AttributeValue{
+					":active": &types.AttributeValueMemberS{Value: "active"},
+					":min":    &types.AttributeValueMemberN{Value: "18"},
+					":tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+						&types.AttributeValueMemberSS{Value: []string{"a"}},
+						&types.AttributeValueMemberNULL{Value: true},
+					}},
+				}))
+				Expect(dOptsGot.PartitionKeys).To(Equal([]string{"p1", "p2"}))
+			})
+
+			It("the table of an export is named by its table description", func() {
+				description := filepath.Join(GinkgoT().TempDir(), "table.json")
+				Expect(os.WriteFile(description, []byte(`{"Table": {"TableName": "orders", "KeySchema": `+
+					`[{"AttributeName": "id", "KeyType": "HASH"}]}}`), 0o644)).To(Succeed())
+				var dOptsGot *dOpts.Options
+				var cbOptsGot *option.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(dOpts *dOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					dOptsGot = dOpts
+					cbOptsGot = cbOpts
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, "--"+command.DynamoDBExport, "export",
+					"--"+command.DynamoDBTableDescription, description,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(dOptsGot).To(Equal(&dOpts.Options{TableName: "orders", Segments: 1, ExportPath: "export",
+					TableDescriptionFile: description}))
+				Expect(cbOptsGot.NameSpace.Collection).To(Equal("orders"))
+			})
 		})
 		Context("failure", func() {
 			It("missing required flags", func() {
@@ -272,6 +345,48 @@ var _ = Describe("mongo", func() {
 					cbPassword, cbBucketOption, cbBucket, cbScopeOption, cbScope)
 				Expect(err.Error()).To(Equal("inconsistent flag usage. Flags aws-access-key-id, aws-secret-access-key must all be provided together or not at all. Missing: aws-access-key-id"))
 			})
+			It("the changes can not be followed with a generated uuid key", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName, dynamoDBFollowOption,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, cbGeneratorKeyOption, "key::#UUID#")
+				Expect(err).NotTo(BeNil())
+			})
+			It("the expression attribute values must be in the DynamoDB JSON format", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName,
+					"--"+command.DynamoDBFilterExpression, "age > :min",
+					"--"+command.DynamoDBExpressionAttributeValues, `{":min": 18}`,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("the expression attribute values are only used by a filter expression", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName,
+					"--"+command.DynamoDBExpressionAttributeValues, `{":min": {"N": "18"}}`,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("the changes can not be followed with a filter expression", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName, dynamoDBFollowOption,
+					"--"+command.DynamoDBFilterExpression, "attribute_exists(id)",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("an export requires the table description", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName,
+					"--"+command.DynamoDBExport, "export",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("the changes of an export can not be followed", func() {
+				_, err := common.ExecuteCommand(cmd, dynamoDBTableNameOption, dynamoDBTableName, dynamoDBFollowOption,
+					"--"+command.DynamoDBExport, "export", "--"+command.DynamoDBTableDescription, "table.json",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
 		})
 	})
 })
@@ -17,6 +17,17 @@ const (
 	DynamoDBTableName   = "dynamodb-table-name"
 	DynamoDBSegments    = "dynamodb-segments"
 	DynamoDBLimit       = "dynamodb-limit"
+	DynamoDBFollow      = "dynamodb-follow"
+	DynamoDBCheckpoint  = "dynamodb-checkpoint-file"
+
+	DynamoDBFilterExpression          = "dynamodb-filter-expression"
+	DynamoDBProjectionExpression      = "dynamodb-projection-expression"
+	DynamoDBExpressionAttributeNames  = "dynamodb-expression-attribute-names"
+	DynamoDBExpressionAttributeValues = "dynamodb-expression-attribute-values"
+	DynamoDBPartitionKeys             = "dynamodb-partition-keys"
+
+	DynamoDBExport           = "dynamodb-export"
+	DynamoDBTableDescription = "dynamodb-table-description"
 )
 
 var dynamoDBEndpointURL = &flag.StringFlag{
@@ -58,7 +69,7 @@ var dynamoDBCaBundle = &flag.StringFlag{
 
 var dynamoDBTableName = &flag.StringFlag{
 	Name:     DynamoDBTableName,
-	Usage:    "The name of the table containing the requested item. You can also provide the Amazon Resource Name (ARN) of the table in this parameter.",
+	Usage:    "The name of the table containing the requested item. You can also provide the Amazon Resource Name (ARN) of the table in this parameter. With --dynamodb-export it defaults to the name of the table description.",
 	Required: true,
 }
 
@@ -77,6 +88,62 @@ var dynamoDBLimit = &flag.IntFlag{
 		"helping to manage memory usage and API call rates during scanning.",
 }
 
+var dynamoDBFollow = &flag.BoolFlag{
+	Name: DynamoDBFollow,
+	Usage: "After the scan, keep applying the inserts, modifies and removes of the table (read from its DynamoDB " +
+		"stream, which must be enabled with the NEW_IMAGE or NEW_AND_OLD_IMAGES view type) until interrupted.",
+}
+
+var dynamoDBCheckpoint = &flag.StringFlag{
+	Name: DynamoDBCheckpoint,
+	Usage: "File the position in the stream is persisted to with --dynamodb-follow (default " +
+		"\"<table>.stream-checkpoint\"). When the file exists the scan is skipped and the changes are applied from " +
+		"the persisted position.",
+}
+
+var dynamoDBFilterExpression = &flag.StringFlag{
+	Name: DynamoDBFilterExpression,
+	Usage: "Only migrate the items matching this DynamoDB filter expression, e.g. \"#s = :active AND age > :min\". " +
+		"The items are still read, the filter is applied by DynamoDB before returning them.",
+}
+
+var dynamoDBProjectionExpression = &flag.StringFlag{
+	Name: DynamoDBProjectionExpression,
+	Usage: "Only migrate these attributes of the items, a DynamoDB projection expression e.g. \"id, #n, address.city\". " +
+		"The attributes of the primary key are always migrated.",
+}
+
+var dynamoDBExpressionAttributeNames = &flag.StringFlag{
+	Name: DynamoDBExpressionAttributeNames,
+	Usage: "The placeholders of the attribute names used by the filter and projection expressions, as a JSON object " +
+		"e.g. '{\"#s\": \"status\"}'.",
+}
+
+var dynamoDBExpressionAttributeValues = &flag.StringFlag{
+	Name: DynamoDBExpressionAttributeValues,
+	Usage: "The placeholders of the values used by the filter expression, as a JSON object in the DynamoDB JSON " +
+		"format e.g. '{\":active\": {\"S\": \"active\"}, \":min\": {\"N\": \"18\"}}'.",
+}
+
+var dynamoDBPartitionKeys = &flag.StringSliceFlag{
+	Name: DynamoDBPartitionKeys,
+	Usage: "Query the items of these partition key values (comma separated, base64 for a binary key) instead of " +
+		"scanning the whole table. --dynamodb-segments is then the number of partitions queried concurrently.",
+}
+
+var dynamoDBExport = &flag.StringFlag{
+	Name: DynamoDBExport,
+	Usage: "A table export to S3 downloaded to this directory (holding manifest-summary.json, manifest-files.json and " +
+		"the data directory), read instead of scanning the table. Full exports in DynamoDB JSON or Amazon Ion are " +
+		"supported, --dynamodb-segments is the number of data files read concurrently.",
+}
+
+var dynamoDBTableDescription = &flag.StringFlag{
+	Name: DynamoDBTableDescription,
+	Usage: "The output of \"aws dynamodb describe-table\" for the exported table, saved to a file. The keys and the " +
+		"indexes of the table are read from it with --dynamodb-export.",
+}
+
 func NewCommand() *cobra.Command {
 
 	//short := "A tool to convert time series data in CSV to the one supported by Couchbase."
@@ -101,6 +168,15 @@ func NewCommand() *cobra.Command {
 		dynamoDBCaBundle,
 		dynamoDBSegments,
 		dynamoDBLimit,
+		dynamoDBFollow,
+		dynamoDBCheckpoint,
+		dynamoDBFilterExpression,
+		dynamoDBProjectionExpression,
+		dynamoDBExpressionAttributeNames,
+		dynamoDBExpressionAttributeValues,
+		dynamoDBPartitionKeys,
+		dynamoDBExport,
+		dynamoDBTableDescription,
 	}
 	flags = append(flags, common.GetCBFlags()...)
 	flags = append(flags, common.GetCBGenerateKeyOption(""))
@@ -122,6 +198,22 @@ func NewCommand() *cobra.Command {
 			Value: "cbmigrate dynamodb --dynamodb-table-name da-test-2 --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name --cb-collection collection-name --cb-generate-key key::%firstname%::%lastname% --hash-document-key sha256",
 			Usage: "With hash document key option.",
 		},
+		{
+			Value: "cbmigrate dynamodb --dynamodb-table-name da-test-2 --dynamodb-follow --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Imports the table, then keeps applying the changes of its stream to couchbase until interrupted. Running the same command again resumes from the persisted stream position.",
+		},
+		{
+			Value: "cbmigrate dynamodb --dynamodb-table-name da-test-2 --dynamodb-filter-expression \"#s = :active\" --dynamodb-projection-expression \"id, #s, address\" --dynamodb-expression-attribute-names '{\"#s\": \"status\"}' --dynamodb-expression-attribute-values '{\":active\": {\"S\": \"active\"}}' --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Imports the id, status and address of the active items only.",
+		},
+		{
+			Value: "cbmigrate dynamodb --dynamodb-table-name da-test-2 --dynamodb-partition-keys customer-1,customer-2 --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Imports the items of two partition key values, queried instead of scanning the table.",
+		},
+		{
+			Value: "cbmigrate dynamodb --dynamodb-export ./export --dynamodb-table-description table.json --dynamodb-segments 4 --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Imports a table export to S3 downloaded to the export directory, reading 4 data files at a time. The table is described by the output of aws dynamodb describe-table.",
+		},
 	}
 	usage := "Migrate data from DynamoDB to Couchbase"
 	return common.NewCommand(common.DynamoDB, []string{"d"}, examples, usage, usage, flags)
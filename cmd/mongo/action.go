@@ -1,12 +1,19 @@
 package mongo
 
 import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	"github.com/couchbaselabs/cbmigrate/cmd/common"
 	"github.com/couchbaselabs/cbmigrate/cmd/mongo/command"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
 	cRepo "github.com/couchbaselabs/cbmigrate/internal/couchbase/repo"
 	"github.com/couchbaselabs/cbmigrate/internal/migrater"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo"
@@ -16,28 +23,65 @@ import (
 
 type Action struct {
 	Migrate migrater.IMigrate[mOpts.Options]
+	// Repo and NewMigrate are used when several collections are migrated in one run. Every collection is migrated by
+	// its own IMigrate, all of them sharing the same connections.
+	Repo       mRepo.IRepo
+	NewMigrate func() migrater.IMigrate[mOpts.Options]
+	// useDump replaces Repo, Migrate and NewMigrate by those reading the mongodump output, when --mongodb-dump is set.
+	useDump func()
 }
 
 func NewAction() *Action {
-	return &Action{
-		Migrate: migrater.NewMigrator(
-			mongo.NewMongo(mRepo.NewRepo(), mongo.NewIndexFieldAnalyzer()),
-			couchbase.NewCouchbase(cRepo.NewRepo()),
-		),
+	cbRepo := cRepo.NewRepo()
+	newMigrate := func(mongoRepo mRepo.IRepo) func() migrater.IMigrate[mOpts.Options] {
+		return func() migrater.IMigrate[mOpts.Options] {
+			return migrater.NewMigrator(
+				mongo.NewMongo(mongoRepo, mongo.NewIndexFieldAnalyzer()),
+				couchbase.NewCouchbase(cbRepo),
+			)
+		}
+	}
+	mongoRepo := mRepo.NewRepo()
+	a := &Action{
+		Migrate:    newMigrate(mongoRepo)(),
+		Repo:       mongoRepo,
+		NewMigrate: newMigrate(mongoRepo),
+	}
+	a.useDump = func() {
+		dumpRepo := mRepo.NewDumpRepo()
+		a.Repo = dumpRepo
+		a.NewMigrate = newMigrate(dumpRepo)
+		a.Migrate = a.NewMigrate()
 	}
+	return a
+}
+
+// isMultipleCollections reports whether the collection option selects several collections, with a comma separated
+// list or glob patterns.
+func isMultipleCollections(collection string) bool {
+	return strings.ContainsAny(collection, ",*?[")
 }
 
 func (a *Action) RunE(cmd *cobra.Command, args []string) error {
 
 	var missingRequiredOptions []string
 	switch {
-	case !cmd.Flags().Changed(command.MongoDBURI) && !cmd.Flags().Changed(command.MongoDBHost):
+	case !cmd.Flags().Changed(command.MongoDBURI) && !cmd.Flags().Changed(command.MongoDBHost) &&
+		!cmd.Flags().Changed(command.MongoDBDump):
 		missingRequiredOptions = append(missingRequiredOptions, command.MongoDBURI)
 		fallthrough
-	case !cmd.Flags().Changed(command.MongoDBCollection):
+	case !cmd.Flags().Changed(command.MongoDBCollection) && !cmd.Flags().Changed(command.MongoDBGridFSBucket):
 		missingRequiredOptions = append(missingRequiredOptions, command.MongoDBCollection)
 	}
-	missingRequiredOptions = append(missingRequiredOptions, common.CouchBaseMissingRequiredOptions(cmd)...)
+	collection, _ := cmd.Flags().GetString(command.MongoDBCollection)
+	multipleCollections := isMultipleCollections(collection)
+	for _, opt := range common.CouchBaseMissingRequiredOptions(cmd) {
+		// the scope defaults to the database name when several collections are migrated
+		if opt == common.CBScope && multipleCollections {
+			continue
+		}
+		missingRequiredOptions = append(missingRequiredOptions, opt)
+	}
 	if len(missingRequiredOptions) > 0 {
 		err := common.ReqFieldsError(missingRequiredOptions)
 		if err != nil {
@@ -54,6 +98,11 @@ func (a *Action) RunE(cmd *cobra.Command, args []string) error {
 	}
 
 	mopts.URI.ConnectionString, _ = cmd.Flags().GetString(command.MongoDBURI)
+	mopts.DumpPath, _ = cmd.Flags().GetString(command.MongoDBDump)
+	if mopts.DumpPath != "" && (mopts.URI.ConnectionString != "" || cmd.Flags().Changed(command.MongoDBHost)) {
+		return fmt.Errorf("--%s can not be used with --%s or --%s", command.MongoDBDump, command.MongoDBURI,
+			command.MongoDBHost)
+	}
 	mopts.Connection.Host, _ = cmd.Flags().GetString(command.MongoDBHost)
 	mopts.Connection.Port, _ = cmd.Flags().GetString(command.MongoDBPort)
 
@@ -74,17 +123,110 @@ func (a *Action) RunE(cmd *cobra.Command, args []string) error {
 	mopts.Kerberos.Service, _ = cmd.Flags().GetString(command.MongoDBGSSAPIServiceName)
 	mopts.Namespace.DB, _ = cmd.Flags().GetString(command.MongoDBDatabase)
 	mopts.Namespace.Collection, _ = cmd.Flags().GetString(command.MongoDBCollection)
+	mopts.GridFSBucket, _ = cmd.Flags().GetString(command.MongoDBGridFSBucket)
+	cbCollection := mopts.Namespace.Collection
+	if mopts.GridFSBucket != "" {
+		if cmd.Flags().Changed(command.MongoDBCollection) {
+			return fmt.Errorf("--%s can not be used with --%s", command.MongoDBGridFSBucket, command.MongoDBCollection)
+		}
+		mopts.Namespace.Collection = mongo.GridFSFilesCollection(mopts.GridFSBucket)
+		cbCollection = couchbase.SanitizeName(mopts.GridFSBucket)
+	}
+
+	mopts.QueryOptions.Query, _ = cmd.Flags().GetString(command.MongoDBQuery)
+	mopts.QueryOptions.Projection, _ = cmd.Flags().GetString(command.MongoDBProjection)
+	mopts.QueryOptions.Limit, _ = cmd.Flags().GetInt64(command.MongoDBLimit)
+	mopts.QueryOptions.Skip, _ = cmd.Flags().GetInt64(command.MongoDBSkip)
+	if mopts.QueryOptions.Limit < 0 || mopts.QueryOptions.Skip < 0 {
+		return fmt.Errorf("--%s and --%s must not be negative", command.MongoDBLimit, command.MongoDBSkip)
+	}
+	mopts.QueryOptions.Pipeline, _ = cmd.Flags().GetString(command.MongoDBPipeline)
+	if mopts.Pipeline != "" && (mopts.Query != "" || mopts.Projection != "" || mopts.Limit > 0 || mopts.Skip > 0) {
+		return fmt.Errorf("--%s can not be used with --%s, --%s, --%s or --%s, use the $match, $project, $limit "+
+			"and $skip stages instead", command.MongoDBPipeline, command.MongoDBQuery, command.MongoDBProjection,
+			command.MongoDBLimit, command.MongoDBSkip)
+	}
 
-	cbOpts, err := common.ParesCouchbaseOptions(cmd, mopts.Namespace.Collection)
+	cbOpts, err := common.ParesCouchbaseOptions(cmd, cbCollection)
 	if err != nil {
 		return err
 	}
 	if cbOpts.GeneratedKey == "" {
 		cbOpts.GeneratedKey = " %_id%"
 	}
+	mopts.Partitions, _ = cmd.Flags().GetInt(command.MongoDBPartitions)
+	switch {
+	case mopts.Partitions < 1:
+		return fmt.Errorf("--%s must be at least 1", command.MongoDBPartitions)
+	case mopts.Partitions > 1 && (mopts.Limit > 0 || mopts.Skip > 0):
+		return fmt.Errorf("--%s can not be used with --%s or --%s", command.MongoDBPartitions, command.MongoDBLimit,
+			command.MongoDBSkip)
+	case mopts.Partitions > 1 && mopts.Pipeline != "":
+		return fmt.Errorf("--%s can not be used with --%s", command.MongoDBPartitions, command.MongoDBPipeline)
+	}
+	mopts.RetryAttempts, _ = cmd.Flags().GetInt(command.MongoDBRetryAttempts)
+	if mopts.RetryAttempts < 0 {
+		return fmt.Errorf("--%s must not be negative", command.MongoDBRetryAttempts)
+	}
+	mopts.NoCursorTimeout, _ = cmd.Flags().GetBool(command.MongoDBNoCursorTimeout)
+	if mopts.GridFSBucket != "" {
+		if err = validateGridFSOptions(cmd, mopts, cbOpts); err != nil {
+			return err
+		}
+	}
+	mopts.Snapshot, _ = cmd.Flags().GetBool(command.MongoDBSnapshot)
+	mopts.Follow, _ = cmd.Flags().GetBool(command.MongoDBFollow)
+	if mopts.Follow {
+		if err = validateFollowOptions(mopts, cbOpts, multipleCollections); err != nil {
+			return err
+		}
+		mopts.ResumeTokenFile, _ = cmd.Flags().GetString(command.MongoDBResumeTokenFile)
+		if mopts.ResumeTokenFile == "" {
+			mopts.ResumeTokenFile = mopts.Namespace.String() + ".resume-token"
+		}
+	}
 	copyIndexes, _ := cmd.Flags().GetBool(common.CopyIndexes)
 	mopts.CopyIndexes = copyIndexes
+	mopts.AnalyzerSamplePercent, _ = cmd.Flags().GetInt(command.MongoDBAnalyzerSamplePercent)
+	mopts.AnalyzerMaxOccurrences, _ = cmd.Flags().GetInt(command.MongoDBAnalyzerMaxOccurrence)
+	mopts.AnalyzerSampleSize, _ = cmd.Flags().GetInt(command.MongoDBAnalyzerSampleSize)
+	switch {
+	case mopts.AnalyzerSamplePercent < 1 || mopts.AnalyzerSamplePercent > 100:
+		return fmt.Errorf("--%s must be between 1 and 100", command.MongoDBAnalyzerSamplePercent)
+	case mopts.AnalyzerMaxOccurrences < 0 || mopts.AnalyzerSampleSize < 0:
+		return fmt.Errorf("--%s and --%s must not be negative", command.MongoDBAnalyzerMaxOccurrence,
+			command.MongoDBAnalyzerSampleSize)
+	}
+	mopts.ConvertGeoJSONPoints, _ = cmd.Flags().GetBool(command.MongoDBConvertGeoJSONPoints)
+	mopts.UniqueLookup, _ = cmd.Flags().GetBool(command.MongoDBUniqueLookup)
+	mopts.UniqueTrackingLimit, _ = cmd.Flags().GetInt(command.MongoDBUniqueTrackingLimit)
+	if mopts.UniqueTrackingLimit < 0 {
+		return fmt.Errorf("--%s must not be negative", command.MongoDBUniqueTrackingLimit)
+	}
+	mopts.TimeSeriesBuckets, _ = cmd.Flags().GetBool(command.MongoDBTimeSeriesBuckets)
+	mopts.TimeSeriesWindow, _ = cmd.Flags().GetInt(command.MongoDBTimeSeriesWindow)
+	switch {
+	case mopts.TimeSeriesWindow < 0:
+		return fmt.Errorf("--%s must not be negative", command.MongoDBTimeSeriesWindow)
+	case mopts.TimeSeriesWindow > 0 && !mopts.TimeSeriesBuckets:
+		return fmt.Errorf("--%s can only be used with --%s", command.MongoDBTimeSeriesWindow,
+			command.MongoDBTimeSeriesBuckets)
+	}
+	if err = parseReferenceOptions(cmd, mopts, cbOpts); err != nil {
+		return err
+	}
+	if mopts.DumpPath != "" {
+		if err = validateDumpOptions(mopts); err != nil {
+			return err
+		}
+		if a.useDump != nil {
+			a.useDump()
+		}
+	}
 	bufferSize, _ := cmd.Flags().GetInt(common.BufferSize)
+	if multipleCollections {
+		return a.copyCollections(cmd, mopts, cbOpts, copyIndexes, bufferSize)
+	}
 	err = a.Migrate.Copy(mopts, cbOpts, copyIndexes, bufferSize)
 	if err != nil {
 		zap.S().Fatal(err)
@@ -92,6 +234,251 @@ func (a *Action) RunE(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// validateFollowOptions rejects the options the changes can not be applied with.
+func validateFollowOptions(mopts *mOpts.Options, cbOpts *option.Options, multipleCollections bool) error {
+	switch {
+	case multipleCollections:
+		return fmt.Errorf("--%s can only be used with a single collection", command.MongoDBFollow)
+	case mopts.Query != "" || mopts.Projection != "" || mopts.Limit > 0 || mopts.Skip > 0 || mopts.Pipeline != "":
+		return fmt.Errorf("--%s can not be used with --%s, --%s, --%s, --%s or --%s", command.MongoDBFollow,
+			command.MongoDBQuery, command.MongoDBProjection, command.MongoDBLimit, command.MongoDBSkip,
+			command.MongoDBPipeline)
+	case cbOpts.Transactional == option.TransactionalRun:
+		return fmt.Errorf("--%s can not be used with --%s %s", command.MongoDBFollow, common.CBTransactional,
+			option.TransactionalRun)
+	}
+	// a deleted document is only known by its _id, so the key must be derived from it alone
+	for _, part := range strings.Split(strings.TrimSpace(cbOpts.GeneratedKey), "::") {
+		if strings.HasPrefix(part, "#") || (strings.HasPrefix(part, "%") && part != "%_id%") {
+			return fmt.Errorf("with --%s the document key can only be generated from %%_id%% and static text, "+
+				"%s is not supported", command.MongoDBFollow, part)
+		}
+	}
+	return nil
+}
+
+// parseReferenceOptions reads the reference options. The referenced documents are read by their _id, so they can
+// not be read from a dump, unless only their key is needed and it is generated from %_id% and static text.
+func parseReferenceOptions(cmd *cobra.Command, mopts *mOpts.Options, cbOpts *option.Options) error {
+	mopts.References, _ = cmd.Flags().GetString(command.MongoDBReferences)
+	fields, _ := cmd.Flags().GetString(command.MongoDBReferenceFields)
+	switch {
+	case mopts.References == "" && fields != "":
+		return fmt.Errorf("--%s can only be used with --%s", command.MongoDBReferenceFields, command.MongoDBReferences)
+	case mopts.References == "":
+		return nil
+	case mopts.References != mongo.ReferenceKey && mopts.References != mongo.ReferenceEmbed:
+		return fmt.Errorf("--%s must be one of %s or %s", command.MongoDBReferences, mongo.ReferenceKey,
+			mongo.ReferenceEmbed)
+	}
+	if fields != "" {
+		mopts.ReferenceFields = make(map[string]string)
+		for _, field := range strings.Split(fields, ",") {
+			path, collection, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok || path == "" || collection == "" {
+				return fmt.Errorf("invalid --%s %s, expected path=collection", command.MongoDBReferenceFields, field)
+			}
+			mopts.ReferenceFields[path] = collection
+		}
+	}
+	mopts.EmbedDepth, _ = cmd.Flags().GetInt(command.MongoDBEmbedDepth)
+	mopts.ReferenceCacheSize, _ = cmd.Flags().GetInt(command.MongoDBReferenceCacheSize)
+	if mopts.EmbedDepth < 0 || mopts.ReferenceCacheSize < 0 {
+		return fmt.Errorf("--%s and --%s must not be negative", command.MongoDBEmbedDepth,
+			command.MongoDBReferenceCacheSize)
+	}
+	mopts.HashDocumentKey = cbOpts.HashDocumentKey
+	keyFromID := true
+	for _, part := range strings.Split(strings.TrimSpace(cbOpts.GeneratedKey), "::") {
+		if strings.HasPrefix(part, "#") {
+			// a random key can not be generated again for a reference
+			return fmt.Errorf("with --%s the document key can not be generated with %s", command.MongoDBReferences,
+				part)
+		}
+		if strings.HasPrefix(part, "%") && part != "%_id%" {
+			keyFromID = false
+		}
+	}
+	if mopts.DumpPath != "" && (mopts.References == mongo.ReferenceEmbed || !keyFromID) {
+		return fmt.Errorf("--%s can only be used with --%s %s and a document key generated from %%_id%% and "+
+			"static text", command.MongoDBDump, command.MongoDBReferences, mongo.ReferenceKey)
+	}
+	return nil
+}
+
+// validateDumpOptions rejects the options that need a server, a dump can only be read in the order of its documents.
+func validateDumpOptions(mopts *mOpts.Options) error {
+	if mopts.Query != "" || mopts.Projection != "" || mopts.Pipeline != "" || mopts.Partitions > 1 ||
+		mopts.GridFSBucket != "" || mopts.Snapshot || mopts.Follow || mopts.AnalyzerSampleSize > 0 {
+		return fmt.Errorf("--%s can not be used with --%s, --%s, --%s, --%s, --%s, --%s, --%s or --%s",
+			command.MongoDBDump, command.MongoDBQuery, command.MongoDBProjection, command.MongoDBPipeline,
+			command.MongoDBPartitions, command.MongoDBGridFSBucket, command.MongoDBSnapshot, command.MongoDBFollow,
+			command.MongoDBAnalyzerSampleSize)
+	}
+	return nil
+}
+
+// validateGridFSOptions rejects the options the files of a GridFS bucket can not be migrated with. The content of the
+// files is written to binary documents keyed by the key of the file document, so the key must be generated again by
+// the source.
+func validateGridFSOptions(cmd *cobra.Command, mopts *mOpts.Options, cbOpts *option.Options) error {
+	mopts.GridFSPartSize, _ = cmd.Flags().GetInt(command.MongoDBGridFSPartSize)
+	mopts.GridFSFilesInFlight, _ = cmd.Flags().GetInt(command.MongoDBGridFSFilesInFlight)
+	switch {
+	case mopts.GridFSPartSize < 1 || mopts.GridFSPartSize > mongo.MaxGridFSPartSize:
+		return fmt.Errorf("--%s must be between 1 and %d", command.MongoDBGridFSPartSize, mongo.MaxGridFSPartSize)
+	case mopts.GridFSFilesInFlight < 1:
+		return fmt.Errorf("--%s must be at least 1", command.MongoDBGridFSFilesInFlight)
+	case mopts.Projection != "" || mopts.Pipeline != "" || mopts.Partitions > 1 || cmd.Flags().Changed(command.MongoDBFollow):
+		return fmt.Errorf("--%s can not be used with --%s, --%s, --%s or --%s", command.MongoDBGridFSBucket,
+			command.MongoDBProjection, command.MongoDBPipeline, command.MongoDBPartitions, command.MongoDBFollow)
+	case cbOpts.Transactional != "":
+		return fmt.Errorf("--%s can not be used with --%s", command.MongoDBGridFSBucket, common.CBTransactional)
+	}
+	for _, part := range strings.Split(strings.TrimSpace(cbOpts.GeneratedKey), "::") {
+		if strings.HasPrefix(part, "#") {
+			return fmt.Errorf("with --%s the document key can not be generated with %s", command.MongoDBGridFSBucket,
+				part)
+		}
+	}
+	mopts.HashDocumentKey = cbOpts.HashDocumentKey
+	return nil
+}
+
+// copyCollections migrates every collection selected by the collection option to the couchbase collection of the
+// same (sanitized) name. Unless a scope is given, the database is migrated to the scope of the same name.
+func (a *Action) copyCollections(cmd *cobra.Command, mopts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+	if cmd.Flags().Changed(common.CBCollection) {
+		return fmt.Errorf("--%s can not be used when several collections are migrated, every collection is migrated "+
+			"to the couchbase collection of the same name", common.CBCollection)
+	}
+	if !cmd.Flags().Changed(common.CBScope) {
+		if mopts.Namespace.DB == "" {
+			return common.ReqFieldsError([]string{common.CBScope})
+		}
+		cbOpts.Scope = couchbase.SanitizeName(mopts.Namespace.DB)
+	}
+	concurrency, _ := cmd.Flags().GetInt(command.MongoDBCollectionConcurrency)
+	if concurrency < 1 {
+		return fmt.Errorf("--%s must be at least 1", command.MongoDBCollectionConcurrency)
+	}
+
+	err := a.Repo.Init(mopts)
+	if err != nil {
+		return err
+	}
+	infos, err := a.Repo.ListCollections(context.Background())
+	if err != nil {
+		return err
+	}
+	patterns := strings.Split(mopts.Namespace.Collection, ",")
+	var names []string
+	for _, info := range infos {
+		reason := skippedCollection(info, mopts.DumpPath != "")
+		if reason == "" {
+			names = append(names, info.Name)
+			continue
+		}
+		if matchesAny(info.Name, patterns) {
+			zap.S().Warnf("%s is not migrated, %s", info.Name, reason)
+		}
+	}
+	collections, err := matchCollections(names, patterns)
+	if err != nil {
+		return err
+	}
+
+	var jobs []migrater.CollectionJob[mOpts.Options]
+	targets := make(map[string]string)
+	for _, collection := range collections {
+		target := couchbase.SanitizeName(collection)
+		if other, ok := targets[target]; ok {
+			return fmt.Errorf("collections %s and %s would both be migrated to the couchbase collection %s",
+				other, collection, target)
+		}
+		targets[target] = collection
+
+		sOpts := *mopts
+		ns := *mopts.Namespace
+		ns.Collection = collection
+		sOpts.Namespace = &ns
+		cOpts := *cbOpts
+		cNs := *cbOpts.NameSpace
+		cNs.Collection = target
+		cOpts.NameSpace = &cNs
+		jobs = append(jobs, migrater.CollectionJob[mOpts.Options]{
+			Name:       collection,
+			Migrate:    a.NewMigrate(),
+			SourceOpts: &sOpts,
+			CBOpts:     &cOpts,
+		})
+	}
+	results, err := migrater.CopyCollections(jobs, copyIndexes, bufferSize, concurrency)
+	migrater.LogSummary(results)
+	if err != nil {
+		zap.S().Fatal(err)
+	}
+	return nil
+}
+
+// skippedCollection returns why the collection is not migrated with the others, or "" when it is.
+func skippedCollection(info mRepo.CollectionInfo, dump bool) string {
+	switch {
+	case info.Type == "collection":
+		return ""
+	case info.Type == "timeseries" && dump:
+		return "a dump only holds the internal buckets of the time series collections"
+	case info.Type == "timeseries":
+		return ""
+	case info.Type == "view":
+		return "it is a view, only the collections are migrated"
+	}
+	return fmt.Sprintf("it is a %s collection, only the regular collections are migrated", info.Type)
+}
+
+// matchesAny reports whether the collection matches one of the patterns.
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCollections returns the collections matching the patterns, in the order of the patterns. A pattern without
+// glob characters must name an existing collection.
+func matchCollections(names []string, patterns []string) ([]string, error) {
+	sort.Strings(names)
+	var collections []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matched := false
+		for _, name := range names {
+			ok, err := path.Match(pattern, name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid collection pattern %s: %w", pattern, err)
+			}
+			if !ok {
+				continue
+			}
+			matched = true
+			if !seen[name] {
+				seen[name] = true
+				collections = append(collections, name)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no collection matches %s", pattern)
+		}
+	}
+	return collections, nil
+}
+
 func GetMongoMigrateCommand() *cobra.Command {
 	cmd := command.NewCommand()
 	action := NewAction()
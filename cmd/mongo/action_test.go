@@ -6,12 +6,15 @@ import (
 	"github.com/couchbaselabs/cbmigrate/cmd/mongo"
 	"github.com/couchbaselabs/cbmigrate/cmd/mongo/command"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	"github.com/couchbaselabs/cbmigrate/internal/migrater"
 	mOpts "github.com/couchbaselabs/cbmigrate/internal/mongo/option"
+	mRepo "github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
 	mocktest "github.com/couchbaselabs/cbmigrate/testhelper/mock"
 	"github.com/spf13/cobra"
 	"go.uber.org/mock/gomock"
 	"go.uber.org/zap/zapcore"
 	"strconv"
+	"sync"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -97,11 +100,16 @@ var _ = Describe("mongo", func() {
 						Collection: mongodbCollection,
 						DB:         mongodbDb,
 					},
-					Connection:  &mOpts.Connection{},
-					SSL:         &mOpts.SSL{UseSSL: true},
-					Auth:        &mOpts.Auth{},
-					Kerberos:    &mOpts.Kerberos{},
-					CopyIndexes: true,
+					Connection:             &mOpts.Connection{},
+					SSL:                    &mOpts.SSL{UseSSL: true},
+					Auth:                   &mOpts.Auth{},
+					Kerberos:               &mOpts.Kerberos{},
+					CopyIndexes:            true,
+					Partitions:             1,
+					RetryAttempts:          3,
+					AnalyzerSamplePercent:  100,
+					AnalyzerMaxOccurrences: 100,
+					UniqueTrackingLimit:    1000000,
 				}
 				expectedCbOpts := &option.Options{
 					Cluster: cbCluster,
@@ -153,11 +161,16 @@ var _ = Describe("mongo", func() {
 						Collection: mongodbCollection,
 						DB:         mongodbDb,
 					},
-					Connection:  &mOpts.Connection{},
-					SSL:         &mOpts.SSL{UseSSL: true},
-					Auth:        &mOpts.Auth{},
-					Kerberos:    &mOpts.Kerberos{},
-					CopyIndexes: true,
+					Connection:             &mOpts.Connection{},
+					SSL:                    &mOpts.SSL{UseSSL: true},
+					Auth:                   &mOpts.Auth{},
+					Kerberos:               &mOpts.Kerberos{},
+					CopyIndexes:            true,
+					Partitions:             1,
+					RetryAttempts:          3,
+					AnalyzerSamplePercent:  100,
+					AnalyzerMaxOccurrences: 100,
+					UniqueTrackingLimit:    1000000,
 				}
 				expectedCbOpts := &option.Options{
 					Cluster: cbCluster,
@@ -181,9 +194,279 @@ var _ = Describe("mongo", func() {
 				Expect(bufferSizeGot).To(Equal(bufferSize.Int()))
 			})
 
+			It("Input assertion with query options", func() {
+
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+
+				query := `{"tenant": "acme"}`
+				projection := `{"audit": 0}`
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection,
+					"--"+command.MongoDBQuery, query, "--"+command.MongoDBProjection, projection,
+					"--"+command.MongoDBLimit, "100", "--"+command.MongoDBSkip, "10",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.QueryOptions).To(Equal(mOpts.QueryOptions{
+					Query:      query,
+					Projection: projection,
+					Limit:      100,
+					Skip:       10,
+				}))
+			})
+
+			It("Input assertion with pipeline", func() {
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+				pipeline := `[{"$unwind": "$items"}]`
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBPipeline, pipeline,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.Pipeline).To(Equal(pipeline))
+			})
+
+			It("Input assertion with GridFS bucket", func() {
+				var mOptsGot *mOpts.Options
+				var cbOptsGot *option.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					cbOptsGot = cbOpts
+					return nil
+				})
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					"--"+command.MongoDBGridFSBucket, "fs", "--"+command.MongoDBGridFSPartSize, "1024",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.Namespace.Collection).To(Equal("fs.files"))
+				Expect(mOptsGot.GridFSBucket).To(Equal("fs"))
+				Expect(mOptsGot.GridFSPartSize).To(Equal(1024))
+				Expect(cbOptsGot.Collection).To(Equal("fs"))
+				Expect(mOptsGot.GridFSFilesInFlight).To(Equal(4))
+			})
+
+			It("Input assertion with follow and snapshot", func() {
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBFollow, "--"+command.MongoDBSnapshot,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.Snapshot).To(BeTrue())
+				Expect(mOptsGot.Follow).To(BeTrue())
+				Expect(mOptsGot.ResumeTokenFile).To(Equal(mongodbDb + "." + mongodbCollection + ".resume-token"))
+			})
+			It("Input assertion with a dump", func() {
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+				_, err := common.ExecuteCommand(cmd, "--"+command.MongoDBDump, "dump/archive.gz", mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.DumpPath).To(Equal("dump/archive.gz"))
+				Expect(mOptsGot.URI.ConnectionString).To(BeEmpty())
+			})
+			It("Input assertion with time series buckets", func() {
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBTimeSeriesBuckets,
+					"--"+command.MongoDBTimeSeriesWindow, "600",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.TimeSeriesBuckets).To(BeTrue())
+				Expect(mOptsGot.TimeSeriesWindow).To(Equal(600))
+			})
+			It("Input assertion with references", func() {
+				var mOptsGot *mOpts.Options
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mOptsGot = mOpts
+					return nil
+				})
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBReferences, "embed",
+					"--"+command.MongoDBReferenceFields, "author=users, tags=tags", "--"+command.MongoDBEmbedDepth, "2",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, "--"+common.HashDocumentKey, "sha256")
+				Expect(err).To(BeNil())
+				Expect(mOptsGot.References).To(Equal("embed"))
+				Expect(mOptsGot.ReferenceFields).To(Equal(map[string]string{"author": "users", "tags": "tags"}))
+				Expect(mOptsGot.EmbedDepth).To(Equal(2))
+				Expect(mOptsGot.ReferenceCacheSize).To(Equal(10000))
+				Expect(mOptsGot.HashDocumentKey).To(Equal("sha256"))
+			})
+			It("Input assertion with multiple collections", func() {
+				repo := mocktest.NewMockMongoIRepo(ctrl)
+				action.Repo = repo
+				action.NewMigrate = func() migrater.IMigrate[mOpts.Options] {
+					return migrate
+				}
+				repo.EXPECT().Init(gomock.Any()).Return(nil)
+				repo.EXPECT().ListCollections(gomock.Any()).Return([]mRepo.CollectionInfo{
+					{Name: "users", Type: "collection"}, {Name: "orders_2024", Type: "collection"},
+					{Name: "orders.archive", Type: "collection"}, {Name: "logs", Type: "collection"},
+					{Name: "orders_recent", Type: "view"}, {Name: "orders_metrics", Type: "timeseries"},
+				}, nil)
+
+				var mu sync.Mutex
+				targets := make(map[string]string)
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).Times(4).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					mu.Lock()
+					defer mu.Unlock()
+					targets[mOpts.Namespace.Collection] = cbOpts.Scope + "." + cbOpts.Collection
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, "mongo.db",
+					mongodbCollectionOption, "users,orders*",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket)
+				Expect(err).To(BeNil())
+				Expect(targets).To(Equal(map[string]string{
+					"users":          "mongo_db.users",
+					"orders.archive": "mongo_db.orders_archive",
+					"orders_2024":    "mongo_db.orders_2024",
+					"orders_metrics": "mongo_db.orders_metrics",
+				}))
+			})
+			It("time series collections of a dump are skipped with multiple collections", func() {
+				repo := mocktest.NewMockMongoIRepo(ctrl)
+				action.Repo = repo
+				action.NewMigrate = func() migrater.IMigrate[mOpts.Options] {
+					return migrate
+				}
+				repo.EXPECT().Init(gomock.Any()).Return(nil)
+				repo.EXPECT().ListCollections(gomock.Any()).Return([]mRepo.CollectionInfo{
+					{Name: "users", Type: "collection"}, {Name: "weather", Type: "timeseries"},
+				}, nil)
+				migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 10000).DoAndReturn(func(mOpts *mOpts.Options, cbOpts *option.Options, copyIndexes bool, bufferSize int) error {
+					Expect(mOpts.Namespace.Collection).To(Equal("users"))
+					return nil
+				})
+
+				_, err := common.ExecuteCommand(cmd, "--"+command.MongoDBDump, "dump", mongodbDbOption, "shop",
+					mongodbCollectionOption, "*",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket)
+				Expect(err).To(BeNil())
+			})
+
 		})
 
 		Context("failure", func() {
+			It("collection pattern without any match", func() {
+				repo := mocktest.NewMockMongoIRepo(ctrl)
+				action.Repo = repo
+				repo.EXPECT().Init(gomock.Any()).Return(nil)
+				repo.EXPECT().ListCollections(gomock.Any()).Return([]mRepo.CollectionInfo{
+					{Name: "users", Type: "collection"}, {Name: "orders_recent", Type: "view"},
+				}, nil)
+
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, "orders*",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket)
+				Expect(err).NotTo(BeNil())
+			})
+			It("follow with a document key that is not derived from _id", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBFollow,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, "--"+common.CBGenerateKey, "key::%name%")
+				Expect(err).NotTo(BeNil())
+			})
+			It("dump with a query filter", func() {
+				_, err := common.ExecuteCommand(cmd, "--"+command.MongoDBDump, "dump", mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBQuery, `{"tenant": "acme"}`,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("dump with a uri", func() {
+				_, err := common.ExecuteCommand(cmd, "--"+command.MongoDBDump, "dump", mongodbUriOption, mongodbUri,
+					mongodbDbOption, mongodbDb, mongodbCollectionOption, mongodbCollection,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("time series window without buckets", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBTimeSeriesWindow, "600",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("references with a random document key", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBReferences, "key",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope, "--"+common.CBGenerateKey, "key::#UUID#")
+				Expect(err).NotTo(BeNil())
+			})
+			It("embedded references with a dump", func() {
+				_, err := common.ExecuteCommand(cmd, "--"+command.MongoDBDump, "dump", mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBReferences, "embed",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("reference fields without references", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBReferenceFields, "author",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("pipeline with a query filter", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBPipeline, `[{"$unwind": "$items"}]`,
+					"--"+command.MongoDBQuery, `{"tenant": "acme"}`,
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("GridFS bucket with a collection", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, mongodbCollection, "--"+command.MongoDBGridFSBucket, "fs",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).NotTo(BeNil())
+			})
+			It("GridFS bucket with a random document key", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					"--"+command.MongoDBGridFSBucket, "fs", "--"+common.CBGenerateKey, "file::#UUID#",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbScopeOption, cbScope)
+				Expect(err).To(MatchError(ContainSubstring("#UUID#")))
+			})
+			It("couchbase collection with multiple collections", func() {
+				_, err := common.ExecuteCommand(cmd, mongodbUriOption, mongodbUri, mongodbDbOption, mongodbDb,
+					mongodbCollectionOption, "*",
+					cbClusterOption, cbCluster, cbUserOption, cbUser, cbPasswordOption, cbPassword,
+					cbBucketOption, cbBucket, cbCollectionOption, cbCollection)
+				Expect(err).NotTo(BeNil())
+			})
 		})
 	})
 })
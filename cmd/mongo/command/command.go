@@ -9,26 +9,54 @@ import (
 )
 
 const (
-	MongoDBHost              = "mongodb-host"
-	MongoDBPort              = "mongodb-port"
-	MongoDBSSL               = "mongodb-ssl"
-	MongoDBSSLCAFile         = "mongodb-ssl-ca-file"
-	MongoDBSSLPEMKeyFile     = "mongodb-ssl-pem-key-file"
-	MongoDBSSLPEMKeyPassword = "mongodb-ssl-pem-key-password"
-	MongoDBSSLCRLFile        = "mongodb-ssl-crl-file"
-	MongoDBSSLFIPSMode       = "mongodb-ssl-fips-mode"
-	MongoDBTLSInsecure       = "mongodb-tls-insecure"
-	MongoDBUsername          = "mongodb-username"
-	MongoDBPassword          = "mongodb-password"
-	MongoDBAuthDatabase      = "mongodb-authentication-database"
-	MongoDBAuthMechanism     = "mongodb-authentication-mechanism"
-	MongoDBAWSSessionToken   = "mongodb-aws-session-token"
-	MongoDBGSSAPIServiceName = "mongodb-gss-api-service-name"
-	MongoDBGSSAPIHostName    = "mongodb-gss-api-host-name"
-	MongoDBDatabase          = "mongodb-database"
-	MongoDBCollection        = "mongodb-collection"
-	MongoDBURI               = "mongodb-uri"
-	MongoDBReadPreference    = "mongodb-read-preference"
+	MongoDBHost                  = "mongodb-host"
+	MongoDBPort                  = "mongodb-port"
+	MongoDBSSL                   = "mongodb-ssl"
+	MongoDBSSLCAFile             = "mongodb-ssl-ca-file"
+	MongoDBSSLPEMKeyFile         = "mongodb-ssl-pem-key-file"
+	MongoDBSSLPEMKeyPassword     = "mongodb-ssl-pem-key-password"
+	MongoDBSSLCRLFile            = "mongodb-ssl-crl-file"
+	MongoDBSSLFIPSMode           = "mongodb-ssl-fips-mode"
+	MongoDBTLSInsecure           = "mongodb-tls-insecure"
+	MongoDBUsername              = "mongodb-username"
+	MongoDBPassword              = "mongodb-password"
+	MongoDBAuthDatabase          = "mongodb-authentication-database"
+	MongoDBAuthMechanism         = "mongodb-authentication-mechanism"
+	MongoDBAWSSessionToken       = "mongodb-aws-session-token"
+	MongoDBGSSAPIServiceName     = "mongodb-gss-api-service-name"
+	MongoDBGSSAPIHostName        = "mongodb-gss-api-host-name"
+	MongoDBDatabase              = "mongodb-database"
+	MongoDBCollection            = "mongodb-collection"
+	MongoDBCollectionConcurrency = "mongodb-collection-concurrency"
+	MongoDBURI                   = "mongodb-uri"
+	MongoDBDump                  = "mongodb-dump"
+	MongoDBReadPreference        = "mongodb-read-preference"
+	MongoDBQuery                 = "mongodb-query"
+	MongoDBProjection            = "mongodb-projection"
+	MongoDBLimit                 = "mongodb-limit"
+	MongoDBSkip                  = "mongodb-skip"
+	MongoDBPipeline              = "mongodb-pipeline"
+	MongoDBPartitions            = "mongodb-partitions"
+	MongoDBRetryAttempts         = "mongodb-retry-attempts"
+	MongoDBNoCursorTimeout       = "mongodb-no-cursor-timeout"
+	MongoDBSnapshot              = "mongodb-snapshot"
+	MongoDBFollow                = "mongodb-follow"
+	MongoDBResumeTokenFile       = "mongodb-resume-token-file"
+	MongoDBConvertGeoJSONPoints  = "mongodb-convert-geojson-points"
+	MongoDBUniqueLookup          = "mongodb-unique-lookup"
+	MongoDBUniqueTrackingLimit   = "mongodb-unique-tracking-limit"
+	MongoDBGridFSBucket          = "mongodb-gridfs-bucket"
+	MongoDBAnalyzerSamplePercent = "mongodb-analyzer-sample-percent"
+	MongoDBAnalyzerMaxOccurrence = "mongodb-analyzer-max-occurrences"
+	MongoDBAnalyzerSampleSize    = "mongodb-analyzer-sample-size"
+	MongoDBGridFSPartSize        = "mongodb-gridfs-part-size"
+	MongoDBGridFSFilesInFlight   = "mongodb-gridfs-files-in-flight"
+	MongoDBTimeSeriesBuckets     = "mongodb-timeseries-buckets"
+	MongoDBTimeSeriesWindow      = "mongodb-timeseries-window"
+	MongoDBReferences            = "mongodb-references"
+	MongoDBReferenceFields       = "mongodb-reference-fields"
+	MongoDBEmbedDepth            = "mongodb-embed-depth"
+	MongoDBReferenceCacheSize    = "mongodb-reference-cache-size"
 )
 
 var mongoDBHost = &flag.StringFlag{
@@ -136,23 +164,208 @@ var mongoDBDatabase = &flag.StringFlag{
 }
 
 var mongoDBCollection = &flag.StringFlag{
-	Name:     MongoDBCollection,
-	Usage:    "collection to use",
+	Name: MongoDBCollection,
+	Usage: "collection to use. Several collections are migrated in one run with a comma separated list and glob patterns" +
+		" (e.g. 'users,orders_*'), or '*' for all the collections of the database",
 	Required: true,
 }
 
+var mongoDBCollectionConcurrency = &flag.IntFlag{
+	Name:  MongoDBCollectionConcurrency,
+	Usage: "number of collections migrated at the same time when several collections are migrated",
+	Value: 4,
+}
+
 var mongoDBURI = &flag.StringFlag{
 	Name:     MongoDBURI,
 	Usage:    "mongodb uri connection string",
 	Required: true,
 }
 
+var mongoDBDump = &flag.StringFlag{
+	Name: MongoDBDump,
+	Usage: "mongodump output read instead of connecting to a server: a dump directory (the dump root or the " +
+		"directory of the database), a <collection>.bson file or an --archive file, optionally compressed with " +
+		"--gzip. The indexes are read from the metadata of the dump",
+	Required: true,
+}
+
 var mongoDBReadPreference = &flag.StringFlag{
 	Name:   MongoDBReadPreference,
 	Usage:  `specify either a preference mode (e.g. 'nearest') or a preference json object (e.g. '{mode: "nearest", tagSets: [{a: "b"}],  maxStalenessSeconds: 123}')`,
 	Hidden: !feature.IsFeatureEnabled(feature.CbmigrateMongoHostOptsConfig),
 }
 
+var mongoDBQuery = &flag.StringFlag{
+	Name:  MongoDBQuery,
+	Usage: `query filter, as an extended JSON document (e.g. '{"status": "active", "created": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}'). Only the matching documents are migrated`,
+}
+
+var mongoDBProjection = &flag.StringFlag{
+	Name:  MongoDBProjection,
+	Usage: `projection, as an extended JSON document (e.g. '{"name": 1, "address": 1}'), restricting the fields of the migrated documents`,
+}
+
+var mongoDBLimit = &flag.Int64Flag{
+	Name:  MongoDBLimit,
+	Usage: "maximum number of documents to migrate, 0 migrates all the documents",
+}
+
+var mongoDBSkip = &flag.Int64Flag{
+	Name:  MongoDBSkip,
+	Usage: "number of documents to skip, in _id order, before migrating",
+}
+
+var mongoDBPipeline = &flag.StringFlag{
+	Name: MongoDBPipeline,
+	Usage: `aggregation pipeline, as an extended JSON array of stages (e.g. '[{"$lookup": {...}}, {"$unwind": "$items"}]') ` +
+		`or the path of a file holding it. The output documents of the pipeline are migrated instead of the documents of the collection`,
+}
+
+var mongoDBPartitions = &flag.IntFlag{
+	Name: MongoDBPartitions,
+	Usage: "number of _id ranges the collection is split into, every range is read in parallel by its own cursor. " +
+		"The ranges are computed from a sample of the _ids. By default the collection is read by a single cursor",
+	Value: 1,
+}
+
+var mongoDBRetryAttempts = &flag.IntFlag{
+	Name: MongoDBRetryAttempts,
+	Usage: "number of times in a row a cursor failing with a network error or a cursor timeout is re-issued for the " +
+		"documents after the last _id read",
+	Value: 3,
+}
+
+var mongoDBNoCursorTimeout = &flag.BoolFlag{
+	Name:  MongoDBNoCursorTimeout,
+	Usage: "stops the server from closing the cursors after 10 minutes of inactivity",
+}
+
+var mongoDBConvertGeoJSONPoints = &flag.BoolFlag{
+	Name: MongoDBConvertGeoJSONPoints,
+	Usage: "converts the GeoJSON points of the 2dsphere and 2d index fields into {\"lat\", \"lon\"} objects, and maps " +
+		"the fields as geopoints instead of geoshapes in the search indexes. Other GeoJSON objects are kept as they are",
+}
+
+var mongoDBUniqueLookup = &flag.BoolFlag{
+	Name: MongoDBUniqueLookup,
+	Usage: "creates a lookup document for every value of the unique indexes, keyed by \"unique::<index name>::<values>\", " +
+		"so applications can enforce the uniqueness by inserting the lookup document first",
+}
+
+var mongoDBUniqueTrackingLimit = &flag.IntFlag{
+	Name: MongoDBUniqueTrackingLimit,
+	Usage: "number of values of every unique index kept in memory to detect the duplicates, the duplicates of the next " +
+		"values are not detected. 0 keeps every value",
+	Value: 1000000,
+}
+
+var mongoDBTimeSeriesBuckets = &flag.BoolFlag{
+	Name: MongoDBTimeSeriesBuckets,
+	Usage: "groups the measurements of the time series collections into a document per meta value and time window, " +
+		"holding the measurements in the ts_data array read by the couchbase _TIMESERIES function. Without it every " +
+		"measurement is migrated as a document",
+}
+
+var mongoDBTimeSeriesWindow = &flag.IntFlag{
+	Name: MongoDBTimeSeriesWindow,
+	Usage: "time window in seconds of the documents of --mongodb-timeseries-buckets. 0 uses the bucket span of the " +
+		"collection, from its bucketMaxSpanSeconds or its granularity (1 hour for seconds, 1 day for minutes and 30 " +
+		"days for hours)",
+}
+
+var mongoDBReferences = &flag.EnumFlag{
+	Name: MongoDBReferences,
+	Usage: "resolves the DBRefs and the --mongodb-reference-fields of the documents. 'key' rewrites them into the " +
+		"couchbase key of the referenced document, generated with --cb-generate-key (a DBRef becomes " +
+		"{\"collection\": <collection>, \"key\": <key>}), 'embed' replaces them by the referenced document. The " +
+		"references to missing documents or to other databases are migrated as they are",
+	Values: []string{"key", "embed"},
+}
+
+var mongoDBReferenceFields = &flag.StringFlag{
+	Name: MongoDBReferenceFields,
+	Usage: "comma separated list of path=collection of the fields holding the _id (or an array of _id) of a " +
+		"document of another collection, resolved with --mongodb-references, e.g. 'author=users,tags=tags'",
+}
+
+var mongoDBEmbedDepth = &flag.IntFlag{
+	Name: MongoDBEmbedDepth,
+	Usage: "number of levels of references embedded with --mongodb-references embed, the references of the deepest " +
+		"embedded documents are rewritten into keys",
+	Value: 1,
+}
+
+var mongoDBReferenceCacheSize = &flag.IntFlag{
+	Name:  MongoDBReferenceCacheSize,
+	Usage: "number of referenced documents cached with --mongodb-references, 0 reads every referenced document",
+	Value: 10000,
+}
+
+var mongoDBGridFSBucket = &flag.StringFlag{
+	Name: MongoDBGridFSBucket,
+	Usage: "GridFS bucket (e.g. 'fs') whose files are migrated instead of a collection. Every file is migrated as a " +
+		"JSON document holding the file metadata, with its content in binary documents written as its chunks are " +
+		"read. The couchbase collection defaults to the bucket name",
+}
+
+var mongoDBGridFSPartSize = &flag.IntFlag{
+	Name: MongoDBGridFSPartSize,
+	Usage: "maximum size in bytes of a binary document holding the content of a GridFS file, the larger files are " +
+		"split into ordered part documents. At most the couchbase value limit of 20MiB",
+	Value: 20 * 1024 * 1024,
+}
+
+var mongoDBGridFSFilesInFlight = &flag.IntFlag{
+	Name: MongoDBGridFSFilesInFlight,
+	Usage: "number of GridFS files read at the same time, each holding at most a part of its content in memory " +
+		"while its chunks are read",
+	Value: 4,
+}
+
+var mongoDBAnalyzerSamplePercent = &flag.IntFlag{
+	Name: MongoDBAnalyzerSamplePercent,
+	Usage: "percentage of the migrated documents analyzed with --copy-indexes to find the paths of the index fields " +
+		"(e.g. the arrays of the documents)",
+	Value: 100,
+}
+
+var mongoDBAnalyzerMaxOccurrences = &flag.IntFlag{
+	Name: MongoDBAnalyzerMaxOccurrence,
+	Usage: "number of documents an index field is analyzed in with --copy-indexes, once every field has been found " +
+		"in that many documents the documents are not analyzed anymore. 0 analyzes every document",
+	Value: 100,
+}
+
+var mongoDBAnalyzerSampleSize = &flag.IntFlag{
+	Name: MongoDBAnalyzerSampleSize,
+	Usage: "with --copy-indexes, analyzes a $sample of that many documents before the migration instead of the " +
+		"migrated documents. 0 analyzes the migrated documents",
+}
+
+var mongoDBSnapshot = &flag.BoolFlag{
+	Name: MongoDBSnapshot,
+	Usage: "reads the collection at a single point in time (readConcern snapshot at the operation time the migration " +
+		"starts at), so documents written during the migration are migrated as they were at that time. The " +
+		"operation time is logged, and --mongodb-follow applies the changes made after it. Needs a replica set or a " +
+		"sharded cluster, and the server keeps the snapshot history for minSnapshotHistoryWindowInSeconds only " +
+		"(300 by default), longer reads fail with SnapshotTooOld unless the parameter is raised on the server",
+}
+
+var mongoDBFollow = &flag.BoolFlag{
+	Name: MongoDBFollow,
+	Usage: "after the initial load, keep applying the inserts, updates, replaces and deletes of the collection " +
+		"(read from a change stream opened before the load) until interrupted. Needs a replica set or a sharded cluster",
+}
+
+var mongoDBResumeTokenFile = &flag.StringFlag{
+	Name: MongoDBResumeTokenFile,
+	Usage: "file the change stream resume token is persisted to with --mongodb-follow (default " +
+		"\"<database>.<collection>.resume-token\"). When the file exists the initial load is skipped and the changes " +
+		"are applied from the persisted token, or from the operation time of a file holding {\"operationTime\": " +
+		"<timestamp>} such as the snapshot time logged by --mongodb-snapshot",
+}
+
 func NewCommand() *cobra.Command {
 
 	//short := "A tool to convert time series data in CSV to the one supported by Couchbase."
@@ -174,9 +387,10 @@ func NewCommand() *cobra.Command {
 					Hidden:   !feature.IsFeatureEnabled(feature.CbmigrateMongoHostOptsConfig),
 					Required: true,
 				},
+				mongoDBDump,
 			},
 			Required:      true,
-			RequiredBrace: feature.IsFeatureEnabled(feature.CbmigrateMongoHostOptsConfig),
+			RequiredBrace: true,
 			Type:          flag.RelationshipOR,
 		},
 		mongoDBSSLCAFile,
@@ -189,11 +403,39 @@ func NewCommand() *cobra.Command {
 		mongoDBGSSAPIServiceName,
 		mongoDBGSSAPIHostName,
 		mongoDBCollection,
+		mongoDBCollectionConcurrency,
 		mongoDBDatabase,
 		mongoDBReadPreference,
+		mongoDBQuery,
+		mongoDBProjection,
+		mongoDBLimit,
+		mongoDBSkip,
+		mongoDBPipeline,
+		mongoDBPartitions,
+		mongoDBRetryAttempts,
+		mongoDBNoCursorTimeout,
+		mongoDBConvertGeoJSONPoints,
+		mongoDBUniqueLookup,
+		mongoDBUniqueTrackingLimit,
+		mongoDBTimeSeriesBuckets,
+		mongoDBTimeSeriesWindow,
+		mongoDBReferences,
+		mongoDBReferenceFields,
+		mongoDBEmbedDepth,
+		mongoDBReferenceCacheSize,
+		mongoDBGridFSBucket,
+		mongoDBGridFSPartSize,
+		mongoDBGridFSFilesInFlight,
+		mongoDBAnalyzerSamplePercent,
+		mongoDBAnalyzerMaxOccurrences,
+		mongoDBAnalyzerSampleSize,
+		mongoDBSnapshot,
+		mongoDBFollow,
+		mongoDBResumeTokenFile,
 	}
 	flags = append(flags, common.GetCBFlags()...)
 	flags = append(flags, common.GetCBGenerateKeyOption("%_id%"))
+	flags = append(flags, common.GetCBBinaryThresholdFlag())
 	flags = append(flags, common.GetCommonFlags()...)
 	examples := []common.Example{
 		{
@@ -212,6 +454,22 @@ func NewCommand() *cobra.Command {
 			Value: "cbmigrate mongo --mongodb-uri uri --mongodb-database db-name --mongodb-collection collection-name --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name --cb-generate-key key::%firstname%::%lastname% --hash-document-key sha256",
 			Usage: "With hash document key option.",
 		},
+		{
+			Value: "cbmigrate mongo --mongodb-uri uri --mongodb-database db-name --mongodb-collection collection-name --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name --cb-binary-threshold 1024",
+			Usage: "Stores binary fields of 1KiB or more as separate couchbase binary documents.",
+		},
+		{
+			Value: `cbmigrate mongo --mongodb-uri uri --mongodb-database db-name --mongodb-collection collection-name --mongodb-query '{"tenant": "acme", "active": true}' --mongodb-projection '{"audit": 0}' --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name`,
+			Usage: "Migrates only the active documents of a tenant, without the audit field.",
+		},
+		{
+			Value: "cbmigrate mongo --mongodb-uri uri --mongodb-database db-name --mongodb-collection collection-name --mongodb-follow --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name --cb-scope scope-name",
+			Usage: "Imports the collection, then keeps applying its changes to couchbase until interrupted. Running the same command again resumes from the persisted change stream position.",
+		},
+		{
+			Value: "cbmigrate mongo --mongodb-uri uri --mongodb-database db-name --mongodb-collection '*' --cb-cluster url --cb-username username --cb-password password --cb-bucket bucket-name",
+			Usage: "Migrates all the collections of the database to the scope of the same name, every collection to the couchbase collection of the same name.",
+		},
 	}
 	usage := "Migrate data from MongoDB to Couchbase"
 	return common.NewCommand(common.Mongo, []string{"m"}, examples, usage, usage, flags)
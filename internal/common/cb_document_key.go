@@ -1,5 +1,17 @@
 package common
 
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 type DocumentKind string
 
 const (
@@ -59,3 +71,70 @@ func (k *CBDocumentKey) GetNonCompoundPrimaryKeyOnly() string {
 func NewCBDocumentKey() ICBDocumentKey {
 	return new(CBDocumentKey)
 }
+
+// GenerateKey returns the key of the document generated by the parts of the document key, before it is hashed.
+func GenerateKey(parts []DocumentKeyPart, data map[string]interface{}) string {
+	var id strings.Builder
+	for i, k := range parts {
+		switch k.Kind {
+		case DkString:
+			id.WriteString(k.Value)
+		case DkField:
+			if val, ok := data[k.Value]; ok {
+				id.WriteString(interfaceToString(val))
+			}
+		case DkUuid:
+			id.WriteString(getUUID())
+		}
+		if i < len(parts)-1 {
+			id.WriteString("::")
+		}
+	}
+	return id.String()
+}
+
+// ComputeHash returns the hex hash of the document key with the algorithm of --hash-document-key.
+func ComputeHash(id []byte, algorithm string) (string, error) {
+	switch algorithm {
+	case "sha256":
+		sha := sha256.Sum256(id)
+		return hex.EncodeToString(sha[:]), nil
+	case "sha512":
+		sha := sha512.Sum512(id)
+		return hex.EncodeToString(sha[:]), nil
+	}
+	return "", fmt.Errorf("hash algorithm: %s not supported", algorithm)
+}
+
+func interfaceToString(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case primitive.ObjectID:
+		return v.Hex()
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func getUUID() string {
+	var id uuid.UUID
+	var err error
+	retry := 5
+	for i := 0; i <= retry; i++ {
+		id, err = uuid.NewRandom()
+		if err != nil {
+			continue
+		}
+		break
+	}
+	return id.String()
+}
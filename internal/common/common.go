@@ -8,6 +8,30 @@ import (
 
 const MetaDataID = "meta().id"
 
+// OperationField is set on the changes streamed by a followed source to the operation applied to the document.
+// Documents without it are upserted.
+const OperationField = "meta().operation"
+
+const OperationDelete = "delete"
+
+// KeyField is set by the sources on the documents they generate (e.g. lookup documents) to the key of the document,
+// the document key generator is not used for those.
+const KeyField = "meta().key"
+
+// BinaryField is set by the sources on the documents they generate with a KeyField whose value is raw binary, to the
+// Binary written as the value of the document. Setting the OperationField instead removes the generated document.
+const BinaryField = "meta().binary"
+
+// ExpiryField is set by the sources to the time.Time a document expires at. Documents without it never expire.
+const ExpiryField = "meta().expiration"
+
+// Binary is a binary value of a source document, the sources convert their binary values to it so that the destination
+// can store the large ones as binary documents. Subtype is the kind of binary data, as defined by the source.
+type Binary struct {
+	Subtype byte
+	Data    []byte
+}
+
 // IsNilOrZero checks if the provided interface{} value is nil,
 // a nil pointer, a nil interface, or a zero value of any type.
 func IsNilOrZero(i interface{}) bool {
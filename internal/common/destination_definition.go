@@ -12,3 +12,9 @@ type IDestination interface {
 	Complete() error
 	CreateIndexes(indexes []Index) error
 }
+
+// IAbortableDestination is implemented by destinations that need to know that the source failed. Abort is called
+// instead of Complete, so a destination holding the data back (e.g. for a single transaction) can drop it.
+type IAbortableDestination interface {
+	Abort(sourceErr error) error
+}
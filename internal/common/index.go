@@ -3,5 +3,9 @@ package common
 type Index struct {
 	Name  string
 	Query string
+	// SearchDefinition is the JSON definition of a search index, Query is empty for those.
+	SearchDefinition []byte
+	// Notes are the findings of the source about the index, e.g. how the fields of the documents have been analyzed.
+	Notes []string
 	Error error
 }
@@ -10,3 +10,17 @@ type ISource[Options any] interface {
 	StreamData(context.Context, chan map[string]interface{}) error
 	GetCouchbaseIndexesQuery(bucket string, scope string, collection string) ([]Index, error)
 }
+
+// IFollowSource is implemented by sources that can keep streaming the changes made to the source after the initial
+// load. Follow passes every change to apply, until ctx is done, and only acknowledges a change (e.g. persists its
+// resume position) once apply returned without error.
+type IFollowSource interface {
+	IsFollowing() bool
+	Follow(ctx context.Context, apply func(data map[string]interface{}) error) error
+}
+
+// ISnapshotSource is implemented by sources that can read a point in time snapshot of the source. SnapshotTime returns
+// the time of the snapshot the data was read at, or "" when the source was not read at a snapshot.
+type ISnapshotSource interface {
+	SnapshotTime() string
+}
@@ -0,0 +1,94 @@
+package couchbase
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+)
+
+// BinaryReference replaces a binary field that has been moved to its own binary document.
+type BinaryReference struct {
+	Key     string `json:"key"`
+	Size    int    `json:"size"`
+	SubType byte   `json:"subtype"`
+}
+
+func binaryDocumentKey(docId, path string) string {
+	return docId + "::" + path
+}
+
+// extractBinaryFields walks the document and replaces every binary value whose size is at least threshold with a
+// BinaryReference. The replaced values are returned as upsert operations keyed by the parent document id and the
+// field path, expiring with the parent document after ttl.
+func extractBinaryFields(data map[string]interface{}, docId string, threshold int, ttl time.Duration) []gocb.BulkOp {
+	var ops []gocb.BulkOp
+	var walk func(value interface{}, path string) interface{}
+	walk = func(value interface{}, path string) interface{} {
+		switch v := value.(type) {
+		case common.Binary:
+			if len(v.Data) < threshold {
+				return v
+			}
+			key := binaryDocumentKey(docId, path)
+			ops = append(ops, &gocb.UpsertOp{
+				ID:     key,
+				Value:  v.Data,
+				Expiry: ttl,
+			})
+			return BinaryReference{Key: key, Size: len(v.Data), SubType: v.Subtype}
+		case map[string]interface{}:
+			for k, iv := range v {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				v[k] = walk(iv, p)
+			}
+		default:
+			// the arrays of the sources may be named []interface{} types
+			rv := reflect.ValueOf(value)
+			if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Interface {
+				return value
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if iv := walk(rv.Index(i).Interface(), path+"["+strconv.Itoa(i)+"]"); iv != nil {
+					rv.Index(i).Set(reflect.ValueOf(iv))
+				}
+			}
+		}
+		return value
+	}
+	walk(data, "")
+	return ops
+}
+
+// binaryReferences returns the keys of the binary documents of the stored document docId, found by their
+// BinaryReference.
+func binaryReferences(doc map[string]interface{}, docId string) []string {
+	var keys []string
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			key, isKey := v["key"].(string)
+			_, hasSize := v["size"]
+			if isKey && hasSize && strings.HasPrefix(key, docId+"::") {
+				keys = append(keys, key)
+				return
+			}
+			for _, iv := range v {
+				walk(iv)
+			}
+		case []interface{}:
+			for _, iv := range v {
+				walk(iv)
+			}
+		}
+	}
+	walk(doc)
+	return keys
+}
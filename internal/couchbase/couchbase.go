@@ -1,58 +1,19 @@
 package couchbase
 
 import (
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/couchbase/gocb/v2"
 	"github.com/couchbaselabs/cbmigrate/internal/common"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase/repo"
-	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
 	cliErrors "github.com/couchbaselabs/cbmigrate/internal/errors"
 )
 
-func interfaceToString(value interface{}) string {
-	switch v := value.(type) {
-	case int:
-		return strconv.Itoa(v)
-	case int64:
-		return strconv.FormatInt(v, 10)
-	case float32:
-		return strconv.FormatFloat(float64(v), 'f', -1, 32)
-	case float64:
-		return strconv.FormatFloat(v, 'f', -1, 64)
-	case primitive.ObjectID:
-		return v.Hex()
-	case string:
-		return v
-	default:
-		return fmt.Sprintf("%v", v)
-	}
-}
-
-func getUUID() string {
-	var id uuid.UUID
-	var err error
-	retry := 5
-	for i := 0; i <= retry; i++ {
-		id, err = uuid.NewRandom()
-		if err != nil {
-			continue
-		}
-		break
-	}
-	return id.String()
-
-}
-
 type Couchbase struct {
 	db              repo.IRepo
 	bucket          string
@@ -60,10 +21,17 @@ type Couchbase struct {
 	collection      string
 	batchSize       int
 	batchDocs       []gocb.BulkOp
+	binaryDocs      []gocb.BulkOp
+	binaryThreshold int
+	transactional   string
+	txnLimit        int
+	indexDDLFile    string
 	key             common.ICBDocumentKey
 	keepPrimaryKey  bool
 	HashDocumentKey string
 	processedCount  int
+	expiredCount    int
+	expiryIgnored   bool
 }
 
 type DocKey struct {
@@ -85,6 +53,10 @@ func (c *Couchbase) Init(cbOpts *option.Options, documentKey common.ICBDocumentK
 	c.key = documentKey
 	c.keepPrimaryKey = cbOpts.KeepPrimaryKey
 	c.HashDocumentKey = cbOpts.HashDocumentKey
+	c.binaryThreshold = cbOpts.BinaryThreshold
+	c.transactional = cbOpts.Transactional
+	c.txnLimit = cbOpts.TransactionLimit
+	c.indexDDLFile = cbOpts.IndexDDLFile
 	// The check (only one key is used as a primary key) is needed to for index migration to use meta().ID instead of
 	// key while creating the index. Also, that key can be ignored while inserting the doc into couchbase
 	var keyParts []common.DocumentKeyPart
@@ -144,9 +116,10 @@ l1:
 			}
 		}
 	}
+	// the scope may be created concurrently by the destination of another collection of the same run
 	if foundScope != true {
 		err = c.db.CreateScope(c.scope)
-		if err != nil {
+		if err != nil && !errors.Is(err, gocb.ErrScopeExists) {
 			return err
 		}
 	}
@@ -160,42 +133,121 @@ l1:
 }
 
 func (c *Couchbase) ProcessData(data map[string]interface{}) error {
-	var id strings.Builder
-	key := c.key.GetKey()
-	kLen := len(key)
-	for i, k := range key {
-		switch k.Kind {
-		case common.DkString:
-			id.WriteString(k.Value)
-		case common.DkField:
-			if val, ok := data[k.Value]; ok {
-				id.WriteString(interfaceToString(val))
+	operation := data[common.OperationField]
+	delete(data, common.OperationField)
+	expiry, hasExpiry := data[common.ExpiryField].(time.Time)
+	delete(data, common.ExpiryField)
+	if key, ok := data[common.KeyField].(string); ok {
+		// the documents generated by the source are written as they are, under their own key
+		delete(data, common.KeyField)
+		if operation == common.OperationDelete {
+			return c.addDoc(&gocb.RemoveOp{ID: key}, key)
+		}
+		var ttl time.Duration
+		if hasExpiry {
+			if ttl = c.ttl(expiry, key); ttl <= 0 {
+				return nil
 			}
-		case common.DkUuid:
-			id.WriteString(getUUID())
 		}
-		if i < kLen-1 {
-			id.WriteString("::")
+		if binary, ok := data[common.BinaryField].(common.Binary); ok {
+			return c.addBinaryDoc(&gocb.UpsertOp{ID: key, Value: binary.Data, Expiry: ttl})
 		}
+		return c.addDoc(&gocb.UpsertOp{ID: key, Value: data, Expiry: ttl}, key)
 	}
+	key := c.key.GetKey()
+	id := common.GenerateKey(key, data)
 	if len(key) == 1 && key[0].Kind == common.DkField && c.HashDocumentKey == "" && !c.keepPrimaryKey {
 		delete(data, key[0].Value)
 	}
-	docId := id.String()
+	docId := id
 	if c.HashDocumentKey != "" {
 		var err error
-		docId, err = ComputeHash([]byte(id.String()), c.HashDocumentKey)
+		docId, err = ComputeHash([]byte(id), c.HashDocumentKey)
 		if err != nil {
 			return err
 		}
 	}
-	c.batchDocs = append(c.batchDocs, &gocb.UpsertOp{
-		ID:    docId,
-		Value: data,
-	})
+	switch {
+	case operation == common.OperationDelete:
+		if c.binaryThreshold > 0 {
+			if err := c.removeBinaryDocuments(docId, nil); err != nil {
+				return err
+			}
+		}
+		return c.addDoc(&gocb.RemoveOp{ID: docId}, id)
+	default:
+		var ttl time.Duration
+		if hasExpiry {
+			if ttl = c.ttl(expiry, docId); ttl <= 0 {
+				return nil
+			}
+		}
+		if c.binaryThreshold > 0 {
+			binaryDocs := extractBinaryFields(data, docId, c.binaryThreshold, ttl)
+			// an updated document may not hold some of its binary fields anymore, or hold them inline
+			if err := c.removeBinaryDocuments(docId, binaryDocs); err != nil {
+				return err
+			}
+			c.binaryDocs = append(c.binaryDocs, binaryDocs...)
+		}
+		return c.addDoc(&gocb.UpsertOp{
+			ID:     docId,
+			Value:  data,
+			Expiry: ttl,
+		}, id)
+	}
+}
+
+// removeBinaryDocuments removes the binary documents of the fields of the document docId, found by the references of
+// the stored document, except those written again by kept.
+func (c *Couchbase) removeBinaryDocuments(docId string, kept []gocb.BulkOp) error {
+	doc, err := c.db.GetDocument(c.scope, c.collection, docId)
+	if err != nil {
+		return fmt.Errorf("error reading the binary fields of the document %s: %w", docId, err)
+	}
+	keys := make(map[string]bool, len(kept))
+	for _, op := range kept {
+		keys[op.(*gocb.UpsertOp).ID] = true
+	}
+	for _, key := range binaryReferences(doc, docId) {
+		if keys[key] {
+			continue
+		}
+		if err = c.addDoc(&gocb.RemoveOp{ID: key}, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ttl returns the time to live of a document expiring at expiry, the document is not migrated when it is not positive.
+func (c *Couchbase) ttl(expiry time.Time, docId string) time.Duration {
+	ttl := time.Until(expiry)
+	if ttl <= 0 {
+		// the document is still in the source as the source removes the expired documents in the background
+		c.expiredCount++
+		zap.S().Debugf("document %s expired at %s, it is not migrated", docId, expiry.Format(time.RFC3339))
+		return ttl
+	}
+	if c.transactional != "" && !c.expiryIgnored {
+		c.expiryIgnored = true
+		zap.S().Warnf("the expiry of the documents is not set by transactional writes, the documents never expire")
+	}
+	return ttl
+}
 
+// addDoc adds the operation to the batch, and writes the batch when it is full.
+func (c *Couchbase) addDoc(op gocb.BulkOp, id string) error {
+	c.batchDocs = append(c.batchDocs, op)
 	// to track the number of documents processed.
 	c.processedCount++
+	if c.transactional == option.TransactionalRun {
+		// the whole run is written by Complete in a single transaction, so nothing is written when the limit is hit
+		if c.txnLimit > 0 && c.processedCount > c.txnLimit {
+			return fmt.Errorf("transaction limit of %d documents exceeded, no document has been written", c.txnLimit)
+		}
+		return nil
+	}
 	// insert and rest docs when the length of the docs is equal to the batch size
 	if len(c.batchDocs)%c.batchSize == 0 {
 		err := c.UpsertData()
@@ -203,55 +255,144 @@ func (c *Couchbase) ProcessData(data map[string]interface{}) error {
 			return err
 		}
 		zap.S().Infof("%d documents processed", c.processedCount)
-		zap.S().Debugf("last processed document %v", id.String())
+		zap.S().Debugf("last processed document %v", id)
 	}
 	return nil
 }
 
+// addBinaryDoc adds the upsert of a binary document generated by the source, the binary documents are written when a
+// batch of them is full, or ahead of the next batch of documents.
+func (c *Couchbase) addBinaryDoc(op *gocb.UpsertOp) error {
+	c.binaryDocs = append(c.binaryDocs, op)
+	c.processedCount++
+	if len(c.binaryDocs) < c.batchSize {
+		return nil
+	}
+	return c.upsertBinaryData()
+}
+
 func ComputeHash(id []byte, algorithm string) (string, error) {
-	switch algorithm {
-	case "sha256":
-		sha := sha256.Sum256(id)
-		return hex.EncodeToString(sha[:]), nil
-	case "sha512":
-		sha := sha512.Sum512(id)
-		return hex.EncodeToString(sha[:]), nil
-	}
-	return "", fmt.Errorf("hash algorithm: %s not supported", algorithm)
+	return common.ComputeHash(id, algorithm)
 }
 
 func (c *Couchbase) Complete() (err error) {
+	if c.expiredCount > 0 {
+		zap.S().Infof("%d documents already expired at migration time were skipped", c.expiredCount)
+		c.expiredCount = 0
+	}
 	if len(c.batchDocs) == 0 {
-		return nil
+		return c.upsertBinaryData()
 	}
 	return c.UpsertData()
 }
 
+// Abort drops the documents held back for a single transaction run, so nothing is written when the source fails.
+// Otherwise, the documents processed so far are written as usual.
+func (c *Couchbase) Abort(sourceErr error) error {
+	if c.transactional == option.TransactionalRun {
+		zap.S().Warnf("%d documents discarded, the transaction is not committed due to the source error: %s",
+			len(c.batchDocs), sourceErr.Error())
+		c.batchDocs = nil
+		c.binaryDocs = nil
+		return nil
+	}
+	return c.Complete()
+}
+
 func (c *Couchbase) UpsertData() error {
+	// binary documents are written first so that the references in the parent documents are never dangling
+	if err := c.upsertBinaryData(); err != nil {
+		return err
+	}
+	if c.transactional != "" {
+		err := c.db.UpsertDataInTransaction(c.scope, c.collection, c.batchDocs)
+		if err != nil {
+			return fmt.Errorf("transaction rolled back: %w", err)
+		}
+		c.batchDocs = nil
+		return nil
+	}
 	err := c.db.UpsertData(c.scope, c.collection, c.batchDocs)
 	if err != nil {
 		return err
 	}
 	// Be sure to check each operation for errors too.
 	for _, op := range c.batchDocs {
+		switch o := op.(type) {
+		case *gocb.UpsertOp:
+			if o.Err != nil {
+				zap.S().Errorf("error %#v occured for the document %#v", o.Err, o.Value)
+			}
+		case *gocb.RemoveOp:
+			// the document may never have been migrated, e.g. when it was deleted during the initial load
+			if o.Err != nil && !errors.Is(o.Err, gocb.ErrDocumentNotFound) {
+				zap.S().Errorf("error %#v occured while removing the document %s", o.Err, o.ID)
+			}
+		}
+	}
+	c.batchDocs = nil
+	return nil
+}
+
+func (c *Couchbase) upsertBinaryData() error {
+	if len(c.binaryDocs) == 0 {
+		return nil
+	}
+	err := c.db.UpsertBinaryData(c.scope, c.collection, c.binaryDocs)
+	if err != nil {
+		return err
+	}
+	for _, op := range c.binaryDocs {
 		upsertOp := op.(*gocb.UpsertOp)
 		if upsertOp.Err != nil {
-			zap.S().Errorf("error %#v occured for the document %#v", upsertOp.Err, upsertOp.Value)
+			zap.S().Errorf("error %#v occured for the binary document %s", upsertOp.Err, upsertOp.ID)
 		}
 	}
-	c.batchDocs = nil
+	c.binaryDocs = nil
 	return nil
 }
 
+func logIndexError(index common.Index) {
+	var err cliErrors.NotSupportedError
+	if errors.As(index.Error, &err) {
+		zap.S().Warnf("error %s occurred while creating index query %s", index.Error.Error(), index.Name)
+	} else {
+		zap.S().Errorf("error %s occurred while creating index query %s", index.Error.Error(), index.Name)
+	}
+}
+
+// logIndexNotes reports the findings of the source about the index.
+func logIndexNotes(index common.Index) {
+	for _, note := range index.Notes {
+		zap.S().Infof("index %s: %s", index.Name, note)
+	}
+}
+
+// buildDeferredIndexesQuery returns the statement building the indexes of the collection created with defer_build.
+func (c *Couchbase) buildDeferredIndexesQuery() string {
+	keyspace := fmt.Sprintf("`%s`.`%s`.`%s`", c.bucket, c.scope, c.collection)
+	return fmt.Sprintf("BUILD INDEX ON %s((SELECT RAW name FROM system:indexes  WHERE "+
+		"keyspace_id = '%s' AND scope_id = '%s' AND bucket_id = '%s' AND state = 'deferred' ));",
+		keyspace, c.collection, c.scope, c.bucket)
+}
+
 func (c *Couchbase) CreateIndexes(indexes []common.Index) error {
+	if c.indexDDLFile != "" {
+		return c.writeIndexDDL(indexes)
+	}
 	for _, index := range indexes {
+		logIndexNotes(index)
 		if index.Error != nil {
-			var err cliErrors.NotSupportedError
-			if errors.As(index.Error, &err) {
-				zap.S().Warnf("error %s occurred while creating index query %s", index.Error.Error(), index.Name)
-			} else {
-				zap.S().Errorf("error %s occurred while creating index query %s", index.Error.Error(), index.Name)
+			logIndexError(index)
+			continue
+		}
+		if index.SearchDefinition != nil {
+			err := c.db.CreateSearchIndex(c.scope, index.SearchDefinition)
+			if err != nil {
+				zap.S().Errorf("error %#v occured while creating search index %s", err.Error(), index.Name)
+				continue
 			}
+			zap.S().Debugf("search index %s created successfully", index.Name)
 			continue
 		}
 		err := c.db.CreateIndex(index.Query)
@@ -262,12 +403,8 @@ func (c *Couchbase) CreateIndexes(indexes []common.Index) error {
 		zap.S().Debugf("index %s created successfully", index.Name)
 	}
 
-	keyspace := fmt.Sprintf("`%s`.`%s`.`%s`", c.bucket, c.scope, c.collection)
 	// build differed index
-	query := fmt.Sprintf("BUILD INDEX ON %s((SELECT RAW name FROM system:indexes  WHERE "+
-		"keyspace_id = '%s' AND scope_id = '%s' AND bucket_id = '%s' AND state = 'deferred' ));",
-		keyspace, c.collection, c.scope, c.bucket)
-	err := c.db.CreateIndex(query)
+	err := c.db.CreateIndex(c.buildDeferredIndexesQuery())
 	if err != nil {
 		zap.S().Errorf("error %#v occured while building indexes", err.Error())
 	}
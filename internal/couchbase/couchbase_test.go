@@ -6,13 +6,18 @@ import (
 	"github.com/couchbaselabs/cbmigrate/internal/common"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase"
 	cOpts "github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	cliErrors "github.com/couchbaselabs/cbmigrate/internal/errors"
 	mock_test "github.com/couchbaselabs/cbmigrate/testhelper/mock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	"go.uber.org/mock/gomock"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 var scopeSpec1 = gocb.ScopeSpec{
@@ -465,6 +470,282 @@ var _ = Describe("couchbase service", func() {
 				err = couchbaseService.Complete()
 				Expect(err).To(BeNil())
 			})
+			It("store binary fields above the threshold as binary documents", func() {
+				copts := *opts
+				copts.BinaryThreshold = 4
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				doc := map[string]interface{}{
+					"id":    1,
+					"small": common.Binary{Subtype: 0, Data: []byte{1, 2}},
+					"files": []interface{}{
+						map[string]interface{}{"content": common.Binary{Subtype: 5, Data: []byte{1, 2, 3, 4, 5}}},
+					},
+					common.ExpiryField: time.Now().Add(time.Hour),
+				}
+				db.EXPECT().GetDocument(copts.Scope, copts.Collection, "1").Return(nil, nil)
+				gomock.InOrder(
+					db.EXPECT().UpsertBinaryData(copts.Scope, copts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, uDocs []gocb.BulkOp) error {
+							Expect(uDocs).To(HaveLen(1))
+							Expect(uDocs[0].(*gocb.UpsertOp).ID).To(Equal("1::files[0].content"))
+							Expect(uDocs[0].(*gocb.UpsertOp).Value).To(Equal([]byte{1, 2, 3, 4, 5}))
+							// the binary documents expire with their document
+							Expect(uDocs[0].(*gocb.UpsertOp).Expiry).To(BeNumerically("~", time.Hour, time.Minute))
+							return nil
+						}),
+					db.EXPECT().UpsertData(copts.Scope, copts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, uDocs []gocb.BulkOp) error {
+							Expect(uDocs).To(HaveLen(1))
+							Expect(uDocs[0].(*gocb.UpsertOp).Value).To(Equal(map[string]interface{}{
+								"small": common.Binary{Subtype: 0, Data: []byte{1, 2}},
+								"files": []interface{}{
+									map[string]interface{}{"content": couchbase.BinaryReference{Key: "1::files[0].content", Size: 5, SubType: 5}},
+								},
+							}))
+							return nil
+						}),
+				)
+				err = couchbaseService.ProcessData(doc)
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+		})
+		Context("change processing", func() {
+			It("the binary documents of deleted documents are removed", func() {
+				copts := *opts
+				copts.BinaryThreshold = 4
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().GetDocument(copts.Scope, copts.Collection, "2").Return(map[string]interface{}{
+					"name": "a",
+					"files": []interface{}{
+						map[string]interface{}{"content": map[string]interface{}{"key": "2::files[0].content", "size": 5.0, "subtype": 0.0}},
+					},
+				}, nil)
+				db.EXPECT().UpsertData(copts.Scope, copts.Collection, gomock.Any()).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(2))
+						Expect(uDocs[0].(*gocb.RemoveOp).ID).To(Equal("2::files[0].content"))
+						Expect(uDocs[1].(*gocb.RemoveOp).ID).To(Equal("2"))
+						return nil
+					})
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 2, common.OperationField: common.OperationDelete})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+			It("the binary documents of the binary fields an update removes are removed", func() {
+				copts := *opts
+				copts.BinaryThreshold = 4
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().GetDocument(copts.Scope, copts.Collection, "2").Return(map[string]interface{}{
+					"photo": map[string]interface{}{"key": "2::photo", "size": 5.0, "subtype": 0.0},
+					"thumb": map[string]interface{}{"key": "2::thumb", "size": 5.0, "subtype": 0.0},
+				}, nil)
+				gomock.InOrder(
+					db.EXPECT().UpsertBinaryData(copts.Scope, copts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, uDocs []gocb.BulkOp) error {
+							Expect(uDocs).To(HaveLen(1))
+							Expect(uDocs[0].(*gocb.UpsertOp).ID).To(Equal("2::photo"))
+							return nil
+						}),
+					db.EXPECT().UpsertData(copts.Scope, copts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, uDocs []gocb.BulkOp) error {
+							// the thumb is now below the threshold, its binary document is stale
+							Expect(uDocs).To(HaveLen(2))
+							Expect(uDocs[0].(*gocb.RemoveOp).ID).To(Equal("2::thumb"))
+							Expect(uDocs[1].(*gocb.UpsertOp).ID).To(Equal("2"))
+							return nil
+						}),
+				)
+				err = couchbaseService.ProcessData(map[string]interface{}{
+					"id":    2,
+					"photo": common.Binary{Data: []byte{1, 2, 3, 4, 5}},
+					"thumb": common.Binary{Data: []byte{1, 2}},
+				})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+			It("deleted documents are removed", func() {
+				db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+				err := couchbaseService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().UpsertData(opts.Scope, opts.Collection, gomock.Any()).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(2))
+						Expect(uDocs[0].(*gocb.UpsertOp).ID).To(Equal("1"))
+						Expect(uDocs[1].(*gocb.RemoveOp).ID).To(Equal("2"))
+						uDocs[1].(*gocb.RemoveOp).Err = gocb.ErrDocumentNotFound
+						return nil
+					})
+				err = couchbaseService.ProcessData(docs[0])
+				Expect(err).To(BeNil())
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 2, common.OperationField: common.OperationDelete})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+		})
+		Context("document expiry", func() {
+			It("documents expire at their expiry and already expired documents are skipped", func() {
+				db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+				err := couchbaseService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().UpsertData(opts.Scope, opts.Collection, gomock.Any()).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(2))
+						Expect(uDocs[0].(*gocb.UpsertOp).ID).To(Equal("1"))
+						Expect(uDocs[0].(*gocb.UpsertOp).Expiry).To(BeNumerically("~", time.Hour, time.Minute))
+						Expect(uDocs[0].(*gocb.UpsertOp).Value).NotTo(HaveKey(common.ExpiryField))
+						Expect(uDocs[1].(*gocb.UpsertOp).ID).To(Equal("3"))
+						Expect(uDocs[1].(*gocb.UpsertOp).Expiry).To(BeZero())
+						return nil
+					})
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 1, common.ExpiryField: time.Now().Add(time.Hour)})
+				Expect(err).To(BeNil())
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 2, common.ExpiryField: time.Now().Add(-time.Hour)})
+				Expect(err).To(BeNil())
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 3})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+		})
+		Context("documents generated by the source", func() {
+			It("are written under their own key", func() {
+				db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+				err := couchbaseService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().UpsertData(opts.Scope, opts.Collection, gomock.Any()).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(1))
+						Expect(uDocs[0].(*gocb.UpsertOp).ID).To(Equal("unique::id_1::1"))
+						Expect(uDocs[0].(*gocb.UpsertOp).Value).To(Equal(map[string]interface{}{"id": 1}))
+						return nil
+					})
+				err = couchbaseService.ProcessData(map[string]interface{}{"id": 1, common.KeyField: "unique::id_1::1"})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+			It("binary documents are written ahead of the documents, and removed documents are removed", func() {
+				db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+				err := couchbaseService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+				gomock.InOrder(
+					db.EXPECT().UpsertBinaryData(opts.Scope, opts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, bDocs []gocb.BulkOp) error {
+							Expect(bDocs).To(HaveLen(1))
+							Expect(bDocs[0].(*gocb.UpsertOp).ID).To(Equal("f1::content[0]"))
+							Expect(bDocs[0].(*gocb.UpsertOp).Value).To(Equal([]byte("abc")))
+							Expect(bDocs[0].(*gocb.UpsertOp).Expiry).To(BeNumerically("~", time.Hour, time.Minute))
+							return nil
+						}),
+					db.EXPECT().UpsertData(opts.Scope, opts.Collection, gomock.Any()).DoAndReturn(
+						func(scope, collection string, uDocs []gocb.BulkOp) error {
+							Expect(uDocs).To(HaveLen(1))
+							Expect(uDocs[0].(*gocb.RemoveOp).ID).To(Equal("f2::content[0]"))
+							return nil
+						}),
+				)
+				err = couchbaseService.ProcessData(map[string]interface{}{
+					common.KeyField: "f1::content[0]", common.BinaryField: common.Binary{Data: []byte("abc")},
+					common.ExpiryField: time.Now().Add(time.Hour),
+				})
+				Expect(err).To(BeNil())
+				err = couchbaseService.ProcessData(map[string]interface{}{
+					common.KeyField: "f2::content[0]", common.OperationField: common.OperationDelete,
+				})
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+		})
+		Context("transactional data processing", func() {
+			It("each batch is written in its own transaction", func() {
+				copts := *opts
+				copts.Transactional = cOpts.TransactionalBatch
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().UpsertDataInTransaction(copts.Scope, copts.Collection, gomock.Any()).Times(5).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(copts.BatchSize))
+						return nil
+					})
+				for _, doc := range docs {
+					err = couchbaseService.ProcessData(doc)
+					Expect(err).To(BeNil())
+				}
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+			It("the whole run is written in a single transaction", func() {
+				copts := *opts
+				copts.Transactional = cOpts.TransactionalRun
+				copts.TransactionLimit = 1000
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().UpsertDataInTransaction(copts.Scope, copts.Collection, gomock.Any()).Times(1).DoAndReturn(
+					func(scope, collection string, uDocs []gocb.BulkOp) error {
+						Expect(uDocs).To(HaveLen(len(docs)))
+						return nil
+					})
+				for _, doc := range docs {
+					err = couchbaseService.ProcessData(doc)
+					Expect(err).To(BeNil())
+				}
+				err = couchbaseService.Complete()
+				Expect(err).To(BeNil())
+			})
+			It("nothing is written when the transaction limit is exceeded", func() {
+				copts := *opts
+				copts.Transactional = cOpts.TransactionalRun
+				copts.TransactionLimit = 10
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				for _, doc := range docs[:10] {
+					err = couchbaseService.ProcessData(doc)
+					Expect(err).To(BeNil())
+				}
+				err = couchbaseService.ProcessData(docs[10])
+				Expect(err).NotTo(BeNil())
+			})
+			It("nothing is written when the source fails", func() {
+				copts := *opts
+				copts.Transactional = cOpts.TransactionalRun
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				for _, doc := range docs {
+					err = couchbaseService.ProcessData(doc)
+					Expect(err).To(BeNil())
+				}
+				err = couchbaseService.(common.IAbortableDestination).Abort(errors.New("cursor not found"))
+				Expect(err).To(BeNil())
+			})
+			It("transaction failure is reported", func() {
+				copts := *opts
+				copts.Transactional = cOpts.TransactionalRun
+				db.EXPECT().Init(copts.Cluster, &copts).Return(nil)
+				err := couchbaseService.Init(&copts, docKey)
+				Expect(err).To(BeNil())
+				txnError := errors.New("transaction failed")
+				db.EXPECT().UpsertDataInTransaction(copts.Scope, copts.Collection, gomock.Any()).Return(txnError)
+				err = couchbaseService.ProcessData(docs[0])
+				Expect(err).To(BeNil())
+				err = couchbaseService.Complete()
+				Expect(errors.Is(err, txnError)).To(BeTrue())
+			})
 		})
 		Context("data processing failure", func() {
 			It("upsert data failure in process data function and complete function", func() {
@@ -498,4 +779,80 @@ var _ = Describe("couchbase service", func() {
 			})
 		})
 	})
+	Describe("test couchbase index creation", func() {
+		var (
+			ctrl             *gomock.Controller
+			db               *mock_test.MockCouchbaseIRepo
+			couchbaseService common.IDestination
+		)
+		newOpts := func() *cOpts.Options {
+			return &cOpts.Options{
+				Cluster:   "cluster-url",
+				NameSpace: &cOpts.NameSpace{Bucket: "test_bucket", Scope: "test_scope", Collection: "test_col"},
+				BatchSize: 100,
+			}
+		}
+		indexes := []common.Index{
+			{Name: "idx1", Query: "CREATE INDEX `idx1` ON `test_bucket`.`test_scope`.`test_col`(`k1` ASC)"},
+			{Name: "test_col_text", SearchDefinition: []byte(`{"name":"test_col_text"}`)},
+			{Name: "idx2", Error: cliErrors.NewMongoNotSupportedError("geo spatial index not supported")},
+		}
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			db = mock_test.NewMockCouchbaseIRepo(ctrl)
+			couchbaseService = couchbase.NewCouchbase(db)
+			db.EXPECT().GetAllScopes().Return([]gocb.ScopeSpec{scopeSpec1}, nil)
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		It("search indexes are created in the scope", func() {
+			opts := newOpts()
+			db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+			err := couchbaseService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).To(BeNil())
+			gomock.InOrder(
+				db.EXPECT().CreateIndex(indexes[0].Query).Return(nil),
+				db.EXPECT().CreateSearchIndex(opts.Scope, indexes[1].SearchDefinition).Return(nil),
+				db.EXPECT().CreateIndex(gomock.Any()).Return(nil),
+			)
+			err = couchbaseService.CreateIndexes(indexes)
+			Expect(err).To(BeNil())
+		})
+		It("index definitions are written to the ddl file instead of being created", func() {
+			opts := newOpts()
+			opts.IndexDDLFile = filepath.Join(GinkgoT().TempDir(), "indexes.ddl")
+			db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+			err := couchbaseService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).To(BeNil())
+			err = couchbaseService.CreateIndexes(indexes)
+			Expect(err).To(BeNil())
+			content, err := os.ReadFile(opts.IndexDDLFile)
+			Expect(err).To(BeNil())
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(Equal(indexes[0].Query + ";"))
+			Expect(lines[1]).To(HavePrefix("BUILD INDEX ON `test_bucket`.`test_scope`.`test_col`"))
+			content, err = os.ReadFile(opts.IndexDDLFile + couchbase.SearchDDLFileSuffix)
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(Equal(`{"name":"test_col_text"}` + "\n"))
+		})
+		It("index notes are written as comments before the index definition", func() {
+			opts := newOpts()
+			opts.IndexDDLFile = filepath.Join(GinkgoT().TempDir(), "indexes.ddl")
+			db.EXPECT().Init(opts.Cluster, opts).Return(nil)
+			err := couchbaseService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).To(BeNil())
+			noted := indexes[0]
+			noted.Notes = []string{"field k1 found in 2 of the 2 analyzed documents (as k1[] in 2), indexed as k1[] (array)"}
+			err = couchbaseService.CreateIndexes([]common.Index{noted})
+			Expect(err).To(BeNil())
+			content, err := os.ReadFile(opts.IndexDDLFile)
+			Expect(err).To(BeNil())
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			Expect(lines).To(HaveLen(3))
+			Expect(lines[0]).To(Equal("/* " + noted.Notes[0] + " */"))
+			Expect(lines[1]).To(Equal(noted.Query + ";"))
+		})
+	})
 })
@@ -0,0 +1,75 @@
+package couchbase
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"go.uber.org/zap"
+)
+
+// ddlFileMu serializes the writes of the destinations of a multi collection run, which share the DDL file.
+var ddlFileMu sync.Mutex
+
+// SearchDDLFileSuffix is appended to the name of the DDL file to name the file of the search index definitions, which
+// are JSON documents for the search REST API and cannot be run with the N1QL statements.
+const SearchDDLFileSuffix = ".search.json"
+
+// writeIndexDDL appends the GSI statements of the collection to the DDL file instead of creating the indexes, and its
+// search index definitions to the DDL file suffixed with SearchDDLFileSuffix, one JSON document per line.
+func (c *Couchbase) writeIndexDDL(indexes []common.Index) error {
+	var ddl, search strings.Builder
+	for _, index := range indexes {
+		logIndexNotes(index)
+		if index.Error != nil {
+			logIndexError(index)
+			continue
+		}
+		if index.SearchDefinition != nil {
+			search.Write(index.SearchDefinition)
+			search.WriteString("\n")
+			continue
+		}
+		for _, note := range index.Notes {
+			// the notes are written as comments, so the file can still be run as it is
+			ddl.WriteString("/* " + strings.ReplaceAll(note, "*/", "* /") + " */\n")
+		}
+		ddl.WriteString(index.Query)
+		ddl.WriteString(";\n")
+	}
+	ddl.WriteString(c.buildDeferredIndexesQuery())
+	ddl.WriteString("\n")
+
+	ddlFileMu.Lock()
+	defer ddlFileMu.Unlock()
+	if err := appendFile(c.indexDDLFile, ddl.String()); err != nil {
+		return err
+	}
+	zap.S().Infof("index definitions of %s.%s.%s written to %s", c.bucket, c.scope, c.collection, c.indexDDLFile)
+	if search.Len() > 0 {
+		searchFile := c.indexDDLFile + SearchDDLFileSuffix
+		if err := appendFile(searchFile, search.String()); err != nil {
+			return err
+		}
+		zap.S().Infof("search index definitions of %s.%s.%s written to %s", c.bucket, c.scope, c.collection,
+			searchFile)
+	}
+	return nil
+}
+
+func appendFile(name string, content string) error {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening the index ddl file: %w", err)
+	}
+	_, err = f.WriteString(content)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing the index ddl file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package couchbase
+
+import "strings"
+
+// maxNameLength is the maximum length of a couchbase scope or collection name.
+const maxNameLength = 251
+
+// SanitizeName turns name into a valid couchbase scope or collection name. The characters other than letters, digits,
+// '_', '-' and '%' are replaced by '_', and the leading '_' and '%' characters, that are reserved for system
+// scopes and collections, are removed.
+func SanitizeName(name string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '%':
+			return r
+		}
+		return '_'
+	}, name)
+	sanitized = strings.TrimLeft(sanitized, "_%")
+	if sanitized == "" {
+		sanitized = "collection"
+	}
+	if len(sanitized) > maxNameLength {
+		sanitized = sanitized[:maxNameLength]
+	}
+	return sanitized
+}
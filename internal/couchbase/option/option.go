@@ -1,5 +1,12 @@
 package option
 
+const (
+	// TransactionalBatch writes each batch inside its own transaction
+	TransactionalBatch = "batch"
+	// TransactionalRun writes the whole run inside a single transaction
+	TransactionalRun = "run"
+)
+
 type Options struct {
 	Cluster string
 	*Auth
@@ -9,6 +16,15 @@ type Options struct {
 	KeepPrimaryKey  bool
 	HashDocumentKey string
 	BatchSize       int
+	// BinaryThreshold is the minimum size in bytes of a binary field to be stored as a separate binary document.
+	// A zero value keeps binary fields inline.
+	BinaryThreshold int
+	// Transactional is one of TransactionalBatch or TransactionalRun, an empty value disables transactions.
+	Transactional string
+	// TransactionLimit is the maximum number of documents of a TransactionalRun migration.
+	TransactionLimit int
+	// IndexDDLFile is the file the index definitions are appended to instead of being created, when not empty.
+	IndexDDLFile string
 }
 
 type Auth struct {
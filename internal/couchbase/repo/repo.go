@@ -2,9 +2,12 @@ package repo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/couchbase/gocb/v2"
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
 	"github.com/couchbaselabs/cbmigrate/internal/db/couchbase"
+	"sync"
 	"time"
 )
 
@@ -16,10 +19,15 @@ type IRepo interface {
 	CreateScope(name string) error
 	CreateCollection(scope, name string) error
 	UpsertData(scope, collection string, docs []gocb.BulkOp) error
+	UpsertBinaryData(scope, collection string, docs []gocb.BulkOp) error
+	UpsertDataInTransaction(scope, collection string, docs []gocb.BulkOp) error
+	GetDocument(scope, collection, id string) (map[string]interface{}, error)
 	CreateIndex(query string) error
+	CreateSearchIndex(scope string, definition []byte) error
 }
 
 type Repo struct {
+	mu sync.Mutex
 	db *couchbase.DB
 }
 
@@ -29,7 +37,13 @@ func NewRepo() IRepo {
 	}
 }
 
+// Init connects to the cluster. The repo can be shared by several destinations, so it only connects once.
 func (r *Repo) Init(uri string, opts *option.Options) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db.Cluster != nil {
+		return nil
+	}
 	return r.db.Init(uri, opts)
 }
 
@@ -61,6 +75,65 @@ func (r *Repo) UpsertData(scope, collection string, docs []gocb.BulkOp) error {
 	return col.Do(docs, nil)
 }
 
+// UpsertBinaryData writes the docs with the raw binary transcoder, so the values must be []byte.
+func (r *Repo) UpsertBinaryData(scope, collection string, docs []gocb.BulkOp) error {
+	col := r.db.Scope(scope).Collection(collection)
+	return col.Do(docs, &gocb.BulkOpOptions{Transcoder: gocb.NewRawBinaryTranscoder()})
+}
+
+// GetDocument returns the JSON document, or nil when it does not exist.
+func (r *Repo) GetDocument(scope, collection, id string) (map[string]interface{}, error) {
+	res, err := r.db.Scope(scope).Collection(collection).Get(id, nil)
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err = res.Content(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// UpsertDataInTransaction writes (or removes) the docs inside a single distributed transaction. When any of the writes fails the
+// transaction is rolled back, and none of the docs are written.
+func (r *Repo) UpsertDataInTransaction(scope, collection string, docs []gocb.BulkOp) error {
+	col := r.db.Scope(scope).Collection(collection)
+	_, err := r.db.Transactions().Run(func(ctx *gocb.TransactionAttemptContext) error {
+		for _, op := range docs {
+			var err error
+			switch o := op.(type) {
+			case *gocb.UpsertOp:
+				// transactions have no upsert, so the document is replaced when it exists and inserted otherwise
+				var doc *gocb.TransactionGetResult
+				doc, err = ctx.Get(col, o.ID)
+				switch {
+				case errors.Is(err, gocb.ErrDocumentNotFound):
+					_, err = ctx.Insert(col, o.ID, o.Value)
+				case err == nil:
+					_, err = ctx.Replace(doc, o.Value)
+				}
+			case *gocb.RemoveOp:
+				var doc *gocb.TransactionGetResult
+				doc, err = ctx.Get(col, o.ID)
+				switch {
+				case errors.Is(err, gocb.ErrDocumentNotFound):
+					err = nil
+				case err == nil:
+					err = ctx.Remove(doc)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil)
+	return err
+}
+
 func (r *Repo) CreateIndex(query string) error {
 	_, err := r.db.Query(query, &gocb.QueryOptions{})
 	if err != nil {
@@ -68,3 +141,12 @@ func (r *Repo) CreateIndex(query string) error {
 	}
 	return nil
 }
+
+// CreateSearchIndex creates (or updates) the search index of the JSON definition in the scope.
+func (r *Repo) CreateSearchIndex(scope string, definition []byte) error {
+	var index gocb.SearchIndex
+	if err := json.Unmarshal(definition, &index); err != nil {
+		return err
+	}
+	return r.db.Scope(scope).SearchIndexes().UpsertIndex(index, nil)
+}
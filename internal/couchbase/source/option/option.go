@@ -0,0 +1,23 @@
+package option
+
+import (
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+)
+
+const (
+	ScanModeRangeScan = "range-scan"
+	ScanModeQuery     = "query"
+)
+
+type Options struct {
+	// connection and keyspace of the source collection
+	*option.Options
+	// ScanMode is either ScanModeRangeScan (KV range scan) or ScanModeQuery (N1QL cursor)
+	ScanMode string
+	// IncludeSourceKey generates the key of every streamed document in the source, as the source document key is
+	// referenced by the generator key as %meta().id%, and is not an attribute of the document
+	IncludeSourceKey bool
+	// KeyHash is the algorithm the generated keys are hashed with, see --hash-document-key
+	KeyHash     string
+	CopyIndexes bool
+}
@@ -0,0 +1,170 @@
+package repo
+
+//go:generate mockgen -source=repo.go -destination=../../../../testhelper/mock/cb_source_repo.go -package=mock -mock_names=IRepo=MockCouchbaseSourceIRepo,ICursor=MockCouchbaseSourceICursor IRepo ICursor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	"github.com/couchbaselabs/cbmigrate/internal/db/couchbase"
+	"time"
+)
+
+type IRepo interface {
+	Init(uri string, opts *option.Options) error
+	Scan(ctx context.Context, scope, collection string) (ICursor, error)
+	Query(ctx context.Context, scope, collection string) (ICursor, error)
+	GetIndexes(scope, collection string) ([]gocb.QueryIndex, error)
+}
+
+// ICursor iterates over the documents of the source collection.
+type ICursor interface {
+	Next() bool
+	// Decode decodes the current document
+	Decode() (Document, error)
+	Err() error
+	Close() error
+}
+
+// Document is a document of the source collection. Content is the value of the JSON object documents, and Binary the
+// value of the binary documents. The other documents (JSON arrays, strings, numbers...) have neither. Expiry is zero
+// when the document never expires.
+type Document struct {
+	ID      string
+	Content map[string]interface{}
+	Binary  []byte
+	Expiry  time.Time
+}
+
+// setValue sets the value of the document decoded by the legacy transcoder.
+func (d *Document) setValue(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		d.Content = v
+	case []byte:
+		d.Binary = v
+	}
+}
+
+type Repo struct {
+	db *couchbase.DB
+}
+
+func NewRepo() IRepo {
+	return &Repo{
+		db: new(couchbase.DB),
+	}
+}
+
+func (r *Repo) Init(uri string, opts *option.Options) error {
+	return r.db.Init(uri, opts)
+}
+
+func (r *Repo) Scan(ctx context.Context, scope, collection string) (ICursor, error) {
+	col := r.db.Scope(scope).Collection(collection)
+	// the legacy transcoder decodes the binary documents as well as the JSON ones
+	result, err := col.Scan(gocb.RangeScan{}, &gocb.ScanOptions{Context: ctx, Transcoder: gocb.NewLegacyTranscoder()})
+	if err != nil {
+		return nil, err
+	}
+	return &ScanCursor{result: result}, nil
+}
+
+func (r *Repo) Query(ctx context.Context, scope, collection string) (ICursor, error) {
+	query := fmt.Sprintf("SELECT META(d).id AS id, META(d).type AS type, META(d).expiration AS expiration, d AS doc "+
+		"FROM `%s`.`%s`.`%s` AS d",
+		r.db.Bucket.Name(), scope, collection)
+	result, err := r.db.Query(query, &gocb.QueryOptions{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	return &QueryCursor{ctx: ctx, col: r.db.Scope(scope).Collection(collection), result: result}, nil
+}
+
+func (r *Repo) GetIndexes(scope, collection string) ([]gocb.QueryIndex, error) {
+	col := r.db.Scope(scope).Collection(collection)
+	return col.QueryIndexes().GetAllIndexes(nil)
+}
+
+type ScanCursor struct {
+	result  *gocb.ScanResult
+	current *gocb.ScanResultItem
+}
+
+func (c *ScanCursor) Next() bool {
+	c.current = c.result.Next()
+	return c.current != nil
+}
+
+func (c *ScanCursor) Decode() (Document, error) {
+	doc := Document{ID: c.current.ID(), Expiry: c.current.ExpiryTime()}
+	var value interface{}
+	if err := c.current.Content(&value); err != nil {
+		return doc, err
+	}
+	doc.setValue(value)
+	return doc, nil
+}
+
+func (c *ScanCursor) Err() error {
+	return c.result.Err()
+}
+
+func (c *ScanCursor) Close() error {
+	return c.result.Close()
+}
+
+type queryRow struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Expiration is the unix time the document expires at, 0 when it never expires
+	Expiration int64       `json:"expiration"`
+	Doc        interface{} `json:"doc"`
+}
+
+// QueryCursor reads the documents with a N1QL cursor, the binary documents are read with a get as N1QL does not return
+// their value.
+type QueryCursor struct {
+	ctx    context.Context
+	col    *gocb.Collection
+	result *gocb.QueryResult
+}
+
+func (c *QueryCursor) Next() bool {
+	return c.result.Next()
+}
+
+func (c *QueryCursor) Decode() (Document, error) {
+	var row queryRow
+	if err := c.result.Row(&row); err != nil {
+		return Document{}, err
+	}
+	doc := Document{ID: row.ID}
+	if row.Expiration > 0 {
+		doc.Expiry = time.Unix(row.Expiration, 0)
+	}
+	if row.Type != "binary" {
+		doc.setValue(row.Doc)
+		return doc, nil
+	}
+	result, err := c.col.Get(row.ID, &gocb.GetOptions{Context: c.ctx, Transcoder: gocb.NewRawBinaryTranscoder()})
+	if errors.Is(err, gocb.ErrDocumentNotFound) {
+		// removed since the query read it
+		return doc, nil
+	}
+	if err != nil {
+		return doc, err
+	}
+	doc.Binary = []byte{}
+	return doc, result.Content(&doc.Binary)
+}
+
+func (c *QueryCursor) Err() error {
+	return c.result.Err()
+}
+
+func (c *QueryCursor) Close() error {
+	return c.result.Close()
+}
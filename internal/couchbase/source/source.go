@@ -0,0 +1,179 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"github.com/couchbase/gocb/v2"
+	"strings"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source/option"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source/repo"
+	"github.com/couchbaselabs/cbmigrate/internal/errors"
+	"go.uber.org/zap"
+)
+
+// SourceKeyField is the field the generator key references the source document key with.
+const SourceKeyField = common.MetaDataID
+
+type Couchbase struct {
+	db               repo.IRepo
+	scope            string
+	collection       string
+	scanMode         string
+	includeSourceKey bool
+	keyHash          string
+	documentKey      common.ICBDocumentKey
+}
+
+func NewCouchbase(db repo.IRepo) common.ISource[option.Options] {
+	return &Couchbase{
+		db: db,
+	}
+}
+
+func (c *Couchbase) Init(opts *option.Options, documentKey common.ICBDocumentKey) error {
+	c.scope = opts.Scope
+	c.collection = opts.Collection
+	c.scanMode = opts.ScanMode
+	c.includeSourceKey = opts.IncludeSourceKey
+	c.keyHash = opts.KeyHash
+	c.documentKey = documentKey
+	err := c.db.Init(opts.Cluster, opts.Options)
+	if err != nil {
+		return err
+	}
+	if c.includeSourceKey {
+		// note: the generator key will replace the documentKey settings if present
+		c.documentKey.Set([]common.DocumentKeyPart{{Value: SourceKeyField, Kind: common.DkField}})
+	}
+	return nil
+}
+
+func (c *Couchbase) StreamData(ctx context.Context, mChan chan map[string]interface{}) error {
+	defer close(mChan)
+
+	var cursor repo.ICursor
+	var err error
+	switch c.scanMode {
+	case option.ScanModeQuery:
+		cursor, err = c.db.Query(ctx, c.scope, c.collection)
+	default:
+		cursor, err = c.db.Scan(ctx, c.scope, c.collection)
+	}
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+
+	skipped := 0
+	defer func() {
+		if skipped > 0 {
+			zap.S().Warnf("%d documents of %s.%s are not migrated, they are not JSON objects, or binary documents "+
+				"whose key is not generated from the source key", skipped, c.scope, c.collection)
+		}
+	}()
+	for cursor.Next() {
+		doc, err := cursor.Decode()
+		if err != nil {
+			return fmt.Errorf("error decoding the document %s: %w", doc.ID, err)
+		}
+		data := doc.Content
+		switch {
+		case data != nil:
+			if c.includeSourceKey {
+				if err = c.setKey(data, doc.ID); err != nil {
+					return err
+				}
+			}
+		case doc.Binary != nil && c.includeSourceKey:
+			// the binary documents are copied as they are
+			data = map[string]interface{}{common.BinaryField: common.Binary{Data: doc.Binary}}
+			if err = c.setKey(data, doc.ID); err != nil {
+				return err
+			}
+		default:
+			skipped++
+			zap.S().Debugf("document %s of %s.%s is not migrated", doc.ID, c.scope, c.collection)
+			continue
+		}
+		if !doc.Expiry.IsZero() {
+			data[common.ExpiryField] = doc.Expiry
+		}
+		select {
+		case mChan <- data:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return cursor.Err()
+}
+
+// setKey generates the key of the document from its source key and its fields, and passes it to the destination as
+// common.KeyField, so the source key is not written as an attribute of the document.
+func (c *Couchbase) setKey(data map[string]interface{}, id string) error {
+	data[SourceKeyField] = id
+	key := common.GenerateKey(c.documentKey.GetKey(), data)
+	delete(data, SourceKeyField)
+	if c.keyHash != "" {
+		hashed, err := common.ComputeHash([]byte(key), c.keyHash)
+		if err != nil {
+			return err
+		}
+		key = hashed
+	}
+	data[common.KeyField] = key
+	return nil
+}
+
+func (c *Couchbase) GetCouchbaseIndexesQuery(bucket string, scope string, collection string) ([]common.Index, error) {
+	indexes, err := c.db.GetIndexes(c.scope, c.collection)
+	if err != nil {
+		return nil, err
+	}
+	keyspace := fmt.Sprintf("`%s`.`%s`.`%s`", bucket, scope, collection)
+	isPrimaryIndexPresent := false
+	var cbIndexes []common.Index
+	for _, index := range indexes {
+		cbIndex := common.Index{
+			Name: index.Name,
+		}
+		switch {
+		case index.Type != gocb.QueryIndexTypeGsi:
+			cbIndex.Error = errors.NewCouchbaseNotSupportedError(fmt.Sprintf("%s index not supported", index.Type))
+		case index.IsPrimary:
+			cbIndex.Query = fmt.Sprintf(
+				"CREATE PRIMARY INDEX `%s` on %s USING GSI WITH {\"defer_build\":true}", index.Name, keyspace)
+			isPrimaryIndexPresent = true
+		default:
+			cbIndex.Query = CreateIndexQuery(keyspace, index)
+		}
+		cbIndexes = append(cbIndexes, cbIndex)
+	}
+	if !isPrimaryIndexPresent {
+		uuid, _ := common.GenerateShortUUIDHex()
+		key := "primary-" + uuid
+		cbIndexes = append(cbIndexes, common.Index{
+			Name: key,
+			Query: fmt.Sprintf(
+				"CREATE PRIMARY INDEX `%s` on %s USING GSI WITH {\"defer_build\":true}", key, keyspace),
+		})
+	}
+	return cbIndexes, nil
+}
+
+// CreateIndexQuery rebuilds the create statement of a secondary GSI index for the target keyspace. The index keys,
+// partition and condition are taken verbatim from the index definition of the source collection.
+func CreateIndexQuery(keyspace string, index gocb.QueryIndex) string {
+	var query strings.Builder
+	query.WriteString(fmt.Sprintf("CREATE INDEX `%s` on %s (%s)", index.Name, keyspace,
+		strings.Join(index.IndexKey, ",")))
+	if index.Partition != "" {
+		query.WriteString(" PARTITION BY " + index.Partition)
+	}
+	if index.Condition != "" {
+		query.WriteString(" WHERE " + index.Condition)
+	}
+	query.WriteString(" USING GSI WITH {\"defer_build\":true}")
+	return query.String()
+}
@@ -0,0 +1,191 @@
+package source_test
+
+import (
+	"context"
+	"errors"
+	"github.com/couchbase/gocb/v2"
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	cOpts "github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source"
+	sOpts "github.com/couchbaselabs/cbmigrate/internal/couchbase/source/option"
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/source/repo"
+	mocktest "github.com/couchbaselabs/cbmigrate/testhelper/mock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"time"
+)
+
+var _ = Describe("couchbase source service", func() {
+	var (
+		ctrl          *gomock.Controller
+		db            *mocktest.MockCouchbaseSourceIRepo
+		cursor        *mocktest.MockCouchbaseSourceICursor
+		sourceService common.ISource[sOpts.Options]
+		docKey        common.ICBDocumentKey
+	)
+	opts := &sOpts.Options{
+		Options: &cOpts.Options{
+			Cluster:   "cluster-url",
+			NameSpace: &cOpts.NameSpace{Bucket: "src_bucket", Scope: "src_scope", Collection: "src_col"},
+		},
+		ScanMode:         sOpts.ScanModeRangeScan,
+		IncludeSourceKey: true,
+	}
+	testData := []map[string]interface{}{{"a": 1.0}, {"b": 1.0}}
+	testKeys := []string{"k1", "k2"}
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		db = mocktest.NewMockCouchbaseSourceIRepo(ctrl)
+		cursor = mocktest.NewMockCouchbaseSourceICursor(ctrl)
+		sourceService = source.NewCouchbase(db)
+		docKey = common.NewCBDocumentKey()
+	})
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+	Describe("test couchbase source streaming", func() {
+		Context("success", func() {
+			It("output data should be keyed by the source key", func() {
+				ctx := context.Background()
+				db.EXPECT().Init(opts.Cluster, opts.Options).Return(nil)
+				err := sourceService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+				Expect(docKey.GetNonCompoundPrimaryKeyOnly()).To(Equal(source.SourceKeyField))
+
+				db.EXPECT().Scan(ctx, "src_scope", "src_col").Return(cursor, nil)
+				n := -1
+				cursor.EXPECT().Next().Times(len(testData) + 1).DoAndReturn(func() bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode().Times(len(testData)).DoAndReturn(func() (repo.Document, error) {
+					doc := repo.Document{ID: testKeys[n], Content: map[string]interface{}{}}
+					for k, v := range testData[n] {
+						doc.Content[k] = v
+					}
+					return doc, nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close().Return(nil)
+
+				stream := make(chan map[string]interface{}, len(testData))
+				err = sourceService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var outputData []map[string]interface{}
+				for data := range stream {
+					outputData = append(outputData, data)
+				}
+				Expect(outputData).To(Equal([]map[string]interface{}{
+					{"a": 1.0, common.KeyField: "k1"},
+					{"b": 1.0, common.KeyField: "k2"},
+				}))
+			})
+			It("the generated and hashed key is set by the source, without the source key field", func() {
+				hopts := *opts
+				hopts.KeyHash = "sha256"
+				ctx := context.Background()
+				db.EXPECT().Init(hopts.Cluster, hopts.Options).Return(nil)
+				err := sourceService.Init(&hopts, docKey)
+				Expect(err).To(BeNil())
+				// the generator key is set by the destination
+				docKey.Set([]common.DocumentKeyPart{
+					{Value: source.SourceKeyField, Kind: common.DkField},
+					{Value: "a", Kind: common.DkField},
+				})
+
+				db.EXPECT().Scan(ctx, "src_scope", "src_col").Return(cursor, nil)
+				gomock.InOrder(
+					cursor.EXPECT().Next().Return(true),
+					cursor.EXPECT().Next().Return(false),
+				)
+				cursor.EXPECT().Decode().Return(repo.Document{ID: "k1", Content: map[string]interface{}{"a": "x"}}, nil)
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close().Return(nil)
+
+				stream := make(chan map[string]interface{}, 1)
+				err = sourceService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				key, err := common.ComputeHash([]byte("k1::x"), "sha256")
+				Expect(err).To(BeNil())
+				Expect(<-stream).To(Equal(map[string]interface{}{"a": "x", common.KeyField: key}))
+			})
+			It("binary documents are copied as they are, the other non object documents are skipped, and the expiry is kept", func() {
+				ctx := context.Background()
+				db.EXPECT().Init(opts.Cluster, opts.Options).Return(nil)
+				err := sourceService.Init(opts, docKey)
+				Expect(err).To(BeNil())
+
+				db.EXPECT().Scan(ctx, "src_scope", "src_col").Return(cursor, nil)
+				expiry := time.Now().Add(time.Hour)
+				docs := []repo.Document{
+					{ID: "k1", Binary: []byte{0xca, 0xfe}},
+					{ID: "k2"},
+					{ID: "k3", Content: map[string]interface{}{"a": 1.0}, Expiry: expiry},
+				}
+				n := -1
+				cursor.EXPECT().Next().Times(len(docs) + 1).DoAndReturn(func() bool {
+					n++
+					return n < len(docs)
+				})
+				cursor.EXPECT().Decode().Times(len(docs)).DoAndReturn(func() (repo.Document, error) {
+					return docs[n], nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close().Return(nil)
+
+				stream := make(chan map[string]interface{}, len(docs))
+				err = sourceService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var outputData []map[string]interface{}
+				for data := range stream {
+					outputData = append(outputData, data)
+				}
+				Expect(outputData).To(Equal([]map[string]interface{}{
+					{common.KeyField: "k1", common.BinaryField: common.Binary{Data: []byte{0xca, 0xfe}}},
+					{"a": 1.0, common.KeyField: "k3", common.ExpiryField: expiry},
+				}))
+			})
+		})
+		Context("failure", func() {
+			It("error in opening the query cursor", func() {
+				qopts := *opts
+				qopts.ScanMode = sOpts.ScanModeQuery
+				queryError := errors.New("no index available on keyspace")
+				ctx := context.Background()
+				db.EXPECT().Init(qopts.Cluster, qopts.Options).Return(nil)
+				err := sourceService.Init(&qopts, docKey)
+				Expect(err).To(BeNil())
+				db.EXPECT().Query(ctx, "src_scope", "src_col").Return(nil, queryError)
+				err = sourceService.StreamData(ctx, make(chan map[string]interface{}))
+				Expect(err).To(Equal(queryError))
+			})
+		})
+	})
+	Describe("test couchbase source index translation", func() {
+		It("gsi indexes are recreated on the target keyspace", func() {
+			db.EXPECT().Init(opts.Cluster, opts.Options).Return(nil)
+			err := sourceService.Init(opts, docKey)
+			Expect(err).To(BeNil())
+			db.EXPECT().GetIndexes("src_scope", "src_col").Return([]gocb.QueryIndex{
+				{Name: "#primary", IsPrimary: true, Type: gocb.QueryIndexTypeGsi},
+				{
+					Name:      "idx_type",
+					Type:      gocb.QueryIndexTypeGsi,
+					IndexKey:  []string{"`type`", "`name`"},
+					Partition: "HASH(`type`)",
+					Condition: "(`active` = true)",
+				},
+				{Name: "idx_view", Type: gocb.QueryIndexTypeView},
+			}, nil)
+			indexes, err := sourceService.GetCouchbaseIndexesQuery("b", "s", "c")
+			Expect(err).To(BeNil())
+			Expect(indexes).To(HaveLen(3))
+			Expect(indexes[0].Query).To(Equal("CREATE PRIMARY INDEX `#primary` on `b`.`s`.`c` USING GSI WITH {\"defer_build\":true}"))
+			Expect(indexes[1].Query).To(Equal("CREATE INDEX `idx_type` on `b`.`s`.`c` (`type`,`name`) PARTITION BY HASH(`type`) WHERE (`active` = true) USING GSI WITH {\"defer_build\":true}"))
+			Expect(indexes[2].Error).NotTo(BeNil())
+			Expect(strings.Contains(indexes[2].Error.Error(), "not supported")).To(BeTrue())
+		})
+	})
+})
@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
 	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
 	"net/http"
 	"os"
@@ -17,6 +18,8 @@ import (
 
 type DB struct {
 	*dynamodb.Client
+	// Streams reads the stream of the tables, from the same endpoint
+	Streams *dynamodbstreams.Client
 }
 
 func (d *DB) Init(opts *option.Options) error {
@@ -62,5 +65,13 @@ func (d *DB) Init(opts *option.Options) error {
 			options.EndpointOptions.DisableHTTPS = true
 		}
 	})
+	d.Streams = dynamodbstreams.NewFromConfig(cfg, func(options *dynamodbstreams.Options) {
+		if opts.EndpointUrl != "" {
+			options.BaseEndpoint = aws.String(opts.EndpointUrl)
+		}
+		if opts.NoSSLVerify {
+			options.EndpointOptions.DisableHTTPS = true
+		}
+	})
 	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/couchbaselabs/cbmigrate/internal/common"
 	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
@@ -21,6 +22,18 @@ type DynamoDB struct {
 	documentKey common.ICBDocumentKey
 	segments    int
 	limit       int
+	tableName   string
+	primaryKey  []string
+	// partitionKeys are queried instead of scanning the table, by segments concurrent queries
+	partitionKeys []string
+
+	follow         bool
+	checkpointFile string
+	// checkpoint is the position in the stream, it is loaded from the checkpoint file when it exists (resumed), and
+	// the scan is then skipped
+	checkpoint *checkpoint
+	resumed    bool
+	streamArn  string
 }
 
 func NewDynamoDB(db repo.IRepo) common.ISource[option.Options] {
@@ -33,6 +46,15 @@ func (d *DynamoDB) Init(opts *option.Options, documentKey common.ICBDocumentKey)
 	d.documentKey = documentKey
 	d.segments = opts.Segments
 	d.limit = opts.Limit
+	d.tableName = opts.TableName
+	d.partitionKeys = opts.PartitionKeys
+	d.follow = opts.Follow
+	d.checkpointFile = opts.CheckpointFile
+	if d.follow {
+		if err := d.loadCheckpoint(); err != nil {
+			return err
+		}
+	}
 	err := d.db.Init(opts)
 	if err != nil {
 		return err
@@ -42,6 +64,12 @@ func (d *DynamoDB) Init(opts *option.Options, documentKey common.ICBDocumentKey)
 	if err != nil {
 		return err
 	}
+	d.primaryKey = index.Keys
+	if d.follow {
+		if err = d.initStream(context.Background()); err != nil {
+			return err
+		}
+	}
 	var documentKeyParts []common.DocumentKeyPart
 	for _, k := range index.Keys {
 		documentKeyParts = append(documentKeyParts, common.DocumentKeyPart{
@@ -56,16 +84,37 @@ func (d *DynamoDB) Init(opts *option.Options, documentKey common.ICBDocumentKey)
 
 func (d *DynamoDB) StreamData(ctx context.Context, mChan chan map[string]interface{}) error {
 	defer close(mChan)
+	if d.follow {
+		if d.resumed {
+			zap.S().Infof("resuming the stream from %s, the scan is skipped", d.checkpointFile)
+			return nil
+		}
+		// the changes made from the start of the scan are applied once it is done
+		d.checkpoint = &checkpoint{
+			StreamArn: d.streamArn,
+			Since:     time.Now().Add(-streamClockSkew),
+			Shards:    make(map[string]*shardCheckpoint),
+		}
+	}
 
-	errChan := make(chan error, d.segments)
+	workers := d.segments
+	if len(d.partitionKeys) > 0 {
+		workers = min(d.segments, len(d.partitionKeys))
+	}
+	errChan := make(chan error, workers)
 	var wg sync.WaitGroup
 	dCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	for segment := 0; segment < d.segments; segment++ {
+	for segment := 0; segment < workers; segment++ {
 		wg.Add(1)
 		go func(segment int) {
 			defer wg.Done()
-			err := d.parallelScanSegment(dCtx, segment, mChan)
+			var err error
+			if len(d.partitionKeys) > 0 {
+				err = d.queryPartitionKeys(dCtx, segment, workers, mChan)
+			} else {
+				err = d.parallelScanSegment(dCtx, segment, mChan)
+			}
 			if err != nil {
 				sync.OnceFunc(func() {
 					cancel()
@@ -91,6 +140,21 @@ func (d *DynamoDB) StreamData(ctx context.Context, mChan chan map[string]interfa
 
 func (d *DynamoDB) parallelScanSegment(ctx context.Context, segment int, mChan chan map[string]interface{}) error {
 	paginator := d.db.NewPaginator(int32(segment), int32(d.segments), int32(d.limit))
+	return readPages(ctx, paginator, fmt.Sprintf("segment %d", segment), mChan)
+}
+
+// queryPartitionKeys queries the items of every workers-th partition key value from the worker-th.
+func (d *DynamoDB) queryPartitionKeys(ctx context.Context, worker int, workers int, mChan chan map[string]interface{}) error {
+	for i := worker; i < len(d.partitionKeys); i += workers {
+		paginator := d.db.NewQueryPaginator(d.partitionKeys[i], int32(d.limit))
+		if err := readPages(ctx, paginator, "partition "+d.partitionKeys[i], mChan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPages(ctx context.Context, paginator repo.IPaginator, source string, mChan chan map[string]interface{}) error {
 	for paginator.HasMorePages() {
 		output, err := paginator.NextPage(ctx)
 		if err != nil {
@@ -99,7 +163,7 @@ func (d *DynamoDB) parallelScanSegment(ctx context.Context, segment int, mChan c
 		var records []map[string]interface{}
 		err = attributevalue.UnmarshalListOfMaps(output.Items, &records)
 		if err != nil {
-			return fmt.Errorf("error unmarshalling records in segment %d: %w", segment, err)
+			return fmt.Errorf("error unmarshalling records in %s: %w", source, err)
 		}
 		for _, record := range records {
 			mChan <- record
@@ -109,6 +173,10 @@ func (d *DynamoDB) parallelScanSegment(ctx context.Context, segment int, mChan c
 }
 
 func (d *DynamoDB) GetCouchbaseIndexesQuery(bucket string, scope string, collection string) ([]common.Index, error) {
+	if d.resumed {
+		// the indexes have been created by the run that did the scan
+		return nil, nil
+	}
 	indexes, err := d.db.GetIndexes(context.Background())
 	if err != nil {
 		return nil, err
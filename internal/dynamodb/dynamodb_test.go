@@ -2,7 +2,12 @@ package dynamodb_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	dynamodb2 "github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -88,6 +93,37 @@ var _ = Describe("DynamoDB service", func() {
 				Expect(err).To(BeNil())
 				Ω(outputData).Should(Equal(testData))
 			})
+			It("the items of the partition keys are queried instead of scanning the table", func() {
+				queryOpts := &dOpts.Options{TableName: "test1", Segments: 2, PartitionKeys: []string{"p1", "p2", "p3"}}
+				db.EXPECT().Init(queryOpts).Return(nil)
+				db.EXPECT().GetPrimaryIndex(context.Background()).Return(repoIndexes[0], nil)
+				Expect(dynamodbService.Init(queryOpts, common.NewCBDocumentKey())).To(Succeed())
+				for i, pk := range queryOpts.PartitionKeys {
+					page := mocktest.NewMockDynamoDbIPaginator(ctrl)
+					db.EXPECT().NewQueryPaginator(pk, int32(0)).Return(page)
+					gomock.InOrder(
+						page.EXPECT().HasMorePages().Return(true),
+						page.EXPECT().NextPage(gomock.Any()).DoAndReturn(func(ctx context.Context, optFns ...func(*dynamodb2.Options)) (*dynamodb2.ScanOutput, error) {
+							item, err := attributevalue.MarshalMap(&testData[i])
+							return &dynamodb2.ScanOutput{Items: []map[string]types.AttributeValue{item}}, err
+						}),
+						page.EXPECT().HasMorePages().Return(false),
+					)
+				}
+				stream := make(chan map[string]interface{})
+				var outputData []map[string]interface{}
+				doneRoutine := make(chan bool)
+				go func() {
+					for data := range stream {
+						outputData = append(outputData, data)
+					}
+					doneRoutine <- true
+				}()
+				err := dynamodbService.StreamData(context.Background(), stream)
+				<-doneRoutine
+				Expect(err).To(BeNil())
+				Ω(outputData).Should(ConsistOf(testData))
+			})
 		})
 		Context("failure", func() {
 			It("error in connection initialization", func() {
@@ -141,4 +177,121 @@ var _ = Describe("DynamoDB service", func() {
 			})
 		})
 	})
+	Describe("test DynamoDB follow", func() {
+		var (
+			ctrl            *gomock.Controller
+			db              *mocktest.MockDynamoDbIRepo
+			dynamodbService common.ISource[dOpts.Options]
+			opts            *dOpts.Options
+		)
+		stream := repo.Stream{
+			Arn:      "arn1",
+			ViewType: "NEW_IMAGE",
+			Shards:   []repo.Shard{{ID: "s1"}, {ID: "s2", ParentID: "s1"}},
+		}
+		attributes := func(item map[string]interface{}) map[string]types.AttributeValue {
+			av, err := attributevalue.MarshalMap(item)
+			Expect(err).To(BeNil())
+			return av
+		}
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			db = mocktest.NewMockDynamoDbIRepo(ctrl)
+			dynamodbService = dynamodb.NewDynamoDB(db)
+			opts = &dOpts.Options{TableName: "test1", Segments: 1, Follow: true,
+				CheckpointFile: filepath.Join(GinkgoT().TempDir(), "test1.stream-checkpoint")}
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		It("changes are applied after the scan and the checkpoint is persisted", func() {
+			docKey := common.NewCBDocumentKey()
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetPrimaryIndex(context.Background()).Return(repoIndexes[0], nil)
+			db.EXPECT().GetStream(gomock.Any()).Times(3).Return(stream, nil)
+			err := dynamodbService.Init(opts, docKey)
+			Expect(err).To(BeNil())
+
+			paginator := mocktest.NewMockDynamoDbIPaginator(ctrl)
+			db.EXPECT().NewPaginator(int32(0), int32(1), int32(0)).Return(paginator)
+			paginator.EXPECT().HasMorePages().Return(false)
+			mChan := make(chan map[string]interface{}, 1)
+			Expect(dynamodbService.StreamData(context.Background(), mChan)).To(Succeed())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			now := time.Now()
+			gomock.InOrder(
+				db.EXPECT().GetShardIterator(gomock.Any(), "arn1", "s1", "").Return("it1", nil),
+				db.EXPECT().GetRecords(gomock.Any(), "it1").Return([]repo.StreamRecord{
+					// made before the scan, the scanned item holds it
+					{EventName: "INSERT", SequenceNumber: "1", CreatedAt: now.Add(-time.Hour),
+						NewImage: attributes(map[string]interface{}{"id": "a", "v": 1.0})},
+					{EventName: "INSERT", SequenceNumber: "2", CreatedAt: now,
+						NewImage: attributes(map[string]interface{}{"id": "b", "v": 1.0})},
+					{EventName: "REMOVE", SequenceNumber: "3", CreatedAt: now,
+						Keys: attributes(map[string]interface{}{"id": "a"})},
+				}, "", nil),
+				db.EXPECT().GetShardIterator(gomock.Any(), "arn1", "s2", "").Return("it2", nil),
+				db.EXPECT().GetRecords(gomock.Any(), "it2").Return([]repo.StreamRecord{
+					{EventName: "MODIFY", SequenceNumber: "4", CreatedAt: now,
+						NewImage: attributes(map[string]interface{}{"id": "b", "v": 2.0})},
+				}, "it3", nil),
+				db.EXPECT().GetRecords(gomock.Any(), "it3").DoAndReturn(func(ctx context.Context, iterator string) ([]repo.StreamRecord, string, error) {
+					cancel()
+					return nil, "it4", nil
+				}),
+			)
+			var applied []map[string]interface{}
+			err = dynamodbService.(common.IFollowSource).Follow(ctx, func(data map[string]interface{}) error {
+				applied = append(applied, data)
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(applied).To(Equal([]map[string]interface{}{
+				{"id": "b", "v": 1.0},
+				{"id": "a", common.OperationField: common.OperationDelete},
+				{"id": "b", "v": 2.0},
+			}))
+			content, err := os.ReadFile(opts.CheckpointFile)
+			Expect(err).To(BeNil())
+			var checkpoint map[string]interface{}
+			Expect(json.Unmarshal(content, &checkpoint)).To(Succeed())
+			Expect(checkpoint["streamArn"]).To(Equal("arn1"))
+			Expect(checkpoint["shards"]).To(Equal(map[string]interface{}{
+				"s1": map[string]interface{}{"sequenceNumber": "3", "done": true},
+				"s2": map[string]interface{}{"sequenceNumber": "4"},
+			}))
+		})
+		It("the scan is skipped when a checkpoint is persisted", func() {
+			Expect(os.WriteFile(opts.CheckpointFile, []byte(`{"streamArn":"arn1","since":"2024-05-01T10:00:00Z",`+
+				`"shards":{"s1":{"sequenceNumber":"3"}}}`), 0o600)).To(Succeed())
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetPrimaryIndex(context.Background()).Return(repoIndexes[0], nil)
+			db.EXPECT().GetStream(gomock.Any()).Return(stream, nil)
+			err := dynamodbService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).To(BeNil())
+			mChan := make(chan map[string]interface{}, 1)
+			Expect(dynamodbService.StreamData(context.Background(), mChan)).To(Succeed())
+			Expect(mChan).To(BeClosed())
+			indexes, err := dynamodbService.GetCouchbaseIndexesQuery("bucket", "scope", "collection")
+			Expect(err).To(BeNil())
+			Expect(indexes).To(BeEmpty())
+		})
+		It("the stream of the table must hold the new images", func() {
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetPrimaryIndex(context.Background()).Return(repoIndexes[0], nil)
+			db.EXPECT().GetStream(gomock.Any()).Return(repo.Stream{Arn: "arn1", ViewType: "KEYS_ONLY"}, nil)
+			err := dynamodbService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).NotTo(BeNil())
+		})
+		It("the checkpoint must belong to the stream of the table", func() {
+			Expect(os.WriteFile(opts.CheckpointFile, []byte(`{"streamArn":"arn0","shards":{}}`), 0o600)).To(Succeed())
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetPrimaryIndex(context.Background()).Return(repoIndexes[0], nil)
+			db.EXPECT().GetStream(gomock.Any()).Return(stream, nil)
+			err := dynamodbService.Init(opts, common.NewCBDocumentKey())
+			Expect(err).NotTo(BeNil())
+		})
+	})
 })
@@ -0,0 +1,285 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"go.uber.org/zap"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/repo"
+)
+
+const (
+	// streamPollInterval is the wait before reading the shards again when they had no new record
+	streamPollInterval = time.Second
+	// shardRefreshInterval is how often the shards of the stream are listed, to find the new shards
+	shardRefreshInterval = 10 * time.Second
+	// streamClockSkew is the margin before the scan the records are applied from, as the time of the records is set
+	// by the clock of DynamoDB
+	streamClockSkew = time.Minute
+)
+
+// checkpoint is the position of the follower in the stream of the table, persisted to the checkpoint file.
+type checkpoint struct {
+	StreamArn string `json:"streamArn"`
+	// Since skips the records created before the scan of the initial load, their changes are in the scanned items
+	Since  time.Time                   `json:"since"`
+	Shards map[string]*shardCheckpoint `json:"shards"`
+}
+
+// shardCheckpoint is the sequence number of the last record applied from the shard, Done is set once a closed shard
+// has been read entirely.
+type shardCheckpoint struct {
+	SequenceNumber string `json:"sequenceNumber,omitempty"`
+	Done           bool   `json:"done,omitempty"`
+}
+
+func (d *DynamoDB) IsFollowing() bool {
+	return d.follow
+}
+
+// initStream checks that the table has a stream holding the new images of the items, and that the persisted
+// checkpoint belongs to it.
+func (d *DynamoDB) initStream(ctx context.Context) error {
+	stream, err := d.db.GetStream(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case stream.Arn == "":
+		return fmt.Errorf("the stream of the table %s is not enabled, enable it with the NEW_IMAGE or "+
+			"NEW_AND_OLD_IMAGES view type to follow the changes", d.tableName)
+	case stream.ViewType != "NEW_IMAGE" && stream.ViewType != "NEW_AND_OLD_IMAGES":
+		return fmt.Errorf("the stream of the table %s has the %s view type, the changes can only be followed with "+
+			"the NEW_IMAGE or NEW_AND_OLD_IMAGES view type", d.tableName, stream.ViewType)
+	case d.checkpoint != nil && d.checkpoint.StreamArn != stream.Arn:
+		return fmt.Errorf("the checkpoint file %s belongs to the stream %s and the table %s now has the stream %s, "+
+			"the changes made in between are lost: delete the checkpoint file to migrate the table again",
+			d.checkpointFile, d.checkpoint.StreamArn, d.tableName, stream.Arn)
+	}
+	d.streamArn = stream.Arn
+	return nil
+}
+
+// Follow applies the changes of the stream of the table until ctx is done. The shards are read in the order of their
+// lineage, a shard once its parent has been read entirely, so the changes of an item are applied in order. The
+// checkpoint is persisted after every batch of applied records, so that a restarted follower carries on where it
+// stopped.
+func (d *DynamoDB) Follow(ctx context.Context, apply func(data map[string]interface{}) error) error {
+	if err := d.checkKeyFromPrimaryKey(); err != nil {
+		return err
+	}
+	if err := d.saveCheckpoint(); err != nil {
+		return err
+	}
+	iterators := make(map[string]string)
+	var shards []repo.Shard
+	var refreshed time.Time
+	refresh := true
+	for ctx.Err() == nil {
+		if refresh || time.Since(refreshed) >= shardRefreshInterval {
+			stream, err := d.db.GetStream(ctx)
+			if err != nil {
+				return d.stopped(ctx, err)
+			}
+			shards = stream.Shards
+			d.pruneCheckpoint(shards)
+			refreshed = time.Now()
+			refresh = false
+		}
+		progressed := false
+		for _, shard := range shards {
+			if !d.isReadable(shard, shards) {
+				continue
+			}
+			n, done, err := d.readShard(ctx, shard.ID, iterators, apply)
+			if err != nil {
+				return d.stopped(ctx, err)
+			}
+			if n > 0 || done {
+				progressed = true
+				if err = d.saveCheckpoint(); err != nil {
+					return err
+				}
+			}
+			// the children of a closed shard are listed with the shards
+			refresh = refresh || done
+		}
+		if !progressed {
+			select {
+			case <-time.After(streamPollInterval):
+			case <-ctx.Done():
+			}
+		}
+	}
+	return d.stopped(ctx, nil)
+}
+
+// stopped returns err, or nil when ctx is done as the follower has been interrupted.
+func (d *DynamoDB) stopped(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		zap.S().Infof("stream reading stopped, it resumes from %s on the next run", d.checkpointFile)
+		return nil
+	}
+	return err
+}
+
+// isReadable reports whether the records of the shard can be applied: it has not been read entirely, and its parent
+// has been or is no longer in the stream.
+func (d *DynamoDB) isReadable(shard repo.Shard, shards []repo.Shard) bool {
+	if cp := d.checkpoint.Shards[shard.ID]; cp != nil && cp.Done {
+		return false
+	}
+	if shard.ParentID == "" {
+		return true
+	}
+	parent := d.checkpoint.Shards[shard.ParentID]
+	if parent != nil && parent.Done {
+		return true
+	}
+	return !slices.ContainsFunc(shards, func(s repo.Shard) bool { return s.ID == shard.ParentID })
+}
+
+// pruneCheckpoint removes the shards trimmed from the stream, the records are kept 24 hours.
+func (d *DynamoDB) pruneCheckpoint(shards []repo.Shard) {
+	for id, cp := range d.checkpoint.Shards {
+		if slices.ContainsFunc(shards, func(s repo.Shard) bool { return s.ID == id }) {
+			continue
+		}
+		if !cp.Done {
+			zap.S().Warnf("the shard %s has been trimmed from the stream before it was read entirely, changes made "+
+				"after the sequence number %s may be lost", id, cp.SequenceNumber)
+		}
+		delete(d.checkpoint.Shards, id)
+	}
+}
+
+// readShard applies the next records of the shard, and returns their number and whether the shard has been read
+// entirely.
+func (d *DynamoDB) readShard(ctx context.Context, shardID string, iterators map[string]string, apply func(data map[string]interface{}) error) (int, bool, error) {
+	cp := d.checkpoint.Shards[shardID]
+	if cp == nil {
+		cp = &shardCheckpoint{}
+		d.checkpoint.Shards[shardID] = cp
+	}
+	iterator := iterators[shardID]
+	if iterator == "" {
+		var err error
+		iterator, err = d.db.GetShardIterator(ctx, d.streamArn, shardID, cp.SequenceNumber)
+		if err != nil {
+			return 0, false, fmt.Errorf("error reading the shard %s: %w", shardID, err)
+		}
+	}
+	records, next, err := d.db.GetRecords(ctx, iterator)
+	if errors.Is(err, repo.ErrExpiredIterator) {
+		// a new iterator is taken from the checkpoint on the next read
+		delete(iterators, shardID)
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error reading the shard %s: %w", shardID, err)
+	}
+	for _, record := range records {
+		if !record.CreatedAt.Before(d.checkpoint.Since) {
+			data, err := recordDocument(record)
+			if err != nil {
+				return 0, false, err
+			}
+			if err = apply(data); err != nil {
+				return 0, false, fmt.Errorf("error applying the %s record %s: %w", record.EventName,
+					record.SequenceNumber, err)
+			}
+		}
+		cp.SequenceNumber = record.SequenceNumber
+	}
+	if next == "" {
+		delete(iterators, shardID)
+		cp.Done = true
+		return len(records), true, nil
+	}
+	iterators[shardID] = next
+	return len(records), false, nil
+}
+
+// recordDocument returns the item of an INSERT or MODIFY record, or the key of the item of a REMOVE record.
+func recordDocument(record repo.StreamRecord) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	switch record.EventName {
+	case "INSERT", "MODIFY":
+		if err := attributevalue.UnmarshalMap(record.NewImage, &data); err != nil {
+			return nil, fmt.Errorf("error unmarshalling the record %s: %w", record.SequenceNumber, err)
+		}
+	case "REMOVE":
+		if err := attributevalue.UnmarshalMap(record.Keys, &data); err != nil {
+			return nil, fmt.Errorf("error unmarshalling the record %s: %w", record.SequenceNumber, err)
+		}
+		data[common.OperationField] = common.OperationDelete
+	default:
+		return nil, fmt.Errorf("unknown event %s of the record %s", record.EventName, record.SequenceNumber)
+	}
+	return data, nil
+}
+
+// checkKeyFromPrimaryKey checks that the document key is generated from the primary key only, as a removed item is
+// only known by its primary key.
+func (d *DynamoDB) checkKeyFromPrimaryKey() error {
+	for _, part := range d.documentKey.GetKey() {
+		if part.Kind == common.DkUuid || (part.Kind == common.DkField && !slices.Contains(d.primaryKey, part.Value)) {
+			return fmt.Errorf("the changes can only be followed when the document key is generated from the "+
+				"primary key %v and static text", d.primaryKey)
+		}
+	}
+	return nil
+}
+
+func (d *DynamoDB) loadCheckpoint() error {
+	content, err := os.ReadFile(d.checkpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading the checkpoint file: %w", err)
+	}
+	var cp checkpoint
+	if err = json.Unmarshal(content, &cp); err != nil {
+		return fmt.Errorf("invalid checkpoint file %s: %w", d.checkpointFile, err)
+	}
+	if cp.Shards == nil {
+		cp.Shards = make(map[string]*shardCheckpoint)
+	}
+	d.checkpoint = &cp
+	d.resumed = true
+	return nil
+}
+
+// saveCheckpoint writes the checkpoint to a temporary file first, so that the checkpoint file is never left truncated.
+func (d *DynamoDB) saveCheckpoint() error {
+	content, err := json.Marshal(d.checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.checkpointFile), filepath.Base(d.checkpointFile)+".*")
+	if err != nil {
+		return fmt.Errorf("error saving the checkpoint: %w", err)
+	}
+	_, err = tmp.Write(content)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), d.checkpointFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error saving the checkpoint: %w", err)
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package option
 
+import "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
 type Options struct {
 	TableName   string
 	EndpointUrl string
@@ -11,4 +13,20 @@ type Options struct {
 	CABundle    string
 	Segments    int
 	Limit       int
+	// Follow applies the changes of the stream of the table after the scan, the position in the stream is persisted
+	// to CheckpointFile
+	Follow         bool
+	CheckpointFile string
+	// FilterExpression and ProjectionExpression are passed to the scan, or to the queries of PartitionKeys, with the
+	// placeholders they use
+	FilterExpression          string
+	ProjectionExpression      string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]types.AttributeValue
+	// PartitionKeys are the partition key values the items are queried for, instead of scanning the whole table
+	PartitionKeys []string
+	// ExportPath is a table export to S3 downloaded to a directory, read instead of scanning the table, with the keys
+	// of the table read from TableDescriptionFile
+	ExportPath           string
+	TableDescriptionFile string
 }
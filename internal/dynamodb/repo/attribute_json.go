@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// UnmarshalAttributeValues parses attribute values in the DynamoDB JSON format of the AWS CLI and of the table exports,
+// where every value is an object naming its type, as {":name": {"S": "text"}, ":count": {"N": "2"}}.
+func UnmarshalAttributeValues(data []byte) (map[string]types.AttributeValue, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]types.AttributeValue, len(raw))
+	for name, r := range raw {
+		value, err := UnmarshalAttributeValue(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// UnmarshalAttributeValue parses an attribute value in the DynamoDB JSON format.
+func UnmarshalAttributeValue(data []byte) (types.AttributeValue, error) {
+	var typed map[string]json.RawMessage
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, err
+	}
+	if len(typed) != 1 {
+		return nil, fmt.Errorf("an attribute value must name its single type, as {\"S\": \"text\"}, got %s", data)
+	}
+	for t, raw := range typed {
+		switch t {
+		case "S":
+			var v string
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberS{Value: v}, err
+		case "N":
+			var v string
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberN{Value: v}, err
+		case "B":
+			var v string
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+			b, err := decodeBinary(v)
+			return &types.AttributeValueMemberB{Value: b}, err
+		case "BOOL":
+			var v bool
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberBOOL{Value: v}, err
+		case "NULL":
+			var v bool
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberNULL{Value: v}, err
+		case "SS":
+			var v []string
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberSS{Value: v}, err
+		case "NS":
+			var v []string
+			err := json.Unmarshal(raw, &v)
+			return &types.AttributeValueMemberNS{Value: v}, err
+		case "BS":
+			var v []string
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+			bs := make([][]byte, len(v))
+			for i, s := range v {
+				b, err := decodeBinary(s)
+				if err != nil {
+					return nil, err
+				}
+				bs[i] = b
+			}
+			return &types.AttributeValueMemberBS{Value: bs}, nil
+		case "L":
+			var v []json.RawMessage
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+			list := make([]types.AttributeValue, len(v))
+			for i, r := range v {
+				value, err := UnmarshalAttributeValue(r)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = value
+			}
+			return &types.AttributeValueMemberL{Value: list}, nil
+		case "M":
+			m, err := UnmarshalAttributeValues(raw)
+			return &types.AttributeValueMemberM{Value: m}, err
+		default:
+			return nil, fmt.Errorf("unknown attribute value type %s", t)
+		}
+	}
+	return nil, nil
+}
+
+func decodeBinary(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,352 @@
+package repo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
+	"go.uber.org/zap"
+)
+
+// The output formats and the export type of the table exports to S3.
+const (
+	ExportFormatDynamoDBJSON = "DYNAMODB_JSON"
+	ExportFormatIon          = "ION"
+	exportTypeFull           = "FULL_EXPORT"
+	// defaultExportPageSize is the number of items of a page when the limit is not set
+	defaultExportPageSize = 1000
+	// maxExportLineSize is the longest line of a DynamoDB JSON data file, an item is at most 400KB before encoding
+	maxExportLineSize = 4 * 1024 * 1024
+)
+
+var errExportNotSupported = errors.New("not supported on a table export")
+
+// exportSummary is the manifest-summary.json of an export.
+type exportSummary struct {
+	ExportArn    string `json:"exportArn"`
+	TableArn     string `json:"tableArn"`
+	ExportTime   string `json:"exportTime"`
+	ItemCount    int64  `json:"itemCount"`
+	OutputFormat string `json:"outputFormat"`
+	ExportType   string `json:"exportType"`
+}
+
+// exportFile is a line of the manifest-files.json of an export.
+type exportFile struct {
+	ItemCount     int64  `json:"itemCount"`
+	DataFileS3Key string `json:"dataFileS3Key"`
+}
+
+// TableDescription is the part of the output of "aws dynamodb describe-table" describing the keys of the table, with
+// or without its enclosing {"Table": ...} object.
+type TableDescription struct {
+	TableName              string
+	KeySchema              []KeySchemaElement
+	AttributeDefinitions   []AttributeDefinition
+	LocalSecondaryIndexes  []SecondaryIndex
+	GlobalSecondaryIndexes []SecondaryIndex
+}
+
+type KeySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type AttributeDefinition struct {
+	AttributeName string
+	AttributeType string
+}
+
+type SecondaryIndex struct {
+	IndexName string
+	KeySchema []KeySchemaElement
+}
+
+// ReadTableDescription reads the table description file.
+func ReadTableDescription(path string) (*TableDescription, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the table description: %w", err)
+	}
+	var described struct {
+		Table *TableDescription
+		TableDescription
+	}
+	if err = json.Unmarshal(content, &described); err != nil {
+		return nil, fmt.Errorf("invalid table description %s: %w", path, err)
+	}
+	table := &described.TableDescription
+	if described.Table != nil {
+		table = described.Table
+	}
+	if len(table.KeySchema) == 0 {
+		return nil, fmt.Errorf("invalid table description %s: the key schema of the table is missing", path)
+	}
+	return table, nil
+}
+
+func (t *TableDescription) keySchema(elements []KeySchemaElement) []types.KeySchemaElement {
+	kse := make([]types.KeySchemaElement, len(elements))
+	for i, e := range elements {
+		kse[i] = types.KeySchemaElement{AttributeName: aws.String(e.AttributeName), KeyType: types.KeyType(e.KeyType)}
+	}
+	return kse
+}
+
+// ExportRepo reads the items of a table export to S3 downloaded to a directory, instead of scanning the table: the
+// manifest-summary.json and manifest-files.json of the export, and its gzipped data files in DynamoDB JSON or Amazon
+// Ion. The keys and the indexes of the table are read from its table description.
+type ExportRepo struct {
+	path   string
+	format string
+	files  []string
+	table  *TableDescription
+}
+
+func NewExportRepo() IRepo {
+	return &ExportRepo{}
+}
+
+func (e *ExportRepo) Init(opts *option.Options) error {
+	e.path = opts.ExportPath
+	var summary exportSummary
+	content, err := os.ReadFile(filepath.Join(e.path, "manifest-summary.json"))
+	if err != nil {
+		return fmt.Errorf("error reading the export: %w", err)
+	}
+	if err = json.Unmarshal(content, &summary); err != nil {
+		return fmt.Errorf("invalid manifest-summary.json: %w", err)
+	}
+	if summary.ExportType != "" && summary.ExportType != exportTypeFull {
+		return fmt.Errorf("the export %s is an %s, only the full exports can be migrated", summary.ExportArn,
+			summary.ExportType)
+	}
+	switch summary.OutputFormat {
+	case ExportFormatDynamoDBJSON, ExportFormatIon:
+		e.format = summary.OutputFormat
+	default:
+		return fmt.Errorf("the export %s has the unknown output format %s", summary.ExportArn, summary.OutputFormat)
+	}
+	if err = e.initFiles(); err != nil {
+		return err
+	}
+	if e.table, err = ReadTableDescription(opts.TableDescriptionFile); err != nil {
+		return err
+	}
+	zap.S().Infof("reading the export %s of the table %s at %s: %d items in %d files", summary.ExportArn,
+		summary.TableArn, summary.ExportTime, summary.ItemCount, len(e.files))
+	return nil
+}
+
+// initFiles lists the data files of manifest-files.json, they are looked up by name in the data directory of the
+// export.
+func (e *ExportRepo) initFiles() error {
+	f, err := os.Open(filepath.Join(e.path, "manifest-files.json"))
+	if err != nil {
+		return fmt.Errorf("error reading the export: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var file exportFile
+		if err = json.Unmarshal(scanner.Bytes(), &file); err != nil {
+			return fmt.Errorf("invalid manifest-files.json: %w", err)
+		}
+		path := filepath.Join(e.path, "data", filepath.Base(file.DataFileS3Key))
+		if _, err = os.Stat(path); err != nil {
+			return fmt.Errorf("the data file %s of the export is missing: %w", file.DataFileS3Key, err)
+		}
+		e.files = append(e.files, path)
+	}
+	return scanner.Err()
+}
+
+// NewPaginator returns the pages of the items of the segment-th of every totalSegments data files, so that the
+// segments read the files in parallel.
+func (e *ExportRepo) NewPaginator(segment int32, totalSegments int32, limit int32) IPaginator {
+	p := &exportPaginator{format: e.format, pageSize: int(limit)}
+	if p.pageSize <= 0 {
+		p.pageSize = defaultExportPageSize
+	}
+	for i := int(segment); i < len(e.files); i += int(totalSegments) {
+		p.files = append(p.files, e.files[i])
+	}
+	return p
+}
+
+func (e *ExportRepo) NewQueryPaginator(partitionKey string, limit int32) IPaginator {
+	return &exportPaginator{started: true, err: fmt.Errorf("query: %w", errExportNotSupported)}
+}
+
+func (e *ExportRepo) GetIndexes(ctx context.Context) ([]Index, error) {
+	indexes := []Index{getIndexFromSchema(e.table.keySchema(e.table.KeySchema))}
+	for _, index := range e.table.LocalSecondaryIndexes {
+		indexes = append(indexes, getIndexFromSchema(e.table.keySchema(index.KeySchema)))
+	}
+	for _, index := range e.table.GlobalSecondaryIndexes {
+		indexes = append(indexes, getIndexFromSchema(e.table.keySchema(index.KeySchema)))
+	}
+	return indexes, nil
+}
+
+func (e *ExportRepo) GetPrimaryIndex(ctx context.Context) (Index, error) {
+	return getIndexFromSchema(e.table.keySchema(e.table.KeySchema)), nil
+}
+
+func (e *ExportRepo) GetStream(ctx context.Context) (Stream, error) {
+	return Stream{}, fmt.Errorf("stream: %w", errExportNotSupported)
+}
+
+func (e *ExportRepo) GetShardIterator(ctx context.Context, streamArn string, shardID string, sequenceNumber string) (string, error) {
+	return "", fmt.Errorf("stream: %w", errExportNotSupported)
+}
+
+func (e *ExportRepo) GetRecords(ctx context.Context, iterator string) ([]StreamRecord, string, error) {
+	return nil, "", fmt.Errorf("stream: %w", errExportNotSupported)
+}
+
+// exportPaginator returns the items of its data files, read one after the other, by pages of pageSize items. The next
+// item is read ahead, so that there is no empty last page.
+type exportPaginator struct {
+	format   string
+	pageSize int
+	files    []string
+	current  exportFileReader
+	started  bool
+	next     map[string]types.AttributeValue
+	err      error
+}
+
+// exportFileReader returns the next item of a data file, io.EOF at its end.
+type exportFileReader interface {
+	next() (map[string]types.AttributeValue, error)
+	close() error
+}
+
+func (p *exportPaginator) HasMorePages() bool {
+	if !p.started {
+		p.started = true
+		p.advance()
+	}
+	return p.err != nil || p.next != nil
+}
+
+func (p *exportPaginator) NextPage(ctx context.Context, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if !p.started {
+		p.started = true
+		p.advance()
+	}
+	output := &dynamodb.ScanOutput{}
+	for p.next != nil && len(output.Items) < p.pageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		output.Items = append(output.Items, p.next)
+		p.advance()
+	}
+	if p.err != nil && len(output.Items) == 0 {
+		// the items read before the error are returned first
+		err := p.err
+		p.err = nil
+		return nil, err
+	}
+	output.Count = int32(len(output.Items))
+	output.ScannedCount = output.Count
+	return output, nil
+}
+
+// advance reads the next item, from the next data file at the end of the current one.
+func (p *exportPaginator) advance() {
+	p.next = nil
+	for len(p.files) > 0 {
+		if p.current == nil {
+			r, err := openExportFile(p.files[0], p.format)
+			if err != nil {
+				p.err = err
+				p.files = nil
+				return
+			}
+			p.current = r
+		}
+		item, err := p.current.next()
+		if err == io.EOF {
+			err = p.current.close()
+			p.current = nil
+			p.files = p.files[1:]
+			if err != nil {
+				p.err = err
+				return
+			}
+			continue
+		}
+		if err != nil {
+			p.err = fmt.Errorf("error reading the data file %s: %w", filepath.Base(p.files[0]), err)
+			_ = p.current.close()
+			p.current = nil
+			p.files = nil
+			return
+		}
+		p.next = item
+		return
+	}
+}
+
+func openExportFile(path string, format string) (exportFileReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the export: %w", err)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error reading the data file %s: %w", filepath.Base(path), err)
+	}
+	if format == ExportFormatIon {
+		return newIonFileReader(gz, f), nil
+	}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxExportLineSize)
+	return &jsonFileReader{scanner: scanner, file: f}, nil
+}
+
+// jsonFileReader reads a DynamoDB JSON data file, an {"Item": {...}} object per line.
+type jsonFileReader struct {
+	scanner *bufio.Scanner
+	file    *os.File
+}
+
+func (r *jsonFileReader) next() (map[string]types.AttributeValue, error) {
+	for r.scanner.Scan() {
+		if len(r.scanner.Bytes()) == 0 {
+			continue
+		}
+		var line struct {
+			Item json.RawMessage
+		}
+		if err := json.Unmarshal(r.scanner.Bytes(), &line); err != nil {
+			return nil, err
+		}
+		return UnmarshalAttributeValues(line.Item)
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *jsonFileReader) close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,152 @@
+package repo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
+	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/repo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const tableDescription = `{"Table": {"TableName": "orders", "AttributeDefinitions": [{"AttributeName": "customer", ` +
+	`"AttributeType": "S"}, {"AttributeName": "id", "AttributeType": "N"}], "KeySchema": [{"AttributeName": ` +
+	`"customer", "KeyType": "HASH"}, {"AttributeName": "id", "KeyType": "RANGE"}], "GlobalSecondaryIndexes": ` +
+	`[{"IndexName": "by-status", "KeySchema": [{"AttributeName": "status", "KeyType": "HASH"}]}], ` +
+	`"CreationDateTime": "2024-05-01T10:00:00.123000+02:00"}}`
+
+func gzipped(content string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(content))
+	Expect(err).To(BeNil())
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+// writeExport writes an export of the data files, in the layout of an export downloaded from S3, and the table
+// description next to it.
+func writeExport(format string, exportType string, files ...string) *option.Options {
+	dir := GinkgoT().TempDir()
+	Expect(os.MkdirAll(filepath.Join(dir, "data"), 0o755)).To(Succeed())
+	Expect(os.WriteFile(filepath.Join(dir, "manifest-summary.json"), []byte(`{"version": "2020-06-30", `+
+		`"exportArn": "arn:aws:dynamodb:us-east-1:123456789012:table/orders/export/01", "tableArn": `+
+		`"arn:aws:dynamodb:us-east-1:123456789012:table/orders", "exportTime": "2024-05-01T10:00:00.000Z", `+
+		`"itemCount": 3, "outputFormat": "`+format+`", "exportType": "`+exportType+`"}`), 0o644)).To(Succeed())
+	var manifest bytes.Buffer
+	for i, content := range files {
+		name := string(rune('a'+i)) + ".json.gz"
+		Expect(os.WriteFile(filepath.Join(dir, "data", name), gzipped(content), 0o644)).To(Succeed())
+		manifest.WriteString(`{"itemCount": 1, "md5Checksum": "x", "etag": "y", "dataFileS3Key": ` +
+			`"AWSDynamoDB/01/data/` + name + `"}` + "\n")
+	}
+	Expect(os.WriteFile(filepath.Join(dir, "manifest-files.json"), manifest.Bytes(), 0o644)).To(Succeed())
+	description := filepath.Join(dir, "table.json")
+	Expect(os.WriteFile(description, []byte(tableDescription), 0o644)).To(Succeed())
+	return &option.Options{TableName: "orders", ExportPath: dir, TableDescriptionFile: description}
+}
+
+func readPages(paginator repo.IPaginator) ([]map[string]types.AttributeValue, int) {
+	var items []map[string]types.AttributeValue
+	pages := 0
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(context.Background())
+		Expect(err).To(BeNil())
+		items = append(items, output.Items...)
+		pages++
+	}
+	return items, pages
+}
+
+var _ = Describe("table export", func() {
+	Context("success", func() {
+		It("the items of a DynamoDB JSON export are read by pages, the files split between the segments", func() {
+			opts := writeExport(repo.ExportFormatDynamoDBJSON, "FULL_EXPORT",
+				`{"Item":{"customer":{"S":"c1"},"id":{"N":"1"},"tags":{"SS":["a","b"]}}}`+"\n"+
+					`{"Item":{"customer":{"S":"c1"},"id":{"N":"2"},"data":{"B":"aGk="}}}`+"\n",
+				`{"Item":{"customer":{"S":"c2"},"id":{"N":"3"},"m":{"M":{"l":{"L":[{"BOOL":true},{"NULL":true}]}}}}}`)
+			r := repo.NewExportRepo()
+			Expect(r.Init(opts)).To(Succeed())
+
+			items, pages := readPages(r.NewPaginator(0, 2, 1))
+			Expect(pages).To(Equal(2))
+			Expect(items).To(Equal([]map[string]types.AttributeValue{
+				{"customer": &types.AttributeValueMemberS{Value: "c1"}, "id": &types.AttributeValueMemberN{Value: "1"},
+					"tags": &types.AttributeValueMemberSS{Value: []string{"a", "b"}}},
+				{"customer": &types.AttributeValueMemberS{Value: "c1"}, "id": &types.AttributeValueMemberN{Value: "2"},
+					"data": &types.AttributeValueMemberB{Value: []byte("hi")}},
+			}))
+			items, _ = readPages(r.NewPaginator(1, 2, 0))
+			Expect(items).To(Equal([]map[string]types.AttributeValue{
+				{"customer": &types.AttributeValueMemberS{Value: "c2"}, "id": &types.AttributeValueMemberN{Value: "3"},
+					"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+						"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberBOOL{Value: true},
+							&types.AttributeValueMemberNULL{Value: true},
+						}},
+					}}},
+			}))
+		})
+		It("the items of an Ion export are converted to the attribute values they were exported from", func() {
+			opts := writeExport(repo.ExportFormatIon, "FULL_EXPORT",
+				`$ion_1_0 {Item:{customer:"c1",id:1.,price:1250d-2,big:12d3,tags:$dynamodb_SS::["a","b"],`+
+					`ns:$dynamodb_NS::[1.,25d-1],data:{{aGk=}},m:{k:null,l:[true,"x"]}}}`+"\n"+
+					`$ion_1_0 {Item:{customer:"c1",id:-5d-3}}`)
+			r := repo.NewExportRepo()
+			Expect(r.Init(opts)).To(Succeed())
+
+			items, _ := readPages(r.NewPaginator(0, 1, 0))
+			Expect(items).To(Equal([]map[string]types.AttributeValue{
+				{
+					"customer": &types.AttributeValueMemberS{Value: "c1"},
+					"id":       &types.AttributeValueMemberN{Value: "1"},
+					"price":    &types.AttributeValueMemberN{Value: "12.5"},
+					"big":      &types.AttributeValueMemberN{Value: "12000"},
+					"tags":     &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+					"ns":       &types.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+					"data":     &types.AttributeValueMemberB{Value: []byte("hi")},
+					"m": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+						"k": &types.AttributeValueMemberNULL{Value: true},
+						"l": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberBOOL{Value: true},
+							&types.AttributeValueMemberS{Value: "x"},
+						}},
+					}},
+				},
+				{"customer": &types.AttributeValueMemberS{Value: "c1"}, "id": &types.AttributeValueMemberN{Value: "-0.005"}},
+			}))
+		})
+		It("the keys and the indexes are read from the table description", func() {
+			r := repo.NewExportRepo()
+			Expect(r.Init(writeExport(repo.ExportFormatDynamoDBJSON, "FULL_EXPORT"))).To(Succeed())
+			primary, err := r.GetPrimaryIndex(context.Background())
+			Expect(err).To(BeNil())
+			Expect(primary).To(Equal(repo.Index{Name: "customer-id", Keys: []string{"customer", "id"}}))
+			indexes, err := r.GetIndexes(context.Background())
+			Expect(err).To(BeNil())
+			Expect(indexes).To(Equal([]repo.Index{primary, {Name: "status", Keys: []string{"status"}}}))
+		})
+	})
+	Context("failure", func() {
+		It("an incremental export can not be migrated", func() {
+			r := repo.NewExportRepo()
+			Expect(r.Init(writeExport(repo.ExportFormatDynamoDBJSON, "INCREMENTAL_EXPORT"))).NotTo(Succeed())
+		})
+		It("the data files of the manifest must have been downloaded", func() {
+			opts := writeExport(repo.ExportFormatDynamoDBJSON, "FULL_EXPORT", `{"Item":{"id":{"S":"a"}}}`)
+			Expect(os.Remove(filepath.Join(opts.ExportPath, "data", "a.json.gz"))).To(Succeed())
+			Expect(repo.NewExportRepo().Init(opts)).NotTo(Succeed())
+		})
+		It("the changes of an export can not be followed", func() {
+			r := repo.NewExportRepo()
+			Expect(r.Init(writeExport(repo.ExportFormatDynamoDBJSON, "FULL_EXPORT"))).To(Succeed())
+			_, err := r.GetStream(context.Background())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,241 @@
+package repo
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amazon-ion/ion-go/ion"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The annotations of the Ion lists holding the sets of an Ion export.
+const (
+	ionStringSet = "$dynamodb_SS"
+	ionNumberSet = "$dynamodb_NS"
+	ionBinarySet = "$dynamodb_BS"
+)
+
+// ionFileReader reads an Amazon Ion data file, an {Item: {...}} struct per item.
+type ionFileReader struct {
+	reader ion.Reader
+	file   *os.File
+}
+
+func newIonFileReader(r io.Reader, file *os.File) *ionFileReader {
+	return &ionFileReader{reader: ion.NewReader(r), file: file}
+}
+
+func (r *ionFileReader) next() (map[string]types.AttributeValue, error) {
+	for r.reader.Next() {
+		if r.reader.Type() != ion.StructType {
+			continue
+		}
+		if err := r.reader.StepIn(); err != nil {
+			return nil, err
+		}
+		var item map[string]types.AttributeValue
+		for r.reader.Next() {
+			name, err := r.reader.FieldName()
+			if err != nil {
+				return nil, err
+			}
+			if name == nil || name.Text == nil || *name.Text != "Item" {
+				continue
+			}
+			value, err := ionAttributeValue(r.reader)
+			if err != nil {
+				return nil, err
+			}
+			m, ok := value.(*types.AttributeValueMemberM)
+			if !ok {
+				return nil, fmt.Errorf("the Item of a line is not a struct")
+			}
+			item = m.Value
+		}
+		if err := r.reader.StepOut(); err != nil {
+			return nil, err
+		}
+		if item != nil {
+			return item, nil
+		}
+	}
+	if err := r.reader.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *ionFileReader) close() error {
+	return r.file.Close()
+}
+
+// ionAttributeValue converts the current Ion value to the attribute value it was exported from: the numbers are
+// decimals, the binaries blobs, and the sets lists annotated with their type.
+func ionAttributeValue(r ion.Reader) (types.AttributeValue, error) {
+	if r.IsNull() {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+	switch r.Type() {
+	case ion.BoolType:
+		v, err := r.BoolValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberBOOL{Value: *v}, nil
+	case ion.IntType:
+		v, err := r.BigIntValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case ion.FloatType:
+		v, err := r.FloatValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberN{Value: strconv.FormatFloat(*v, 'g', -1, 64)}, nil
+	case ion.DecimalType:
+		v, err := r.DecimalValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberN{Value: decimalNumber(v)}, nil
+	case ion.StringType, ion.SymbolType:
+		v, err := r.StringValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberS{Value: *v}, nil
+	case ion.TimestampType:
+		v, err := r.TimestampValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberS{Value: v.GetDateTime().Format(time.RFC3339Nano)}, nil
+	case ion.BlobType, ion.ClobType:
+		v, err := r.ByteValue()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberB{Value: v}, nil
+	case ion.ListType, ion.SexpType:
+		return ionList(r)
+	case ion.StructType:
+		m := make(map[string]types.AttributeValue)
+		if err := r.StepIn(); err != nil {
+			return nil, err
+		}
+		for r.Next() {
+			name, err := r.FieldName()
+			if err != nil {
+				return nil, err
+			}
+			value, err := ionAttributeValue(r)
+			if err != nil {
+				return nil, err
+			}
+			if name != nil && name.Text != nil {
+				m[*name.Text] = value
+			}
+		}
+		if err := r.Err(); err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, r.StepOut()
+	}
+	return nil, fmt.Errorf("unsupported Ion type %s", r.Type())
+}
+
+// ionList converts a list, or a set when the list is annotated with its type.
+func ionList(r ion.Reader) (types.AttributeValue, error) {
+	annotations, err := r.Annotations()
+	if err != nil {
+		return nil, err
+	}
+	set := ""
+	for _, a := range annotations {
+		if a.Text != nil && strings.HasPrefix(*a.Text, "$dynamodb_") {
+			set = *a.Text
+		}
+	}
+	if err = r.StepIn(); err != nil {
+		return nil, err
+	}
+	var list []types.AttributeValue
+	for r.Next() {
+		value, err := ionAttributeValue(r)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	if err = r.Err(); err != nil {
+		return nil, err
+	}
+	if err = r.StepOut(); err != nil {
+		return nil, err
+	}
+	switch set {
+	case ionStringSet:
+		ss := make([]string, 0, len(list))
+		for _, v := range list {
+			s, ok := v.(*types.AttributeValueMemberS)
+			if !ok {
+				return nil, fmt.Errorf("the string set holds a %T", v)
+			}
+			ss = append(ss, s.Value)
+		}
+		return &types.AttributeValueMemberSS{Value: ss}, nil
+	case ionNumberSet:
+		ns := make([]string, 0, len(list))
+		for _, v := range list {
+			n, ok := v.(*types.AttributeValueMemberN)
+			if !ok {
+				return nil, fmt.Errorf("the number set holds a %T", v)
+			}
+			ns = append(ns, n.Value)
+		}
+		return &types.AttributeValueMemberNS{Value: ns}, nil
+	case ionBinarySet:
+		bs := make([][]byte, 0, len(list))
+		for _, v := range list {
+			b, ok := v.(*types.AttributeValueMemberB)
+			if !ok {
+				return nil, fmt.Errorf("the binary set holds a %T", v)
+			}
+			bs = append(bs, b.Value)
+		}
+		return &types.AttributeValueMemberBS{Value: bs}, nil
+	}
+	if list == nil {
+		list = []types.AttributeValue{}
+	}
+	return &types.AttributeValueMemberL{Value: list}, nil
+}
+
+// decimalNumber returns the decimal in the plain notation of the DynamoDB numbers, e.g. 1.5 for the Ion 15d-1.
+func decimalNumber(d *ion.Decimal) string {
+	coefficient, exponent := d.CoEx()
+	if exponent >= 0 {
+		return new(big.Int).Mul(coefficient, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)).String()
+	}
+	digits := new(big.Int).Abs(coefficient).String()
+	scale := int(-exponent)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	integer, fraction := digits[:len(digits)-scale], strings.TrimRight(digits[len(digits)-scale:], "0")
+	number := integer
+	if fraction != "" {
+		number += "." + fraction
+	}
+	if coefficient.Sign() < 0 {
+		number = "-" + number
+	}
+	return number
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The placeholders of the key attributes added to the expressions, prefixed to not clash with the ones of the user.
+const (
+	keyNamePlaceholder      = "#cbmigrate_key"
+	partitionKeyPlaceholder = ":cbmigrate_pk"
+)
+
+// expressions are the filter and projection expressions of the scan and the queries, and the placeholders they use.
+type expressions struct {
+	filter     string
+	projection string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+}
+
+func (e *expressions) filterExpression() *string {
+	if e.filter == "" {
+		return nil
+	}
+	return aws.String(e.filter)
+}
+
+func (e *expressions) projectionExpression() *string {
+	if e.projection == "" {
+		return nil
+	}
+	return aws.String(e.projection)
+}
+
+// withKeys adds the key attributes missing from the projection to it, they are named by placeholders as they may be
+// reserved words.
+func (e *expressions) withKeys(keys []string) {
+	if e.projection == "" {
+		return
+	}
+	projected := make(map[string]bool)
+	for _, path := range strings.Split(e.projection, ",") {
+		attribute := strings.TrimSpace(path)
+		if i := strings.IndexAny(attribute, ".["); i >= 0 {
+			attribute = attribute[:i]
+		}
+		if name, ok := e.names[attribute]; ok {
+			attribute = name
+		}
+		projected[attribute] = true
+	}
+	for i, key := range keys {
+		if projected[key] {
+			continue
+		}
+		placeholder := fmt.Sprintf("%s%d", keyNamePlaceholder, i)
+		e.names = maps.Clone(e.names)
+		if e.names == nil {
+			e.names = make(map[string]string)
+		}
+		e.names[placeholder] = key
+		e.projection += ", " + placeholder
+	}
+}
+
+// initPartitionKeys converts the partition key values to the type of the partition key of the table.
+func (r *Repo) initPartitionKeys(table *types.TableDescription, partitionKeys []string) error {
+	for _, ks := range table.KeySchema {
+		if ks.KeyType == types.KeyTypeHash {
+			r.partitionKeyName = aws.ToString(ks.AttributeName)
+		}
+	}
+	var keyType types.ScalarAttributeType
+	for _, ad := range table.AttributeDefinitions {
+		if aws.ToString(ad.AttributeName) == r.partitionKeyName {
+			keyType = ad.AttributeType
+		}
+	}
+	r.partitionKeys = make(map[string]types.AttributeValue, len(partitionKeys))
+	for _, pk := range partitionKeys {
+		var value types.AttributeValue
+		switch keyType {
+		case types.ScalarAttributeTypeN:
+			if _, ok := new(big.Float).SetString(pk); !ok {
+				return fmt.Errorf("the partition key %s of the table %s is a number, %q is not", r.partitionKeyName,
+					r.TableName, pk)
+			}
+			value = &types.AttributeValueMemberN{Value: pk}
+		case types.ScalarAttributeTypeB:
+			b, err := decodeBinary(pk)
+			if err != nil {
+				return fmt.Errorf("the partition key %s of the table %s is binary, %q is not base64: %w",
+					r.partitionKeyName, r.TableName, pk, err)
+			}
+			value = &types.AttributeValueMemberB{Value: b}
+		default:
+			value = &types.AttributeValueMemberS{Value: pk}
+		}
+		r.partitionKeys[pk] = value
+	}
+	return nil
+}
+
+// NewQueryPaginator returns the pages of the items of the partition key value, filtered and projected like the scan.
+func (r *Repo) NewQueryPaginator(partitionKey string, limit int32) IPaginator {
+	names := maps.Clone(r.expressions.names)
+	if names == nil {
+		names = make(map[string]string)
+	}
+	names[keyNamePlaceholder] = r.partitionKeyName
+	values := maps.Clone(r.expressions.values)
+	if values == nil {
+		values = make(map[string]types.AttributeValue)
+	}
+	values[partitionKeyPlaceholder] = r.partitionKeys[partitionKey]
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(r.TableName),
+		KeyConditionExpression:    aws.String(keyNamePlaceholder + " = " + partitionKeyPlaceholder),
+		FilterExpression:          r.expressions.filterExpression(),
+		ProjectionExpression:      r.expressions.projectionExpression(),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+	if limit > 0 {
+		qi.Limit = &limit
+	}
+	return queryPaginator{dynamodb.NewQueryPaginator(r.svc, qi)}
+}
+
+// queryPaginator returns the pages of a query as the pages of a scan.
+type queryPaginator struct {
+	*dynamodb.QueryPaginator
+}
+
+func (p queryPaginator) NextPage(ctx context.Context, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	output, err := p.QueryPaginator.NextPage(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            output.Items,
+		Count:            output.Count,
+		ScannedCount:     output.ScannedCount,
+		LastEvaluatedKey: output.LastEvaluatedKey,
+		ConsumedCapacity: output.ConsumedCapacity,
+	}, nil
+}
@@ -10,13 +10,40 @@ import (
 	dynamoDB "github.com/couchbaselabs/cbmigrate/internal/db/dynamodb"
 	"github.com/couchbaselabs/cbmigrate/internal/dynamodb/option"
 	"strings"
+	"time"
 )
 
 type IRepo interface {
 	Init(opts *option.Options) error
 	NewPaginator(segment int32, totalSegments int32, limit int32) IPaginator
+	NewQueryPaginator(partitionKey string, limit int32) IPaginator
 	GetIndexes(ctx context.Context) ([]Index, error)
 	GetPrimaryIndex(ctx context.Context) (Index, error)
+	GetStream(ctx context.Context) (Stream, error)
+	GetShardIterator(ctx context.Context, streamArn string, shardID string, sequenceNumber string) (string, error)
+	GetRecords(ctx context.Context, iterator string) ([]StreamRecord, string, error)
+}
+
+// Stream is the stream of the table, with its shards. Arn is empty when the stream of the table is not enabled.
+type Stream struct {
+	Arn      string
+	ViewType string
+	Shards   []Shard
+}
+
+type Shard struct {
+	ID       string
+	ParentID string
+}
+
+// StreamRecord is a change of an item read from the stream, EventName is INSERT, MODIFY or REMOVE. The NewImage of a
+// REMOVE is nil.
+type StreamRecord struct {
+	EventName      string
+	SequenceNumber string
+	CreatedAt      time.Time
+	Keys           map[string]types.AttributeValue
+	NewImage       map[string]types.AttributeValue
 }
 
 type Index struct {
@@ -32,6 +59,11 @@ type IPaginator interface {
 type Repo struct {
 	TableName string
 	svc       *dynamoDB.DB
+	// expressions are the filter and projection of the items read
+	expressions expressions
+	// partitionKeys are the values of the partition key the items are queried for, by their text
+	partitionKeys    map[string]types.AttributeValue
+	partitionKeyName string
 }
 
 func NewRepo() IRepo {
@@ -42,14 +74,39 @@ func NewRepo() IRepo {
 
 func (r *Repo) Init(opts *option.Options) error {
 	r.TableName = opts.TableName
-	return r.svc.Init(opts)
+	if err := r.svc.Init(opts); err != nil {
+		return err
+	}
+	r.expressions = expressions{
+		filter:     opts.FilterExpression,
+		projection: opts.ProjectionExpression,
+		names:      opts.ExpressionAttributeNames,
+		values:     opts.ExpressionAttributeValues,
+	}
+	if opts.ProjectionExpression == "" && len(opts.PartitionKeys) == 0 {
+		return nil
+	}
+	output, err := r.svc.DescribeTable(context.Background(), &dynamodb.DescribeTableInput{TableName: aws.String(r.TableName)})
+	if err != nil {
+		return err
+	}
+	// the attributes of the primary key are always read, the document key is generated from them
+	r.expressions.withKeys(getIndexFromSchema(output.Table.KeySchema).Keys)
+	if len(opts.PartitionKeys) > 0 {
+		return r.initPartitionKeys(output.Table, opts.PartitionKeys)
+	}
+	return nil
 }
 
 func (r *Repo) NewPaginator(segment int32, totalSegments int32, limit int32) IPaginator {
 	si := &dynamodb.ScanInput{
-		TableName:     aws.String(r.TableName),
-		Segment:       &segment,
-		TotalSegments: &totalSegments,
+		TableName:                 aws.String(r.TableName),
+		Segment:                   &segment,
+		TotalSegments:             &totalSegments,
+		FilterExpression:          r.expressions.filterExpression(),
+		ProjectionExpression:      r.expressions.projectionExpression(),
+		ExpressionAttributeNames:  r.expressions.names,
+		ExpressionAttributeValues: r.expressions.values,
 	}
 	if limit > 0 {
 		si.Limit = &limit
@@ -0,0 +1,138 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	sTypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// ErrExpiredIterator is returned by GetRecords when the shard iterator has expired, a new iterator is needed.
+var ErrExpiredIterator = errors.New("the shard iterator has expired")
+
+// GetStream returns the latest stream of the table and all its shards.
+func (r *Repo) GetStream(ctx context.Context) (Stream, error) {
+	var stream Stream
+	output, err := r.svc.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(r.TableName)})
+	if err != nil {
+		return stream, err
+	}
+	spec := output.Table.StreamSpecification
+	if output.Table.LatestStreamArn == nil || spec == nil || spec.StreamEnabled == nil || !*spec.StreamEnabled {
+		return stream, nil
+	}
+	stream.Arn = *output.Table.LatestStreamArn
+	stream.ViewType = string(spec.StreamViewType)
+	input := &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(stream.Arn)}
+	for {
+		described, err := r.svc.Streams.DescribeStream(ctx, input)
+		if err != nil {
+			return stream, fmt.Errorf("error describing the stream of the table %s: %w", r.TableName, err)
+		}
+		for _, shard := range described.StreamDescription.Shards {
+			stream.Shards = append(stream.Shards, Shard{
+				ID:       aws.ToString(shard.ShardId),
+				ParentID: aws.ToString(shard.ParentShardId),
+			})
+		}
+		if described.StreamDescription.LastEvaluatedShardId == nil {
+			return stream, nil
+		}
+		input.ExclusiveStartShardId = described.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// GetShardIterator returns an iterator of the records of the shard after sequenceNumber, or from the oldest record of
+// the shard when sequenceNumber is empty.
+func (r *Repo) GetShardIterator(ctx context.Context, streamArn string, shardID string, sequenceNumber string) (string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(streamArn),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: sTypes.ShardIteratorTypeTrimHorizon,
+	}
+	if sequenceNumber != "" {
+		input.ShardIteratorType = sTypes.ShardIteratorTypeAfterSequenceNumber
+		input.SequenceNumber = aws.String(sequenceNumber)
+	}
+	output, err := r.svc.Streams.GetShardIterator(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.ShardIterator), nil
+}
+
+// GetRecords returns the records of the iterator and the iterator of the next records, which is empty once a closed
+// shard has been read entirely.
+func (r *Repo) GetRecords(ctx context.Context, iterator string) ([]StreamRecord, string, error) {
+	output, err := r.svc.Streams.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{ShardIterator: aws.String(iterator)})
+	if err != nil {
+		var expired *sTypes.ExpiredIteratorException
+		if errors.As(err, &expired) {
+			return nil, "", ErrExpiredIterator
+		}
+		return nil, "", err
+	}
+	records := make([]StreamRecord, 0, len(output.Records))
+	for _, record := range output.Records {
+		change := record.Dynamodb
+		if change == nil {
+			continue
+		}
+		records = append(records, StreamRecord{
+			EventName:      string(record.EventName),
+			SequenceNumber: aws.ToString(change.SequenceNumber),
+			CreatedAt:      aws.ToTime(change.ApproximateCreationDateTime),
+			Keys:           toAttributeValues(change.Keys),
+			NewImage:       toAttributeValues(change.NewImage),
+		})
+	}
+	return records, aws.ToString(output.NextShardIterator), nil
+}
+
+// toAttributeValues converts the attribute values of the stream, which have their own types, to the attribute values of
+// the table.
+func toAttributeValues(values map[string]sTypes.AttributeValue) map[string]types.AttributeValue {
+	if values == nil {
+		return nil
+	}
+	converted := make(map[string]types.AttributeValue, len(values))
+	for k, v := range values {
+		converted[k] = toAttributeValue(v)
+	}
+	return converted
+}
+
+func toAttributeValue(value sTypes.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *sTypes.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *sTypes.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *sTypes.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: v.Value}
+	case *sTypes.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: v.Value}
+	case *sTypes.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: v.Value}
+	case *sTypes.AttributeValueMemberBS:
+		return &types.AttributeValueMemberBS{Value: v.Value}
+	case *sTypes.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *sTypes.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *sTypes.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: toAttributeValues(v.Value)}
+	case *sTypes.AttributeValueMemberL:
+		list := make([]types.AttributeValue, len(v.Value))
+		for i, item := range v.Value {
+			list[i] = toAttributeValue(item)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	}
+	return &types.AttributeValueMemberNULL{Value: true}
+}
@@ -20,3 +20,19 @@ func (e *MongoNotSupportedError) Error() string {
 func NewMongoNotSupportedError(message string) error {
 	return &MongoNotSupportedError{Message: message}
 }
+
+type CouchbaseNotSupportedError struct {
+	Message string
+}
+
+func (e *CouchbaseNotSupportedError) IsNotSupportedError() bool {
+	return true
+}
+
+func (e *CouchbaseNotSupportedError) Error() string {
+	return e.Message
+}
+
+func NewCouchbaseNotSupportedError(message string) error {
+	return &CouchbaseNotSupportedError{Message: message}
+}
@@ -0,0 +1,79 @@
+package migrater
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// CollectionJob is the migration of one collection of a multi collection run. Every job needs its own IMigrate, as
+// the sources and destinations keep per collection state.
+type CollectionJob[Options any] struct {
+	Name       string
+	Migrate    IMigrate[Options]
+	SourceOpts *Options
+	CBOpts     *option.Options
+}
+
+type CollectionResult struct {
+	Name     string
+	Target   string
+	Duration time.Duration
+	Err      error
+}
+
+// CopyCollections runs the jobs with at most concurrency migrations at a time. A failed collection does not stop the
+// other ones, the results of all the jobs are returned in the order of the jobs and the errors are joined.
+func CopyCollections[Options any](jobs []CollectionJob[Options], copyIndexes bool, bufferSize int, concurrency int) ([]CollectionResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]CollectionResult, len(jobs))
+	var mu sync.Mutex
+	var errs []error
+	g := errgroup.Group{}
+	g.SetLimit(concurrency)
+	for i, job := range jobs {
+		g.Go(func() error {
+			zap.S().Infof("migration of collection %s started", job.Name)
+			start := time.Now()
+			err := job.Migrate.Copy(job.SourceOpts, job.CBOpts, copyIndexes, bufferSize)
+			results[i] = CollectionResult{
+				Name:     job.Name,
+				Target:   fmt.Sprintf("%s.%s.%s", job.CBOpts.Bucket, job.CBOpts.Scope, job.CBOpts.Collection),
+				Duration: time.Since(start),
+				Err:      err,
+			}
+			if err != nil {
+				zap.S().Errorf("migration of collection %s failed: %v", job.Name, err)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("collection %s: %w", job.Name, err))
+				mu.Unlock()
+				return nil
+			}
+			zap.S().Infof("migration of collection %s completed", job.Name)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return results, errors.Join(errs...)
+}
+
+// LogSummary logs the outcome of every collection of a multi collection run.
+func LogSummary(results []CollectionResult) {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			zap.S().Infof("%-30s -> %s: failed after %s: %v", r.Name, r.Target, r.Duration.Round(time.Millisecond), r.Err)
+			continue
+		}
+		zap.S().Infof("%-30s -> %s: migrated in %s", r.Name, r.Target, r.Duration.Round(time.Millisecond))
+	}
+	zap.S().Infof("%d collections migrated, %d failed", len(results)-failed, failed)
+}
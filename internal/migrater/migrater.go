@@ -7,6 +7,9 @@ import (
 	"github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 //go:generate mockgen -source=migrater.go -destination=../../testhelper/mock/migrater.go -package=mock IMigrate
@@ -41,7 +44,9 @@ func (m Migrate[Options]) Copy(mOpts *Options, cbOpts *option.Options, copyIndex
 	var mChan = make(chan map[string]interface{}, bufferSize)
 	g := errgroup.Group{}
 	var sErr, dErr error
+	sDone := make(chan struct{})
 	g.Go(func() error {
+		defer close(sDone)
 		// message channel should be closed properly inside stream data function using syntax:  close(mChan)
 		sErr = m.Source.StreamData(ctx, mChan)
 		return nil
@@ -54,6 +59,11 @@ func (m Migrate[Options]) Copy(mOpts *Options, cbOpts *option.Options, copyIndex
 				return nil
 			}
 		}
+		<-sDone
+		if d, ok := m.Destination.(common.IAbortableDestination); ok && sErr != nil {
+			dErr = d.Abort(sErr)
+			return nil
+		}
 		dErr = m.Destination.Complete()
 		return nil
 	})
@@ -68,6 +78,10 @@ func (m Migrate[Options]) Copy(mOpts *Options, cbOpts *option.Options, copyIndex
 		return err
 	}
 	zap.S().Info("data migration completed")
+	if s, ok := m.Source.(common.ISnapshotSource); ok && s.SnapshotTime() != "" {
+		zap.S().Infof("the data was read at the snapshot %s, it is the starting point of a follower of the changes "+
+			"made after the migration", s.SnapshotTime())
+	}
 	if copyIndexes {
 		zap.S().Info("index migration started")
 		cbIndexes, err := m.Source.GetCouchbaseIndexesQuery(cbOpts.Bucket, cbOpts.Scope, cbOpts.Collection)
@@ -80,9 +94,32 @@ func (m Migrate[Options]) Copy(mOpts *Options, cbOpts *option.Options, copyIndex
 		}
 		zap.S().Info("index migration completed")
 	}
+	if f, ok := m.Source.(common.IFollowSource); ok && f.IsFollowing() {
+		return m.follow(f)
+	}
 	return err
 }
 
+// follow applies the changes streamed by the source until the process is interrupted. Every change is written as
+// soon as it is processed.
+func (m Migrate[Options]) follow(f common.IFollowSource) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	zap.S().Info("following the changes of the source, interrupt to stop")
+	err := f.Follow(ctx, func(data map[string]interface{}) error {
+		err := m.Destination.ProcessData(data)
+		if err != nil {
+			return err
+		}
+		return m.Destination.Complete()
+	})
+	if err != nil {
+		return err
+	}
+	zap.S().Info("following the changes stopped")
+	return nil
+}
+
 func NewMigrator[Options any](source common.ISource[Options], destination common.IDestination) IMigrate[Options] {
 	return Migrate[Options]{
 		Source:      source,
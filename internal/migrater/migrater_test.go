@@ -85,6 +85,21 @@ var cIndexes = []common.Index{
 	},
 }
 
+type abortableDestination struct {
+	*mocktest.MockIDestination
+	abortErr error
+}
+
+func (a *abortableDestination) Abort(sourceErr error) error {
+	a.abortErr = sourceErr
+	return nil
+}
+
+type followSource struct {
+	*mocktest.MockISource[mOpts.Options]
+	*mocktest.MockIFollowSource
+}
+
 var _ = Describe("migrate", func() {
 	Describe("test data migration", func() {
 		var (
@@ -137,6 +152,31 @@ var _ = Describe("migrate", func() {
 				Expect(err).To(BeNil())
 			})
 		})
+		Context("follow", func() {
+			It("changes are applied after the initial load", func() {
+				follow := mocktest.NewMockIFollowSource(ctrl)
+				migrater = migrater2.NewMigrator[mOpts.Options](followSource{source, follow}, destination)
+				destination.EXPECT().Init(CBOpts, dk).Return(nil)
+				source.EXPECT().Init(MOpts, dk).Return(nil)
+				source.EXPECT().StreamData(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, stream chan map[string]interface{}) error {
+					close(stream)
+					return nil
+				})
+				change := map[string]interface{}{"_id": 1, common.OperationField: common.OperationDelete}
+				gomock.InOrder(
+					destination.EXPECT().Complete().Return(nil),
+					follow.EXPECT().IsFollowing().Return(true),
+					follow.EXPECT().Follow(gomock.Any(), gomock.Any()).DoAndReturn(
+						func(ctx context.Context, apply func(data map[string]interface{}) error) error {
+							return apply(change)
+						}),
+					destination.EXPECT().ProcessData(change).Return(nil),
+					destination.EXPECT().Complete().Return(nil),
+				)
+				err := migrater.Copy(MOpts, CBOpts, false, 10000)
+				Expect(err).To(BeNil())
+			})
+		})
 		Context("failure", func() {
 			It("source connection initialization error", func() {
 				sourceError := errors.New("error occurred in source connection initialization")
@@ -176,6 +216,23 @@ var _ = Describe("migrate", func() {
 				Expect(err).To(Equal(errors.Join(streamError)))
 			})
 
+			It("error while streaming the data to an abortable destination", func() {
+				streamError := errors.New("error occurred while streaming the data")
+				abortable := &abortableDestination{MockIDestination: destination}
+				migrater = migrater2.NewMigrator[mOpts.Options](source, abortable)
+				destination.EXPECT().Init(CBOpts, dk).Return(nil)
+				source.EXPECT().Init(MOpts, dk).Return(nil)
+				source.EXPECT().StreamData(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, stream chan map[string]interface{}) error {
+					stream <- testData[0]
+					close(stream)
+					return streamError
+				})
+				destination.EXPECT().ProcessData(gomock.Any()).Return(nil)
+				err := migrater.Copy(MOpts, CBOpts, true, 10000)
+				Expect(err).To(Equal(errors.Join(streamError)))
+				Expect(abortable.abortErr).To(Equal(streamError))
+			})
+
 			It("error while processing the data", func() {
 				dataProcessError := errors.New("error occurred while processing the data")
 				contextCancelledError := errors.New("context cancelled error")
@@ -205,4 +262,44 @@ var _ = Describe("migrate", func() {
 			})
 		})
 	})
+	Describe("test collections migration", func() {
+		var (
+			ctrl    *gomock.Controller
+			migrate *mocktest.MockIMigrate[mOpts.Options]
+		)
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			migrate = mocktest.NewMockIMigrate[mOpts.Options](ctrl)
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		It("a failed collection does not stop the other collections", func() {
+			copyError := errors.New("error occurred while copying")
+			var jobs []migrater2.CollectionJob[mOpts.Options]
+			for _, name := range []string{"users", "orders", "logs"} {
+				jobs = append(jobs, migrater2.CollectionJob[mOpts.Options]{
+					Name:       name,
+					Migrate:    migrate,
+					SourceOpts: &mOpts.Options{Namespace: &mOpts.Namespace{Collection: name}},
+					CBOpts:     &cOpts.Options{NameSpace: &cOpts.NameSpace{Bucket: "b", Scope: "s", Collection: name}},
+				})
+			}
+			migrate.EXPECT().Copy(gomock.Any(), gomock.Any(), true, 100).Times(3).DoAndReturn(
+				func(mOpts *mOpts.Options, cbOpts *cOpts.Options, copyIndexes bool, bufferSize int) error {
+					if mOpts.Collection == "orders" {
+						return copyError
+					}
+					return nil
+				})
+			results, err := migrater2.CopyCollections(jobs, true, 100, 2)
+			Expect(errors.Is(err, copyError)).To(BeTrue())
+			Expect(results).To(HaveLen(3))
+			Expect(results[0].Name).To(Equal("users"))
+			Expect(results[0].Target).To(Equal("b.s.users"))
+			Expect(results[0].Err).To(BeNil())
+			Expect(results[1].Err).To(Equal(copyError))
+			Expect(results[2].Err).To(BeNil())
+		})
+	})
 })
@@ -8,20 +8,39 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type Analyzer interface {
-	Init(index []Index, documentKey common.ICBDocumentKey)
+	Init(index []Index, documentKey common.ICBDocumentKey, opts AnalyzerOptions)
 	AnalyzeData(data map[string]interface{})
 	GetCouchbaseQuery(bucket, scope, collection string) []common.Index
 	//GetKeyPathWithArrayNotation(field string) string
 }
 
+// AnalyzerOptions restricts the documents the index fields are analyzed in.
+type AnalyzerOptions struct {
+	// SamplePercent is the percentage of the documents analyzed, every document is analyzed when it is 0 or 100
+	SamplePercent int
+	// MaxOccurrences is the number of documents a field is analyzed in, without limit when it is 0
+	MaxOccurrences int
+}
+
+// IndexFieldAnalyzer is safe for concurrent use, so the documents of a collection can be analyzed while they are read
+// by several cursors.
 type IndexFieldAnalyzer struct {
+	mu      sync.Mutex
 	indexes []Index
 	keys    map[string]*key
 	dk      common.ICBDocumentKey
+	opts    AnalyzerOptions
+	// credit accumulates the sample percentage of the documents seen, a document is analyzed every time it reaches 100
+	credit   int
+	analyzed int
+	// capped is the number of keys analyzed in MaxOccurrences documents
+	capped int
 }
 
 type occurrence int
@@ -37,8 +56,14 @@ func NewIndexFieldAnalyzer() Analyzer {
 	}
 }
 
-func (a *IndexFieldAnalyzer) Init(indexes []Index, documentKey common.ICBDocumentKey) {
+func (a *IndexFieldAnalyzer) Init(indexes []Index, documentKey common.ICBDocumentKey, opts AnalyzerOptions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.indexes = indexes
+	a.opts = opts
+	if a.opts.SamplePercent <= 0 || a.opts.SamplePercent > 100 {
+		a.opts.SamplePercent = 100
+	}
 	for _, i := range indexes {
 		for _, key := range i.Keys {
 			a.keys[key.Field] = nil
@@ -53,8 +78,20 @@ func (a *IndexFieldAnalyzer) Init(indexes []Index, documentKey common.ICBDocumen
 }
 
 func (a *IndexFieldAnalyzer) AnalyzeData(data map[string]interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.opts.MaxOccurrences > 0 && a.capped == len(a.keys) {
+		// every key has been analyzed in enough documents
+		return
+	}
+	a.credit += a.opts.SamplePercent
+	if a.credit < 100 {
+		return
+	}
+	a.credit -= 100
+	a.analyzed++
 	for k := range a.keys {
-		if a.keys[k] != nil && a.keys[k].occurrence > 100 {
+		if a.opts.MaxOccurrences > 0 && a.keys[k] != nil && a.keys[k].occurrence >= a.opts.MaxOccurrences {
 			continue
 		}
 		path, found := NavigatePath(k, data)
@@ -70,6 +107,9 @@ func (a *IndexFieldAnalyzer) AnalyzeData(data map[string]interface{}) {
 				a.keys[k].keys[path]++
 			}
 			a.keys[k].occurrence++
+			if a.keys[k].occurrence == a.opts.MaxOccurrences {
+				a.capped++
+			}
 		}
 	}
 }
@@ -97,6 +137,8 @@ func (a *IndexFieldAnalyzer) getIndexFieldPath() IndexFieldPath {
 }
 
 func (a *IndexFieldAnalyzer) GetCouchbaseQuery(bucket, scope, collection string) []common.Index {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	fieldPath := a.getIndexFieldPath()
 	var indexes []common.Index
 	isPrimaryIndexPresent := false
@@ -107,15 +149,28 @@ func (a *IndexFieldAnalyzer) GetCouchbaseQuery(bucket, scope, collection string)
 		switch {
 		case mindex.Error != nil:
 			cindex.Error = mindex.Error
+		case mindex.Text != nil || mindex.Geo != nil:
+			definition, err := CreateSearchIndexDefinition(bucket, scope, collection, mindex)
+			cindex.Name = SearchIndexName(collection, mindex.Name)
+			cindex.SearchDefinition = definition
+			cindex.Error = err
 		case len(mindex.Keys) == 1 && a.dk.GetNonCompoundPrimaryKeyOnly() == mindex.Keys[0].Field:
 			cindex.Query = fmt.Sprintf(
 				"CREATE PRIMARY INDEX `%s` on `%s`.`%s`.`%s` USING GSI WITH {\"defer_build\":true}",
 				mindex.Name, bucket, scope, collection)
 			isPrimaryIndexPresent = true
 		default:
-			query, err := CreateIndexQuery(bucket, scope, collection, mindex, fieldPath)
-			cindex.Query = query
-			cindex.Error = err
+			notes := a.fieldNotes(mindex, fieldPath)
+			if mindex.CaseInsensitive && len(mindex.Keys) > 0 {
+				notes = append(notes, fmt.Sprintf("the index has a case insensitive collation, it is created on "+
+					"LOWER() of its keys: rewrite the queries to compare the lowercase values to use it, e.g. WHERE "+
+					"LOWER(%s) = LOWER($value)", formatFieldReference(fieldPath.Get(mindex.Keys[0].Field))))
+			}
+			for _, cindex := range CreateIndexQueries(bucket, scope, collection, mindex, fieldPath) {
+				cindex.Notes = notes
+				indexes = append(indexes, cindex)
+			}
+			continue
 		}
 		indexes = append(indexes, cindex)
 	}
@@ -133,6 +188,48 @@ func (a *IndexFieldAnalyzer) GetCouchbaseQuery(bucket, scope, collection string)
 	return indexes
 }
 
+// fieldNotes returns the findings of the analysis of the index keys: the documents they have been found in, under
+// which paths (an array being marked by []) and the path chosen for the index.
+func (a *IndexFieldAnalyzer) fieldNotes(index Index, fieldPath IndexFieldPath) []string {
+	var notes []string
+	for _, k := range index.Keys {
+		chosen := fieldPath.Get(k.Field)
+		if chosen == common.MetaDataID {
+			continue
+		}
+		v := a.keys[k.Field]
+		if v == nil {
+			notes = append(notes, fmt.Sprintf("field %s not found in the %d analyzed documents, indexed as %s",
+				k.Field, a.analyzed, chosen))
+			continue
+		}
+		paths := make([]string, 0, len(v.keys))
+		for path := range v.keys {
+			paths = append(paths, path)
+		}
+		sort.Slice(paths, func(i, j int) bool {
+			if v.keys[paths[i]] != v.keys[paths[j]] {
+				return v.keys[paths[i]] > v.keys[paths[j]]
+			}
+			return paths[i] < paths[j]
+		})
+		seen := make([]string, len(paths))
+		for i, path := range paths {
+			seen[i] = fmt.Sprintf("%s in %d", path, v.keys[path])
+		}
+		note := fmt.Sprintf("field %s found in %d of the %d analyzed documents (as %s), indexed as %s", k.Field,
+			v.occurrence, a.analyzed, strings.Join(seen, ", "), chosen)
+		if strings.Contains(chosen, "[]") {
+			note += " (array)"
+		}
+		if a.opts.MaxOccurrences > 0 && v.occurrence >= a.opts.MaxOccurrences {
+			note += fmt.Sprintf(", the analysis of the field stopped after %d documents", a.opts.MaxOccurrences)
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
 func isArray(val interface{}) bool {
 	switch val.(type) {
 	case []interface{}, primitive.A:
@@ -3,10 +3,12 @@ package mongo_test
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/couchbaselabs/cbmigrate/internal/common"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var _ = Describe("index analyzer", func() {
@@ -40,4 +42,47 @@ var _ = Describe("index analyzer", func() {
 		})
 
 	})
+	Describe("analysis options and findings", func() {
+		index := mongo.Index{Name: "idx", Keys: []mongo.Key{{Field: "k1.n1k1", Order: 1}, {Field: "k2", Order: 1}}}
+		arrayDoc := map[string]interface{}{"k1": primitive.A{map[string]interface{}{"n1k1": 1}}}
+		objectDoc := map[string]interface{}{"k1": map[string]interface{}{"n1k1": 1}}
+		newAnalyzer := func(opts mongo.AnalyzerOptions) mongo.Analyzer {
+			analyzer := mongo.NewIndexFieldAnalyzer()
+			dk := common.NewCBDocumentKey()
+			dk.Set([]common.DocumentKeyPart{{Value: "_id", Kind: common.DkField}})
+			analyzer.Init([]mongo.Index{index}, dk, opts)
+			return analyzer
+		}
+		It("the paths chosen for the fields are reported", func() {
+			analyzer := newAnalyzer(mongo.AnalyzerOptions{})
+			analyzer.AnalyzeData(arrayDoc)
+			analyzer.AnalyzeData(arrayDoc)
+			analyzer.AnalyzeData(objectDoc)
+			indexes := analyzer.GetCouchbaseQuery("b", "s", "c")
+			Expect(indexes[0].Query).To(ContainSubstring("ALL ARRAY `l1Item`.`n1k1` FOR `l1Item` IN `k1` END"))
+			Expect(indexes[0].Notes).To(Equal([]string{
+				"field k1.n1k1 found in 3 of the 3 analyzed documents (as k1[].n1k1 in 2, k1.n1k1 in 1), indexed as k1[].n1k1 (array)",
+				"field k2 not found in the 3 analyzed documents, indexed as k2",
+			}))
+		})
+		It("only the sampled documents are analyzed, up to the maximum occurrences of the fields", func() {
+			analyzer := newAnalyzer(mongo.AnalyzerOptions{SamplePercent: 50, MaxOccurrences: 2})
+			for i := 0; i < 10; i++ {
+				analyzer.AnalyzeData(objectDoc)
+			}
+			indexes := analyzer.GetCouchbaseQuery("b", "s", "c")
+			// k2 is never found, so the documents keep being analyzed: one of every two documents
+			Expect(indexes[0].Notes[0]).To(Equal("field k1.n1k1 found in 2 of the 5 analyzed documents (as k1.n1k1 in 2), " +
+				"indexed as k1.n1k1, the analysis of the field stopped after 2 documents"))
+		})
+		It("the queries of a case insensitive index are explained", func() {
+			analyzer := mongo.NewIndexFieldAnalyzer()
+			analyzer.Init([]mongo.Index{{Name: "email_1", Keys: []mongo.Key{{Field: "email", Order: 1}},
+				CaseInsensitive: true}}, common.NewCBDocumentKey(), mongo.AnalyzerOptions{})
+			analyzer.AnalyzeData(map[string]interface{}{"email": "a@example.com"})
+			indexes := analyzer.GetCouchbaseQuery("b", "s", "c")
+			Expect(indexes[0].Query).To(ContainSubstring("LOWER(`email`)"))
+			Expect(indexes[0].Notes).To(ContainElement(ContainSubstring("WHERE LOWER(`email`) = LOWER($value)")))
+		})
+	})
 })
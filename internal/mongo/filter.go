@@ -0,0 +1,228 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// convertSpecialOperator translates the operators whose condition is not a plain comparison of the field.
+func convertSpecialOperator(field string, op string, val interface{}, operators bson.D) (string, error) {
+	switch op {
+	case "$nin":
+		return convertNotIn(field, val)
+	case "$regex":
+		options, _ := operators.Map()["$options"].(string)
+		return convertRegex(field, val, options)
+	case "$all":
+		return convertAll(field, val)
+	case "$size":
+		return convertSize(field, val)
+	case "$elemMatch":
+		return convertElemMatch(field, val)
+	case "$not":
+		return convertNot(field, val)
+	}
+	return "", fmt.Errorf("operand %s cannot be parsed to couchbase operand", op)
+}
+
+// fieldCondition returns the condition on the field, built by condition from the reference of the field. The
+// condition is applied to the elements of the arrays of the path, e.g. for k1[].n1k1:
+// ANY `l1Item` IN `k1` SATISFIES (condition(`l1Item`.`n1k1`)) END.
+func fieldCondition(field string, condition func(ref string) string) string {
+	parts := strings.Split(field, "[]")
+	for i := range parts {
+		parts[i] = strings.TrimPrefix(parts[i], ".")
+	}
+	return buildFieldCondition(parts, "", 0, condition)
+}
+
+func buildFieldCondition(parts []string, parent string, l int, condition func(ref string) string) string {
+	l++
+	if len(parts) == 1 {
+		switch {
+		case parent == "":
+			return condition(formatFieldReference(parts[0]))
+		case parts[0] == "":
+			return condition(parent)
+		}
+		return condition(parent + "." + formatFieldReference(parts[0]))
+	}
+	item := fmt.Sprintf("`l%dItem`", l)
+	items := formatFieldReference(parts[0])
+	if parent != "" {
+		items = parent + "." + items
+	}
+	return fmt.Sprintf("ANY %s IN %s SATISFIES (%s) END", item, items,
+		buildFieldCondition(parts[1:], item, l, condition))
+}
+
+// arrayPath returns the path of the array itself, the analyzer marks the arrays with a trailing [].
+func arrayPath(field string) string {
+	return strings.TrimSuffix(field, "[]")
+}
+
+// missingOrNull returns the condition on the field (or on the array holding it) matching the documents without it,
+// which the MongoDB negations match while the N1QL ones are MISSING or NULL for them.
+func missingOrNull(field string) string {
+	ref := formatFieldReference(strings.Split(field, "[]")[0])
+	return fmt.Sprintf("%s IS MISSING OR %s IS NULL", ref, ref)
+}
+
+// convertNotIn translates $nin: for an array, none of the elements is one of the values. The documents without the
+// field match, unless null is one of the values.
+func convertNotIn(field string, val interface{}) (string, error) {
+	values, ok := val.(bson.A)
+	if !ok {
+		return "", fmt.Errorf("$nin value %v is not an array", val)
+	}
+	var condition string
+	if strings.Contains(field, "[]") {
+		in := fieldCondition(field, func(ref string) string {
+			return fmt.Sprintf("%s IN %s", ref, getValue(values, field))
+		})
+		condition = fmt.Sprintf("NOT (%s)", in)
+	} else {
+		condition = fmt.Sprintf("%s NOT IN %s", formatFieldReference(field), getValue(values, field))
+	}
+	for _, v := range values {
+		if v == nil {
+			return condition, nil
+		}
+	}
+	return fmt.Sprintf("(%s OR %s)", missingOrNull(field), condition), nil
+}
+
+// regexFlags maps the mongo regular expression options to the inline flags of the couchbase regular expressions.
+var regexFlags = map[rune]string{
+	'i': "i",
+	'm': "m",
+	's': "s",
+}
+
+// convertRegex translates $regex, with the options given in the regular expression or by $options. A regular
+// expression anchored at both ends matches the whole value (REGEXP_LIKE), the others match a part of it
+// (REGEXP_CONTAINS).
+func convertRegex(field string, val interface{}, options string) (string, error) {
+	pattern := ""
+	switch v := val.(type) {
+	case primitive.Regex:
+		pattern = v.Pattern
+		options += v.Options
+	case string:
+		pattern = v
+	default:
+		return "", fmt.Errorf("$regex value %v is not a regular expression", val)
+	}
+	flags := ""
+	for _, o := range options {
+		f, ok := regexFlags[o]
+		if !ok {
+			return "", fmt.Errorf("regular expression option %c cannot be parsed to couchbase", o)
+		}
+		if !strings.Contains(flags, f) {
+			flags += f
+		}
+	}
+	function := "REGEXP_CONTAINS"
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "^") && strings.HasSuffix(pattern, "$") &&
+		!strings.HasSuffix(pattern, `\$`) && !strings.Contains(pattern, "|") && !strings.Contains(flags, "m") {
+		function = "REGEXP_LIKE"
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+	literal, err := json.Marshal(pattern)
+	if err != nil {
+		return "", err
+	}
+	return fieldCondition(field, func(ref string) string {
+		return fmt.Sprintf("%s(%s, %s)", function, ref, literal)
+	}), nil
+}
+
+// convertAll translates $all: the array holds every value.
+func convertAll(field string, val interface{}) (string, error) {
+	values, ok := val.(bson.A)
+	if !ok || len(values) == 0 {
+		return "", fmt.Errorf("$all value %v is not a non empty array", val)
+	}
+	if !strings.HasSuffix(field, "[]") {
+		field += "[]"
+	}
+	conditions := make([]string, len(values))
+	for i, v := range values {
+		if _, ok := v.(bson.D); ok {
+			return "", fmt.Errorf("$all with $elemMatch cannot be parsed to couchbase")
+		}
+		conditions[i] = fieldCondition(field, func(ref string) string {
+			return fmt.Sprintf("%s = %s", ref, getValue(v, field))
+		})
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", nil
+}
+
+// convertSize translates $size: the length of the array.
+func convertSize(field string, val interface{}) (string, error) {
+	switch val.(type) {
+	case int, int32, int64, float64:
+	default:
+		return "", fmt.Errorf("$size value %v is not a number", val)
+	}
+	return fieldCondition(arrayPath(field), func(ref string) string {
+		return fmt.Sprintf("ARRAY_LENGTH(%s) = %v", ref, val)
+	}), nil
+}
+
+// convertElemMatch translates $elemMatch: an element of the array satisfies the conditions. The conditions are either
+// operators applied to the elements ({"$gte": 80}), or a query on the fields of the elements ({"score": 8}).
+func convertElemMatch(field string, val interface{}) (string, error) {
+	query, ok := val.(bson.D)
+	if !ok || len(query) == 0 {
+		return "", fmt.Errorf("$elemMatch value %v is not a non empty document", val)
+	}
+	var err error
+	condition := fieldCondition(arrayPath(field), func(ref string) string {
+		// the element variables of nested $elemMatch are numbered, so they do not shadow each other
+		elem := fmt.Sprintf("elem%d", strings.Count(ref, "`elem")+1)
+		var inner string
+		if strings.HasPrefix(query[0].Key, "$") && query[0].Key != "$and" && query[0].Key != "$or" {
+			inner, err = convertOperator(elem, query)
+		} else {
+			elemPath := make(IndexFieldPath)
+			for _, key := range extractKeys(query) {
+				elemPath[key] = elem + "." + key
+			}
+			inner, err = processExpression(query, elemPath)
+		}
+		return fmt.Sprintf("ANY `%s` IN %s SATISFIES (%s) END", elem, ref, inner)
+	})
+	return condition, err
+}
+
+// convertNot translates $not applied to an operator document or a regular expression, the documents without the field
+// match.
+func convertNot(field string, val interface{}) (string, error) {
+	var condition string
+	var err error
+	switch v := val.(type) {
+	case bson.D:
+		condition, err = convertOperator(field, v)
+	case primitive.Regex:
+		condition, err = convertRegex(field, v, "")
+	default:
+		return "", fmt.Errorf("$not value %v is not an operator document or a regular expression", val)
+	}
+	if err != nil {
+		return "", err
+	}
+	// the documents without the field match the negation
+	return fmt.Sprintf("(%s OR NOT (%s))", missingOrNull(field), condition), nil
+}
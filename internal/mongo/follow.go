@@ -0,0 +1,152 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type changeEvent struct {
+	OperationType string                 `bson:"operationType"`
+	FullDocument  map[string]interface{} `bson:"fullDocument"`
+	DocumentKey   map[string]interface{} `bson:"documentKey"`
+}
+
+// document returns the document to pass to the destination for the change, or nil when there is nothing to apply.
+func (e changeEvent) document() (map[string]interface{}, error) {
+	switch e.OperationType {
+	case "insert", "replace", "update":
+		// the full document of an update is looked up when the event is read, it is nil when the document has been
+		// deleted since, the delete event that follows removes it
+		return e.FullDocument, nil
+	case "delete":
+		// only the key of a deleted document is known
+		data := e.DocumentKey
+		data[common.OperationField] = common.OperationDelete
+		return data, nil
+	case "drop", "rename", "dropDatabase", "invalidate":
+		return nil, fmt.Errorf("the change stream has been closed by a %s event", e.OperationType)
+	}
+	return nil, nil
+}
+
+func (m *Mongo) IsFollowing() bool {
+	return m.follow
+}
+
+// watch opens the change stream, after the persisted resume token when there is one. A resume token file can hold an
+// operation time instead, e.g. the snapshot time of a previous migration, the stream starts at that time.
+func (m *Mongo) watch(ctx context.Context) error {
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	switch {
+	case m.startAtOperationTime != nil:
+		opts.SetStartAtOperationTime(m.startAtOperationTime)
+	case m.resumed:
+		opts.SetStartAfter(m.resumeToken)
+	case m.snapshotTime != nil:
+		// the changes made after the snapshot of the initial load are applied
+		opts.SetStartAtOperationTime(m.snapshotTime)
+		m.resumeToken = operationTimeToken(*m.snapshotTime)
+	}
+	cs, err := m.db.Watch(ctx, m.collection, bson.A{}, opts)
+	if err != nil {
+		return fmt.Errorf("error opening the change stream: %w", err)
+	}
+	m.changeStream = cs
+	if token := cs.ResumeToken(); !m.resumed && m.snapshotTime == nil && token != nil {
+		m.resumeToken = token
+	}
+	return nil
+}
+
+// Follow applies the changes of the collection until ctx is done. The resume token is persisted after every applied
+// change, so that a restarted follower carries on where it stopped.
+func (m *Mongo) Follow(ctx context.Context, apply func(data map[string]interface{}) error) error {
+	defer m.changeStream.Close(context.Background())
+	// the position of the change stream before the initial load is persisted first, so that a restart replays the
+	// changes made during the load
+	err := m.saveResumeToken(m.resumeToken)
+	if err != nil {
+		return err
+	}
+	for m.changeStream.Next(ctx) {
+		var event changeEvent
+		if err = m.changeStream.Decode(&event); err != nil {
+			return err
+		}
+		data, err := event.document()
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			m.transform(ctx, data)
+			if err = apply(data); err != nil {
+				return fmt.Errorf("error applying the %s change: %w", event.OperationType, err)
+			}
+		}
+		if token := m.changeStream.ResumeToken(); token != nil {
+			if err = m.saveResumeToken(token); err != nil {
+				return err
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		zap.S().Infof("change stream stopped, it resumes from %s on the next run", m.resumeTokenFile)
+		return nil
+	}
+	return m.changeStream.Err()
+}
+
+func (m *Mongo) loadResumeToken() error {
+	content, err := os.ReadFile(m.resumeTokenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading the resume token file: %w", err)
+	}
+	var token bson.D
+	if err = bson.UnmarshalExtJSON(content, true, &token); err != nil {
+		return fmt.Errorf("invalid resume token file %s: %w", m.resumeTokenFile, err)
+	}
+	m.resumeToken = token
+	m.resumed = true
+	if t, ok := tokenOperationTime(token); ok {
+		m.startAtOperationTime = &t
+	}
+	return nil
+}
+
+// saveResumeToken writes the token to a temporary file first, so that the token file is never left truncated.
+func (m *Mongo) saveResumeToken(token interface{}) error {
+	if token == nil {
+		return nil
+	}
+	content, err := bson.MarshalExtJSON(token, true, false)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(m.resumeTokenFile), filepath.Base(m.resumeTokenFile)+".*")
+	if err != nil {
+		return fmt.Errorf("error saving the resume token: %w", err)
+	}
+	_, err = tmp.Write(content)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), m.resumeTokenFile)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error saving the resume token: %w", err)
+	}
+	return nil
+}
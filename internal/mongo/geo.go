@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setGeoPointFields keeps the fields of the geospatial indexes, whose GeoJSON points are converted when enabled.
+func (m *Mongo) setGeoPointFields(indexes []Index) {
+	if !m.convertGeoPoints {
+		return
+	}
+	for _, index := range indexes {
+		for _, f := range index.Geo {
+			m.geoPointFields = append(m.geoPointFields, f.Field)
+		}
+	}
+}
+
+// convertGeoJSONPoints rewrites the GeoJSON points of the geo point fields of the document as {lat, lon} objects,
+// which couchbase search indexes as geopoints. The other GeoJSON objects and the legacy coordinate pairs are kept.
+func (m *Mongo) convertGeoJSONPoints(data map[string]interface{}) {
+	for _, field := range m.geoPointFields {
+		convertGeoJSONPoint(data, strings.Split(field, "."))
+	}
+}
+
+func convertGeoJSONPoint(data interface{}, path []string) interface{} {
+	switch v := data.(type) {
+	case primitive.A:
+		// the points of an array of embedded documents
+		for i := range v {
+			v[i] = convertGeoJSONPoint(v[i], path)
+		}
+		return v
+	case map[string]interface{}:
+		if len(path) == 0 {
+			if point, ok := geoJSONPoint(v); ok {
+				return point
+			}
+			return v
+		}
+		if next, ok := v[path[0]]; ok {
+			v[path[0]] = convertGeoJSONPoint(next, path[1:])
+		}
+		return v
+	}
+	return data
+}
+
+// geoJSONPoint returns the {lat, lon} object of a GeoJSON point, its coordinates are [longitude, latitude].
+func geoJSONPoint(v map[string]interface{}) (map[string]interface{}, bool) {
+	if v["type"] != "Point" {
+		return nil, false
+	}
+	coordinates, ok := v["coordinates"].(primitive.A)
+	if !ok || len(coordinates) != 2 {
+		return nil, false
+	}
+	lon, lonOk := toFloat(coordinates[0])
+	lat, latOk := toFloat(coordinates[1])
+	if !lonOk || !latOk {
+		return nil, false
+	}
+	return map[string]interface{}{"lat": lat, "lon": lon}, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
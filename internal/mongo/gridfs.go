@@ -0,0 +1,205 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// GridFSContentField is the field of the file documents listing the binary documents holding the content of the file,
+// as {"key", "size", "subtype"} objects of parts of at most the part size.
+const GridFSContentField = "content"
+
+// MaxGridFSPartSize is the maximum size of a couchbase document value, the content of the larger files is split into
+// several parts.
+const MaxGridFSPartSize = 20 * 1024 * 1024
+
+var errCorruptedGridFSFile = errors.New("corrupted GridFS file")
+
+type gridFSChunk struct {
+	N    int64  `bson:"n"`
+	Data []byte `bson:"data"`
+}
+
+// GridFSFilesCollection returns the collection holding the file documents of the GridFS bucket.
+func GridFSFilesCollection(bucket string) string {
+	return bucket + ".files"
+}
+
+func gridFSChunksCollection(bucket string) string {
+	return bucket + ".chunks"
+}
+
+// GridFSPartKey returns the key of the binary document holding the part n of the content of the file document key,
+// the key of a binary field moved to its own document by the destination.
+func GridFSPartKey(key string, n int) string {
+	return fmt.Sprintf("%s::%s[%d]", key, GridFSContentField, n)
+}
+
+// streamGridFS sends the file documents of the GridFS bucket matching the filter to analyseChan, m.gridFSFilesInFlight
+// files at a time. The files whose chunks are missing are logged and skipped.
+func (m *Mongo) streamGridFS(ctx context.Context, analyseChan chan map[string]interface{}) error {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if m.limit > 0 {
+		opts.SetLimit(m.limit)
+	}
+	if m.skip > 0 {
+		opts.SetSkip(m.skip)
+	}
+	if m.noCursorTimeout {
+		opts.SetNoCursorTimeout(true)
+	}
+	cursor, err := m.find(ctx, m.collection, m.filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(m.gridFSFilesInFlight)
+	var corrupted atomic.Int64
+	for gCtx.Err() == nil && cursor.Next(ctx) {
+		var file map[string]interface{}
+		if err = cursor.Decode(&file); err != nil {
+			break
+		}
+		g.Go(func() error {
+			err := m.streamGridFSFile(gCtx, file, analyseChan)
+			if errors.Is(err, errCorruptedGridFSFile) {
+				corrupted.Add(1)
+				zap.S().Errorf("file %v (%v) of the GridFS bucket %s is not migrated: %v", file["_id"],
+					file["filename"], m.gridFSBucket, err)
+				return nil
+			}
+			return err
+		})
+	}
+	if err == nil {
+		err = cursor.Err()
+	}
+	if gErr := g.Wait(); err == nil {
+		err = gErr
+	}
+	if n := corrupted.Load(); n > 0 {
+		zap.S().Warnf("%d files of the GridFS bucket %s are not migrated as their chunks are missing", n,
+			m.gridFSBucket)
+	}
+	return err
+}
+
+// streamGridFSFile sends the content of the file as binary part documents of at most m.gridFSPartSize bytes, each as
+// soon as its chunks are read, then the file document listing the parts. The parts sent before the file is found to
+// be corrupted are removed.
+func (m *Mongo) streamGridFSFile(ctx context.Context, file map[string]interface{}, analyseChan chan map[string]interface{}) error {
+	length, ok := toInt64(file["length"])
+	if !ok || length < 0 {
+		return fmt.Errorf("%w: invalid length %v", errCorruptedGridFSFile, file["length"])
+	}
+	key, err := m.couchbaseKey(file)
+	if err != nil {
+		return err
+	}
+	parts := primitive.A{}
+	err = m.readGridFSFile(ctx, file, length, func(data []byte) {
+		partKey := GridFSPartKey(key, len(parts))
+		analyseChan <- map[string]interface{}{
+			common.KeyField:    partKey,
+			common.BinaryField: common.Binary{Subtype: bson.TypeBinaryGeneric, Data: data},
+		}
+		parts = append(parts, map[string]interface{}{"key": partKey, "size": len(data), "subtype": bson.TypeBinaryGeneric})
+	})
+	if errors.Is(err, errCorruptedGridFSFile) {
+		for _, part := range parts {
+			analyseChan <- map[string]interface{}{
+				common.KeyField:       part.(map[string]interface{})["key"],
+				common.OperationField: common.OperationDelete,
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	file[GridFSContentField] = parts
+	analyseChan <- file
+	return nil
+}
+
+// readGridFSFile reads the chunks of the file in order and passes its content to send in parts of at most
+// m.gridFSPartSize bytes, so that at most a part of the file is held in memory.
+func (m *Mongo) readGridFSFile(ctx context.Context, file map[string]interface{}, length int64, send func([]byte)) error {
+	filter := bson.D{{Key: "files_id", Value: file["_id"]}}
+	cursor, err := m.find(ctx, gridFSChunksCollection(m.gridFSBucket), filter,
+		options.Find().SetSort(bson.D{{Key: "n", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	newPart := func(read int64) []byte {
+		return make([]byte, 0, min(int64(m.gridFSPartSize), length-read))
+	}
+	part := newPart(0)
+	var n, read int64
+	for cursor.Next(ctx) {
+		var chunk gridFSChunk
+		if err = cursor.Decode(&chunk); err != nil {
+			return err
+		}
+		if chunk.N != n {
+			return fmt.Errorf("%w: chunk %d is missing", errCorruptedGridFSFile, n)
+		}
+		if read+int64(len(chunk.Data)) > length {
+			return fmt.Errorf("%w: the chunks hold more than %d bytes", errCorruptedGridFSFile, length)
+		}
+		for data := chunk.Data; len(data) > 0; {
+			size := min(m.gridFSPartSize-len(part), len(data))
+			part = append(part, data[:size]...)
+			data = data[size:]
+			read += int64(size)
+			if len(part) == m.gridFSPartSize {
+				send(part)
+				part = newPart(read)
+			}
+		}
+		n++
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	if read != length {
+		return fmt.Errorf("%w: %d bytes read from the chunks instead of %d", errCorruptedGridFSFile, read, length)
+	}
+	if len(part) > 0 {
+		send(part)
+	}
+	return nil
+}
+
+// couchbaseKey returns the key the destination writes the document under.
+func (m *Mongo) couchbaseKey(data map[string]interface{}) (string, error) {
+	key := common.GenerateKey(m.documentKey.GetKey(), data)
+	if m.hashDocumentKey != "" {
+		return common.ComputeHash([]byte(key), m.hashDocumentKey)
+	}
+	return key, nil
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	}
+	return 0, false
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/couchbaselabs/cbmigrate/internal/common"
+	cliErrors "github.com/couchbaselabs/cbmigrate/internal/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"reflect"
@@ -24,7 +25,27 @@ type Index struct {
 	PartialExpression bson.D
 	Unique            bool
 	Sparse            bool
-	Error             error
+	// TTL is set for TTL indexes, whose documents expire ExpireAfterSeconds after the date of the key
+	TTL                bool
+	ExpireAfterSeconds int64
+	// Text is set for text indexes, which are migrated to couchbase search indexes
+	Text *TextIndex
+	// Geo is set for 2dsphere and 2d indexes, which are migrated to couchbase search indexes
+	Geo []GeoField
+	// HashedField is the key of a hashed index, the couchbase index is partitioned by its hash
+	HashedField string
+	// Wildcard is set for wildcard indexes, which are migrated to adaptive indexes
+	Wildcard *WildcardIndex
+	// CaseInsensitive is set for indexes with a case insensitive collation, whose keys are indexed as LOWER(key)
+	CaseInsensitive bool
+	Error           error
+}
+
+// WildcardIndex is the path of a wildcard index ("" for the whole document) or, for a whole document index with a
+// wildcard projection, the top level fields it includes.
+type WildcardIndex struct {
+	Path   string
+	Fields []string
 }
 type Key struct {
 	Field string
@@ -53,9 +74,54 @@ func (i IndexFieldPath) Get(key string) string {
 	return v
 }
 
+// CreateIndexQueries returns the couchbase indexes of the mongo index. A couchbase array index can only index one
+// array, so a compound index on several arrays is split into one index per array, each with all the other keys.
+func CreateIndexQueries(bucket, scope, collection string, index Index, fieldPath IndexFieldPath) []common.Index {
+	var prefixes []string
+	keysByPrefix := make(map[string][]Key)
+	for _, key := range index.Keys {
+		field := fieldPath.Get(key.Field)
+		if lastIndex := strings.LastIndex(field, "[]"); lastIndex > 0 {
+			prefix := field[:lastIndex+2]
+			if _, ok := keysByPrefix[prefix]; !ok {
+				prefixes = append(prefixes, prefix)
+			}
+			keysByPrefix[prefix] = append(keysByPrefix[prefix], key)
+		}
+	}
+	if len(prefixes) < 2 {
+		query, err := CreateIndexQuery(bucket, scope, collection, index, fieldPath)
+		return []common.Index{{Name: index.Name, Query: query, Error: err}}
+	}
+	var indexes []common.Index
+	for i, prefix := range prefixes {
+		split := index
+		split.Name = fmt.Sprintf("%s_%d", index.Name, i+1)
+		split.Keys = nil
+		for _, key := range index.Keys {
+			field := fieldPath.Get(key.Field)
+			if lastIndex := strings.LastIndex(field, "[]"); lastIndex > 0 && field[:lastIndex+2] != prefix {
+				continue
+			}
+			split.Keys = append(split.Keys, key)
+		}
+		query, err := CreateIndexQuery(bucket, scope, collection, split, fieldPath)
+		indexes = append(indexes, common.Index{Name: split.Name, Query: query, Error: err})
+	}
+	return indexes
+}
+
 func CreateIndexQuery(bucket, scope, collection string, index Index, fieldPath IndexFieldPath) (string, error) {
+	if index.Wildcard != nil {
+		if index.CaseInsensitive {
+			return "", cliErrors.NewMongoNotSupportedError("wildcard index with a collation not supported")
+		}
+		return createWildcardIndexQuery(bucket, scope, collection, index, fieldPath)
+	}
 	var arrFields []Key
 	isArrayFieldAtFistPos := false
+	// the keys are rewritten with their paths, without changing the keys of the caller
+	index.Keys = append([]Key(nil), index.Keys...)
 	for i := range index.Keys {
 		index.Keys[i].Field = fieldPath.Get(index.Keys[i].Field)
 		if strings.Index(index.Keys[i].Field, "[]") > 0 {
@@ -65,6 +131,9 @@ func CreateIndexQuery(bucket, scope, collection string, index Index, fieldPath I
 			arrFields = append(arrFields, index.Keys[i])
 		}
 	}
+	if index.CaseInsensitive && len(arrFields) > 0 {
+		return "", cliErrors.NewMongoNotSupportedError("index with a collation on array fields not supported")
+	}
 	arrayIndexExp, err := GroupAndCombine(arrFields, !index.Sparse && isArrayFieldAtFistPos)
 	if err != nil {
 		return "", err
@@ -89,6 +158,8 @@ func CreateIndexQuery(bucket, scope, collection string, index Index, fieldPath I
 				fields = append(fields, field)
 				arrIndex = false
 			}
+		case index.CaseInsensitive:
+			fields = append(fields, getLowerField(key.Field, includeMissing, key.Order))
 		default:
 			fields = append(fields, getField(key.Field, includeMissing, key.Order))
 		}
@@ -100,15 +171,50 @@ func CreateIndexQuery(bucket, scope, collection string, index Index, fieldPath I
 	if err != nil {
 		return "", err
 	}
+	partition := ""
+	if index.HashedField != "" {
+		partition = fmt.Sprintf(" PARTITION BY HASH(%s)", formatFieldReference(fieldPath.Get(index.HashedField)))
+	}
 	query := fmt.Sprintf(
-		"create index `%s` on `%s`.`%s`.`%s` (%s) %s USING GSI WITH {\"defer_build\":true}",
-		name, bucket, scope, collection, strings.Join(fields, ","), partialFilter)
+		"create index `%s` on `%s`.`%s`.`%s` (%s)%s %s USING GSI WITH {\"defer_build\":true}",
+		name, bucket, scope, collection, strings.Join(fields, ","), partition, partialFilter)
+	return query, nil
+}
+
+// createWildcardIndexQuery returns the adaptive index of a wildcard index, which indexes every field of the object.
+func createWildcardIndexQuery(bucket, scope, collection string, index Index, fieldPath IndexFieldPath) (string, error) {
+	object := "self"
+	switch {
+	case index.Wildcard.Path != "":
+		object = formatFieldReference(index.Wildcard.Path)
+	case index.Wildcard.Fields != nil:
+		pairs := make([]string, len(index.Wildcard.Fields))
+		for i, f := range index.Wildcard.Fields {
+			pairs[i] = fmt.Sprintf("%q: %s", f, formatFieldReference(f))
+		}
+		object = "{" + strings.Join(pairs, ", ") + "}"
+	}
+	reg := regexp.MustCompile(`[^A-Za-z0-9#_]`)
+	name := reg.ReplaceAllString(index.Name, "_")
+	partialFilter, err := ConvertMongoToCouchbase(index.PartialExpression, fieldPath)
+	if err != nil {
+		return "", err
+	}
+	query := fmt.Sprintf(
+		"create index `%s` on `%s`.`%s`.`%s` (DISTINCT PAIRS(%s)) %s USING GSI WITH {\"defer_build\":true}",
+		name, bucket, scope, collection, object, partialFilter)
 	return query, nil
 }
 
 func getField(field string, includeMissing bool, order int) string {
 	return fmt.Sprintf("%s%s", formatFieldReference(field), getLeadKeyAttr(order, includeMissing))
 }
+
+// getLowerField returns the key of a case insensitive index, the queries must compare LOWER() of the field to use it.
+func getLowerField(field string, includeMissing bool, order int) string {
+	return fmt.Sprintf("LOWER(%s)%s", formatFieldReference(field), getLeadKeyAttr(order, includeMissing))
+}
+
 func getLeadKeyAttr(order int, includeMissing bool) string {
 	im := INCLUDE_MISSSING
 	indexOrder := ASC
@@ -375,6 +481,19 @@ func convertOperator(field string, operators bson.D) (string, error) {
 			isTypeOperand = true
 		case "$in":
 			couchbaseOp = "IN"
+		case "$options":
+			// the options are read with $regex
+			if _, ok := operators.Map()["$regex"]; !ok {
+				return "", fmt.Errorf("$options without $regex cannot be parsed to couchbase operand")
+			}
+			continue
+		case "$nin", "$regex", "$all", "$size", "$elemMatch", "$not":
+			condition, err := convertSpecialOperator(field, op, val, operators)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, condition)
+			continue
 		default:
 			return "", fmt.Errorf("operand %s cannot be parsed to couchbase operand", op)
 		}
@@ -399,6 +518,8 @@ func convertOperator(field string, operators bson.D) (string, error) {
 
 func getValue(val interface{}, field string) string {
 	switch v := val.(type) {
+	case nil:
+		return "null"
 	case primitive.ObjectID:
 		return fmt.Sprintf("%#v", v.Hex())
 	case primitive.DateTime:
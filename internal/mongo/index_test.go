@@ -3,10 +3,12 @@ package mongo_test
 import (
 	"fmt"
 	"github.com/couchbaselabs/cbmigrate/internal/common"
+	cliErrors "github.com/couchbaselabs/cbmigrate/internal/errors"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"strings"
 )
 
@@ -269,6 +271,71 @@ var _ = Describe("mongo to couchbase index ", func() {
 		})
 	})
 
+	DescribeTable("generate partial filter expression with array and pattern operators",
+		func(partialFilter bson.D, output string) {
+			fieldPath := mongo.IndexFieldPath{}
+			fieldPath["tags"] = "tags[]"
+			fieldPath["k2.n1k1"] = "k2[].n1k1"
+			fieldPath["results"] = "results[]"
+			result, err := mongo.ConvertMongoToCouchbase(partialFilter, fieldPath)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(output))
+		},
+		Entry("$nin", bson.D{{Key: "status", Value: bson.D{{Key: "$nin", Value: bson.A{"A", "B"}}}}},
+			"WHERE (`status` IS MISSING OR `status` IS NULL OR `status` NOT IN [\"A\",\"B\"])"),
+		Entry("$nin on a nested field matches the documents without the field", bson.D{{Key: "item.status", Value: bson.D{
+			{Key: "$nin", Value: bson.A{"A"}}}}},
+			"WHERE (`item`.`status` IS MISSING OR `item`.`status` IS NULL OR `item`.`status` NOT IN [\"A\"])"),
+		Entry("$nin with null does not match the documents without the field", bson.D{{Key: "status", Value: bson.D{
+			{Key: "$nin", Value: bson.A{"A", nil}}}}},
+			"WHERE `status` NOT IN [\"A\",null]"),
+		Entry("$nin on an array", bson.D{{Key: "tags", Value: bson.D{{Key: "$nin", Value: bson.A{"red"}}}}},
+			"WHERE (`tags` IS MISSING OR `tags` IS NULL OR NOT (ANY `l1Item` IN `tags` SATISFIES (`l1Item` IN [\"red\"]) END))"),
+		Entry("$nin on an array field matches the documents without the array", bson.D{{Key: "k2.n1k1", Value: bson.D{
+			{Key: "$nin", Value: bson.A{"x"}}}}},
+			"WHERE (`k2` IS MISSING OR `k2` IS NULL OR NOT (ANY `l1Item` IN `k2` SATISFIES (`l1Item`.`n1k1` IN [\"x\"]) END))"),
+		Entry("$regex contained", bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ab"}}}},
+			"WHERE REGEXP_CONTAINS(`name`, \"^ab\")"),
+		Entry("$regex anchored with $options", bson.D{{Key: "name", Value: bson.D{
+			{Key: "$regex", Value: "^ab.*$"}, {Key: "$options", Value: "i"}}}},
+			"WHERE REGEXP_LIKE(`name`, \"(?i)ab.*\")"),
+		Entry("$regex as a regular expression", bson.D{{Key: "name", Value: bson.D{
+			{Key: "$regex", Value: primitive.Regex{Pattern: `a\.b`, Options: "s"}}}}},
+			"WHERE REGEXP_CONTAINS(`name`, \"(?s)a\\\\.b\")"),
+		Entry("$regex on an array field", bson.D{{Key: "k2.n1k1", Value: bson.D{{Key: "$regex", Value: "x"}}}},
+			"WHERE ANY `l1Item` IN `k2` SATISFIES (REGEXP_CONTAINS(`l1Item`.`n1k1`, \"x\")) END"),
+		Entry("$all", bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"red", "blue"}}}}},
+			"WHERE (ANY `l1Item` IN `tags` SATISFIES (`l1Item` = \"red\") END AND ANY `l1Item` IN `tags` SATISFIES (`l1Item` = \"blue\") END)"),
+		Entry("$all on a field not analyzed as an array", bson.D{{Key: "colors", Value: bson.D{{Key: "$all", Value: bson.A{"red"}}}}},
+			"WHERE ANY `l1Item` IN `colors` SATISFIES (`l1Item` = \"red\") END"),
+		Entry("$size", bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: int32(2)}}}},
+			"WHERE ARRAY_LENGTH(`tags`) = 2"),
+		Entry("$elemMatch with operators", bson.D{{Key: "results", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "$gte", Value: int32(80)}, {Key: "$lt", Value: int32(85)}}}}}},
+			"WHERE ANY `elem1` IN `results` SATISFIES (`elem1` >= 80 AND `elem1` < 85) END"),
+		Entry("$elemMatch with a query", bson.D{{Key: "results", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "product", Value: "xyz"}, {Key: "score", Value: bson.D{{Key: "$gte", Value: int32(8)}}}}}}}},
+			"WHERE ANY `elem1` IN `results` SATISFIES ((`elem1`.`product` = \"xyz\" AND `elem1`.`score` >= 8)) END"),
+		Entry("$not", bson.D{{Key: "price", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: 1.99}}}}}},
+			"WHERE (`price` IS MISSING OR `price` IS NULL OR NOT (`price` > 1.99))"),
+		Entry("$not with a regular expression", bson.D{{Key: "name", Value: bson.D{
+			{Key: "$not", Value: primitive.Regex{Pattern: "^p.*"}}}}},
+			"WHERE (`name` IS MISSING OR `name` IS NULL OR NOT (REGEXP_CONTAINS(`name`, \"^p.*\")))"),
+	)
+	DescribeTable("fail to generate partial filter expression",
+		func(partialFilter bson.D) {
+			_, err := mongo.ConvertMongoToCouchbase(partialFilter, mongo.IndexFieldPath{})
+			Expect(err).NotTo(BeNil())
+		},
+		Entry("$regex with the x option", bson.D{{Key: "name", Value: bson.D{
+			{Key: "$regex", Value: "a b"}, {Key: "$options", Value: "x"}}}}),
+		Entry("$options without $regex", bson.D{{Key: "name", Value: bson.D{{Key: "$options", Value: "i"}}}}),
+		Entry("$all with an empty array", bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{}}}}}),
+		Entry("$size not a number", bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: "2"}}}}),
+		Entry("$elemMatch not a document", bson.D{{Key: "tags", Value: bson.D{{Key: "$elemMatch", Value: int32(1)}}}}),
+		Entry("$not with a value", bson.D{{Key: "price", Value: bson.D{{Key: "$not", Value: int32(1)}}}}),
+	)
+
 	Describe("Create index query", func() {
 		bucket := "bucket1"
 		scope := "scope1"
@@ -385,6 +452,95 @@ var _ = Describe("mongo to couchbase index ", func() {
 				Expect(query).To(Equal(Output))
 			})
 		})
+		Context("hashed, wildcard and multi array indexes", func() {
+			It("hashed index is partitioned by the hash of its key", func() {
+				index := mongo.Index{
+					Name:        "k1_hashed",
+					Keys:        []mongo.Key{{Field: "k1.n1k1", Order: 1}},
+					HashedField: "k1.n1k1",
+				}
+				query, err := mongo.CreateIndexQuery(bucket, scope, collection, index, nil)
+				Expect(err).To(BeNil())
+				Expect(query).To(Equal("create index `k1_hashed` on `bucket1`.`scope1`.`collection1` (`k1`.`n1k1` ASC INCLUDE MISSING) " +
+					"PARTITION BY HASH(`k1`.`n1k1`)  USING GSI WITH {\"defer_build\":true}"))
+			})
+			It("wildcard indexes are adaptive indexes", func() {
+				query, err := mongo.CreateIndexQuery(bucket, scope, collection, mongo.Index{
+					Name:     "$**_1",
+					Wildcard: &mongo.WildcardIndex{},
+				}, nil)
+				Expect(err).To(BeNil())
+				Expect(query).To(Equal("create index `____1` on `bucket1`.`scope1`.`collection1` (DISTINCT PAIRS(self))  USING GSI WITH {\"defer_build\":true}"))
+
+				query, err = mongo.CreateIndexQuery(bucket, scope, collection, mongo.Index{
+					Name:     "k1.$**_1",
+					Wildcard: &mongo.WildcardIndex{Path: "k1.n1k1"},
+				}, nil)
+				Expect(err).To(BeNil())
+				Expect(query).To(ContainSubstring("(DISTINCT PAIRS(`k1`.`n1k1`))"))
+
+				query, err = mongo.CreateIndexQuery(bucket, scope, collection, mongo.Index{
+					Name:     "$**_1",
+					Wildcard: &mongo.WildcardIndex{Fields: []string{"k1", "k2"}},
+				}, nil)
+				Expect(err).To(BeNil())
+				Expect(query).To(ContainSubstring("(DISTINCT PAIRS({\"k1\": `k1`, \"k2\": `k2`}))"))
+			})
+			It("compound index on several arrays is split into one index per array", func() {
+				index := mongo.Index{
+					Name: "idx",
+					Keys: []mongo.Key{
+						{Field: "k1", Order: 1},
+						{Field: "k2.n1k1", Order: 1},
+						{Field: "k3.n1k1", Order: -1},
+						{Field: "k2.n1k2", Order: 1},
+					},
+				}
+				fieldPath := mongo.IndexFieldPath{}
+				fieldPath["k2.n1k1"] = "k2[].n1k1"
+				fieldPath["k2.n1k2"] = "k2[].n1k2"
+				fieldPath["k3.n1k1"] = "k3[].n1k1"
+
+				indexes := mongo.CreateIndexQueries(bucket, scope, collection, index, fieldPath)
+				Expect(indexes).To(HaveLen(2))
+				Expect(indexes[0].Name).To(Equal("idx_1"))
+				Expect(indexes[0].Error).To(BeNil())
+				Expect(indexes[0].Query).To(Equal("create index `idx_1` on `bucket1`.`scope1`.`collection1` " +
+					"(`k1` ASC INCLUDE MISSING,ALL ARRAY FLATTEN_KEYS(`l1Item`.`n1k1` ASC,`l1Item`.`n1k2` ASC) FOR `l1Item` IN `k2` END)  USING GSI WITH {\"defer_build\":true}"))
+				Expect(indexes[1].Name).To(Equal("idx_2"))
+				Expect(indexes[1].Error).To(BeNil())
+				Expect(indexes[1].Query).To(Equal("create index `idx_2` on `bucket1`.`scope1`.`collection1` " +
+					"(`k1` ASC INCLUDE MISSING,ALL ARRAY `l1Item`.`n1k1` FOR `l1Item` IN `k3` END)  USING GSI WITH {\"defer_build\":true}"))
+			})
+			It("case insensitive index is created on the lowercase keys", func() {
+				index := mongo.Index{
+					Name:            "email_1",
+					Keys:            []mongo.Key{{Field: "email", Order: 1}, {Field: "k1.n1k1", Order: -1}},
+					CaseInsensitive: true,
+				}
+				query, err := mongo.CreateIndexQuery(bucket, scope, collection, index, nil)
+				Expect(err).To(BeNil())
+				Expect(query).To(Equal("create index `email_1` on `bucket1`.`scope1`.`collection1` " +
+					"(LOWER(`email`) ASC INCLUDE MISSING,LOWER(`k1`.`n1k1`) DESC)  USING GSI WITH {\"defer_build\":true}"))
+			})
+			It("case insensitive index on array fields or wildcard is not supported", func() {
+				fieldPath := mongo.IndexFieldPath{}
+				fieldPath["k1.n1k1"] = "k1[].n1k1"
+				_, err := mongo.CreateIndexQuery(bucket, scope, collection, mongo.Index{
+					Name:            "k1.n1k1_1",
+					Keys:            []mongo.Key{{Field: "k1.n1k1", Order: 1}},
+					CaseInsensitive: true,
+				}, fieldPath)
+				Expect(err).To(Equal(cliErrors.NewMongoNotSupportedError("index with a collation on array fields not supported")))
+
+				_, err = mongo.CreateIndexQuery(bucket, scope, collection, mongo.Index{
+					Name:            "$**_1",
+					Wildcard:        &mongo.WildcardIndex{},
+					CaseInsensitive: true,
+				}, nil)
+				Expect(err).To(Equal(cliErrors.NewMongoNotSupportedError("wildcard index with a collation not supported")))
+			})
+		})
 		Context("failure", func() {
 			It("output data should match with the test data", func() {
 				index := mongo.Index{
@@ -8,14 +8,63 @@ import (
 	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
 	"strconv"
+	"strings"
 )
 
 type Mongo struct {
 	collection string
 	analyzer   Analyzer
 	db         repo.IRepo
+	filter     interface{}
+	projection interface{}
+	limit      int64
+	skip       int64
+	// pipeline replaces the find of the initial load by an aggregation
+	pipeline []bson.D
+	// gridFSBucket migrates the files of the GridFS bucket, with their content split into parts of gridFSPartSize,
+	// gridFSFilesInFlight files at a time
+	gridFSBucket        string
+	gridFSPartSize      int
+	gridFSFilesInFlight int
+	// documentKey and hashDocumentKey generate the couchbase key of a document, as the destination does
+	documentKey     common.ICBDocumentKey
+	hashDocumentKey string
+
+	partitions      int
+	retryAttempts   int
+	noCursorTimeout bool
+	follow          bool
+	resumeTokenFile string
+	resumeToken     interface{}
+	resumed         bool
+	// startAtOperationTime starts the change stream at an operation time, given by a resume token file holding one
+	startAtOperationTime *primitive.Timestamp
+	changeStream         repo.IChangeStream
+	// snapshot reads the initial load at a single cluster time, snapshotTime, which is also where the change stream
+	// starts
+	snapshot     bool
+	snapshotTime *primitive.Timestamp
+	// convertGeoPoints rewrites the GeoJSON points of the geoPointFields as {lat, lon} objects
+	convertGeoPoints bool
+	geoPointFields   []string
+	ttlFields        []ttlField
+	// uniqueIndexes track the values of the unique indexes during the initial load, up to uniqueTrackingLimit values
+	// per index, uniqueLookup creates a lookup document for every value
+	uniqueIndexes       []*uniqueIndex
+	uniqueLookup        bool
+	uniqueTrackingLimit int
+	// analyzerSampleSize is the size of the $sample analyzed before the migration, the migrated documents are not
+	// analyzed once it has been analyzed
+	analyzerSampleSize int
+	prePassDone        bool
+	// timeSeries groups the measurements of a time series collection into bucket documents
+	timeSeries *timeSeries
+	// references rewrites the references to the documents of other collections, or embeds those documents
+	references *references
 
 	CopyIndexes bool
 }
@@ -29,42 +78,197 @@ func NewMongo(db repo.IRepo, analyzer Analyzer) common.ISource[option.Options] {
 
 func (m *Mongo) Init(opts *option.Options, documentKey common.ICBDocumentKey) error {
 	m.collection = opts.Collection
+	if err := m.setQueryOptions(opts.QueryOptions); err != nil {
+		return err
+	}
+	m.partitions = opts.Partitions
+	m.retryAttempts = opts.RetryAttempts
+	m.noCursorTimeout = opts.NoCursorTimeout
+	m.gridFSBucket = opts.GridFSBucket
+	m.gridFSPartSize = opts.GridFSPartSize
+	if m.gridFSPartSize <= 0 {
+		m.gridFSPartSize = MaxGridFSPartSize
+	}
+	m.gridFSFilesInFlight = max(opts.GridFSFilesInFlight, 1)
+	m.documentKey = documentKey
+	m.hashDocumentKey = opts.HashDocumentKey
+	m.snapshot = opts.Snapshot
+	m.follow = opts.Follow
+	m.resumeTokenFile = opts.ResumeTokenFile
+	if m.follow {
+		if err := m.loadResumeToken(); err != nil {
+			return err
+		}
+	}
 	err := m.db.Init(opts)
 	if err != nil {
 		return err
 	}
 	m.CopyIndexes = opts.CopyIndexes
+	m.convertGeoPoints = opts.ConvertGeoJSONPoints
+	m.uniqueLookup = opts.UniqueLookup
+	m.uniqueTrackingLimit = opts.UniqueTrackingLimit
+	m.references = newReferences(opts, documentKey)
+	// the indexes are read even when they are not copied, as the TTL indexes set the expiry of the documents
+	// a user without the privilege to list them migrates the documents as they are
+	indexes, err := m.GetIndexes(context.Background())
+	if isUnauthorized(err) {
+		zap.S().Warnf("not authorized to list the indexes of %s, the TTL, unique and geospatial indexes are not "+
+			"taken into account and no index is copied: %v", m.collection, err)
+		indexes, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	m.setGeoPointFields(indexes)
+	m.setTTLFields(indexes)
+	m.setUniqueIndexes(indexes)
+	info, err := m.db.GetCollectionInfo(context.Background(), m.collection)
+	if isUnauthorized(err) {
+		zap.S().Warnf("not authorized to read the options of %s, it is migrated as a regular collection: %v",
+			m.collection, err)
+		info, err = repo.CollectionInfo{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = m.setCollectionType(info, opts); err != nil {
+		return err
+	}
 	if m.CopyIndexes {
-		indexes, err := m.GetIndexes(context.Background())
-		if err != nil {
-			return err
+		m.analyzerSampleSize = opts.AnalyzerSampleSize
+		m.analyzer.Init(indexes, documentKey, AnalyzerOptions{
+			SamplePercent:  opts.AnalyzerSamplePercent,
+			MaxOccurrences: opts.AnalyzerMaxOccurrences,
+		})
+	}
+	return nil
+}
+
+// setQueryOptions parses the extended JSON query filter and projection restricting the migrated documents.
+func (m *Mongo) setQueryOptions(qOpts option.QueryOptions) error {
+	m.filter = bson.M{}
+	if qOpts.Query != "" {
+		var filter bson.D
+		if err := bson.UnmarshalExtJSON([]byte(qOpts.Query), false, &filter); err != nil {
+			return fmt.Errorf("invalid query filter %s: %w", qOpts.Query, err)
 		}
-		m.analyzer.Init(indexes, documentKey)
+		m.filter = filter
+	}
+	if qOpts.Projection != "" {
+		var projection bson.D
+		if err := bson.UnmarshalExtJSON([]byte(qOpts.Projection), false, &projection); err != nil {
+			return fmt.Errorf("invalid projection %s: %w", qOpts.Projection, err)
+		}
+		m.projection = projection
+	}
+	m.limit = qOpts.Limit
+	m.skip = qOpts.Skip
+	if qOpts.Pipeline != "" {
+		return m.setPipeline(qOpts.Pipeline)
 	}
 	return nil
 }
 
-func (m *Mongo) analyseData(mChan chan map[string]interface{}) chan map[string]interface{} {
+func (m *Mongo) analyseData(ctx context.Context, mChan chan map[string]interface{}) chan map[string]interface{} {
 	// a new channel is used for analyzer because analyzing the data after decoding will be blocking.
 	analyseChan := make(chan map[string]interface{}, cap(mChan))
 	go func() {
 		for data := range analyseChan {
-			if m.CopyIndexes {
+			if _, ok := data[common.KeyField]; ok {
+				// the documents generated by the source are written as they are
+				mChan <- data
+				continue
+			}
+			m.transform(ctx, data)
+			lookups := m.trackUnique(data)
+			if m.CopyIndexes && !m.prePassDone {
 				m.analyzer.AnalyzeData(data)
 			}
-			mChan <- data
+			if m.timeSeries != nil {
+				for _, bucket := range m.timeSeries.add(data) {
+					mChan <- bucket
+				}
+			} else {
+				mChan <- data
+			}
+			for _, lookup := range lookups {
+				mChan <- lookup
+			}
+		}
+		if m.timeSeries != nil {
+			for _, bucket := range m.timeSeries.flush() {
+				mChan <- bucket
+			}
 		}
+		m.reportUnique()
+		m.reportReferences()
 		close(mChan)
 	}()
 	return analyseChan
 }
 
 func (m *Mongo) StreamData(ctx context.Context, mChan chan map[string]interface{}) error {
-	analyseChan := m.analyseData(mChan)
+	analyseChan := m.analyseData(ctx, mChan)
 	defer close(analyseChan)
 
+	if m.snapshot && !m.resumed {
+		// the snapshot is taken before the change stream is opened, so that the stream starts at its time
+		if err := m.takeSnapshot(ctx); err != nil {
+			return err
+		}
+	}
+	if m.follow {
+		// the change stream is opened before the initial load, so no change made during the load is missed
+		err := m.watch(ctx)
+		if err != nil {
+			return err
+		}
+		if m.resumed {
+			zap.S().Infof("resuming the change stream from %s, the initial load is skipped", m.resumeTokenFile)
+			return nil
+		}
+	}
+
+	if m.CopyIndexes && m.analyzerSampleSize > 0 {
+		if err := m.analyzeSample(ctx); err != nil {
+			return err
+		}
+	}
+	if m.pipeline != nil {
+		return m.streamPipeline(ctx, analyseChan)
+	}
+	if m.gridFSBucket != "" {
+		return m.streamGridFS(ctx, analyseChan)
+	}
+
 	opts := options.Find().SetSort(bson.D{{"_id", 1}})
-	cursor, err := m.db.Find(m.collection, ctx, bson.M{}, opts)
+	if m.timeSeries != nil {
+		opts.SetSort(m.timeSeries.sort())
+	}
+	if m.projection != nil {
+		opts.SetProjection(m.projection)
+	}
+	if m.limit > 0 {
+		opts.SetLimit(m.limit)
+	}
+	if m.skip > 0 {
+		opts.SetSkip(m.skip)
+	}
+	if m.noCursorTimeout {
+		opts.SetNoCursorTimeout(true)
+	}
+	// the analyzer only sees the documents streamed from the cursors, so the index analysis matches the documents
+	// that are actually migrated
+	if m.partitions > 1 {
+		return m.streamPartitions(ctx, opts, analyseChan)
+	}
+	return m.streamRange(ctx, m.filter, opts, analyseChan)
+}
+
+// readCursor sends the documents matching filter to analyseChan, keeping track of the last document read.
+func (m *Mongo) readCursor(ctx context.Context, filter interface{}, opts *options.FindOptions, analyseChan chan map[string]interface{}, progress *cursorProgress) error {
+	cursor, err := m.find(ctx, m.collection, filter, opts)
 	if err != nil {
 		return err
 	}
@@ -76,12 +280,16 @@ func (m *Mongo) StreamData(ctx context.Context, mChan chan map[string]interface{
 		if err != nil {
 			return err
 		}
+		progress.read(data)
 		analyseChan <- data
 	}
 	err = cursor.Err()
 	return err
 }
 
+// wildcardField is the key of a wildcard index, alone or at the end of a path
+const wildcardField = "$**"
+
 func (m *Mongo) GetIndexes(ctx context.Context) ([]Index, error) {
 	var indexes []Index
 	records, err := m.db.GetIndexes(ctx, m.collection)
@@ -101,9 +309,41 @@ func (m *Mongo) GetIndexes(ctx context.Context) ([]Index, error) {
 		if record.IsSparse() {
 			index.Sparse = true
 		}
-		// adaptive index wild card index need support
-
+		index.Unique = record.IsUnique()
+		index.CaseInsensitive = record.IsCaseInsensitive()
+		if record.IsTTL() {
+			index.TTL = true
+			index.ExpireAfterSeconds = record.GetExpireAfterSeconds()
+		}
+		if record.IsText() {
+			index.Text = textIndex(record)
+		}
 		for _, k := range record.GetKey() {
+			if index.Text != nil && (k.Key == "_fts" || k.Key == "_ftsx") {
+				// the text fields are listed by the weights
+				continue
+			}
+			switch k.Value {
+			case "2dsphere":
+				// converted GeoJSON points are indexed as geopoints
+				index.Geo = append(index.Geo, GeoField{Field: k.Key, Shape: !m.convertGeoPoints})
+				continue
+			case "2d":
+				index.Geo = append(index.Geo, GeoField{Field: k.Key})
+				continue
+			case "hashed":
+				index.HashedField = k.Key
+				index.Keys = append(index.Keys, Key{Field: k.Key, Order: 1})
+				continue
+			}
+			if k.Key == wildcardField || strings.HasSuffix(k.Key, "."+wildcardField) {
+				if len(record.GetKey()) > 1 {
+					index.Error = errors.NewMongoNotSupportedError("compound wildcard index not supported")
+					break
+				}
+				index.Wildcard = wildcardIndex(k.Key, record.GetWildcardProjection())
+				continue
+			}
 			v, err := strconv.Atoi(fmt.Sprintf("%v", k.Value))
 			if err != nil {
 				index.Error = errors.NewMongoNotSupportedError(fmt.Sprintf("error occured while getting order value %#v", err))
@@ -121,5 +361,81 @@ func (m *Mongo) GetIndexes(ctx context.Context) ([]Index, error) {
 }
 
 func (m *Mongo) GetCouchbaseIndexesQuery(bucket string, scope string, collection string) ([]common.Index, error) {
+	if m.resumed {
+		// the indexes have been created by the run that did the initial load
+		return nil, nil
+	}
 	return m.analyzer.GetCouchbaseQuery(bucket, scope, collection), nil
 }
+
+func textIndex(record repo.Indexes) *TextIndex {
+	text := &TextIndex{DefaultLanguage: record.GetDefaultLanguage()}
+	boosted := false
+	for _, w := range record.GetWeights() {
+		weight, _ := strconv.Atoi(fmt.Sprintf("%v", w.Value))
+		text.Fields = append(text.Fields, TextField{Field: w.Key, Weight: weight})
+		boosted = boosted || weight != 1
+	}
+	if boosted {
+		// search indexes have no field weights, the fields are boosted by the search queries
+		zap.S().Warnf("the weights of the text index %s are not migrated, boost the fields in the search queries "+
+			"instead, e.g. %s:word^%d", record.GetName(), text.Fields[0].Field, text.Fields[0].Weight)
+	}
+	return text
+}
+
+// transform applies the conversions of the source to a document read from the collection or from the change stream.
+func (m *Mongo) transform(ctx context.Context, data map[string]interface{}) {
+	m.convertGeoJSONPoints(data)
+	m.setExpiry(data)
+	m.resolveReferences(ctx, data)
+	convertBinaries(data)
+}
+
+// convertBinaries converts the binary values of the document to common.Binary, the destination stores the large ones
+// as binary documents.
+func convertBinaries(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.Binary:
+		return common.Binary{Subtype: v.Subtype, Data: v.Data}
+	case map[string]interface{}:
+		for k, iv := range v {
+			v[k] = convertBinaries(iv)
+		}
+	case primitive.A:
+		for i, iv := range v {
+			v[i] = convertBinaries(iv)
+		}
+	}
+	return value
+}
+
+// wildcardIndex returns the path of a wildcard index, or the top level fields of its inclusion projection. An exclusion
+// projection is not translated, the whole document is indexed.
+func wildcardIndex(key string, projection bson.D) *WildcardIndex {
+	wildcard := &WildcardIndex{Path: strings.TrimSuffix(strings.TrimSuffix(key, wildcardField), ".")}
+	if wildcard.Path != "" || projection == nil {
+		return wildcard
+	}
+	seen := make(map[string]bool)
+	for _, p := range projection {
+		included := p.Value == true
+		if v, err := strconv.Atoi(fmt.Sprintf("%v", p.Value)); err == nil {
+			included = v != 0
+		}
+		switch {
+		case !included && p.Key == "_id":
+			// _id can be excluded from an inclusion projection
+			continue
+		case !included:
+			wildcard.Fields = nil
+			return wildcard
+		}
+		field := strings.Split(p.Key, ".")[0]
+		if !seen[field] {
+			seen[field] = true
+			wildcard.Fields = append(wildcard.Fields, field)
+		}
+	}
+	return wildcard
+}
@@ -7,10 +7,20 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/mock/gomock"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/couchbaselabs/cbmigrate/internal/common"
+	cliErrors "github.com/couchbaselabs/cbmigrate/internal/errors"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo"
 	mOpts "github.com/couchbaselabs/cbmigrate/internal/mongo/option"
 	mocktest "github.com/couchbaselabs/cbmigrate/testhelper/mock"
@@ -54,7 +64,8 @@ var _ = Describe("mongo service", func() {
 				ctx := context.Background()
 				db.EXPECT().Init(opts).Return(nil)
 				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
-				analyzer.EXPECT().Init(mongoIndexes, nil).Return()
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{}).Return()
 				err := mongoService.Init(opts, nil)
 				Expect(err).To(BeNil())
 
@@ -101,6 +112,8 @@ var _ = Describe("mongo service", func() {
 				testData := []map[string]interface{}{{"a": 1}, {"b": 1}}
 				ctx := context.Background()
 				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
 				err := mongoService.Init(opts, nil)
 				Expect(err).To(BeNil())
 
@@ -137,8 +150,796 @@ var _ = Describe("mongo service", func() {
 				Ω(outputData).Should(Equal(testData))
 			})
 
+			It("indexes and collection options the user is not authorized to read are skipped", func() {
+				unauthorized := mongodriver.CommandError{Code: 13, Name: "Unauthorized", Message: "not authorized"}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, unauthorized)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, unauthorized)
+				analyzer.EXPECT().Init(nil, nil, mongo.AnalyzerOptions{}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+			})
+
+			It("partitions are read by their own cursor", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, CopyIndexes: true, Partitions: 3}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				buckets := []bson.M{
+					{"_id": bson.M{"min": 1, "max": 10}},
+					{"_id": bson.M{"min": 10, "max": 20}},
+					{"_id": bson.M{"min": 20, "max": 30}},
+				}
+				bucketCursor := mocktest.NewMockMongoICursor(ctrl)
+				db.EXPECT().Aggregate(opts.Collection, ctx, gomock.Any()).Return(bucketCursor, nil)
+				n := -1
+				bucketCursor.EXPECT().Next(ctx).Times(len(buckets) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(buckets)
+				})
+				bucketCursor.EXPECT().Decode(gomock.Any()).Times(len(buckets)).DoAndReturn(func(val interface{}) error {
+					raw, _ := bson.Marshal(buckets[n])
+					return bson.Unmarshal(raw, val)
+				})
+				bucketCursor.EXPECT().Err().Return(nil)
+				bucketCursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				var mu sync.Mutex
+				var filters []interface{}
+				db.EXPECT().Find(opts.Collection, gomock.Any(), gomock.Any(), gomock.Any()).Times(3).DoAndReturn(
+					func(collection string, ctx context.Context, filter interface{}, findOpts ...*options.FindOptions) (repo.ICursor, error) {
+						mu.Lock()
+						defer mu.Unlock()
+						filters = append(filters, filter)
+						c := mocktest.NewMockMongoICursor(ctrl)
+						c.EXPECT().Next(gomock.Any()).Return(true)
+						c.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+							reflect.ValueOf(val).Elem().Set(reflect.ValueOf(map[string]interface{}{"filter": filter}))
+							return nil
+						})
+						c.EXPECT().Next(gomock.Any()).Return(false)
+						c.EXPECT().Err().Return(nil)
+						c.EXPECT().Close(gomock.Any()).Return(nil)
+						return c, nil
+					})
+				analyzer.EXPECT().AnalyzeData(gomock.Any()).Times(3)
+
+				stream := make(chan map[string]interface{})
+				var outputData []map[string]interface{}
+				doneRoutine := make(chan bool)
+				go func() {
+					for data := range stream {
+						outputData = append(outputData, data)
+					}
+					doneRoutine <- true
+				}()
+				err = mongoService.StreamData(ctx, stream)
+				<-doneRoutine
+				Expect(err).To(BeNil())
+				Expect(outputData).To(HaveLen(3))
+				Expect(filters).To(ConsistOf(
+					bson.D{{Key: "_id", Value: bson.D{{Key: "$lt", Value: int32(10)}}}},
+					bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: int32(10)}, {Key: "$lt", Value: int32(20)}}}},
+					bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: int32(20)}}}},
+				))
+			})
+			It("cursor is resumed after the last _id read when it is not found anymore", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, RetryAttempts: 1}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				testData := []map[string]interface{}{{"_id": 1}, {"_id": 2}, {"_id": 3}}
+				resumedCursor := mocktest.NewMockMongoICursor(ctrl)
+				gomock.InOrder(
+					db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil),
+					db.EXPECT().Find(opts.Collection, ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: 2}}}}, gomock.Any()).Return(resumedCursor, nil),
+				)
+				n := -1
+				next := func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				}
+				decode := func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				}
+				cursor.EXPECT().Next(ctx).Times(2).DoAndReturn(next)
+				cursor.EXPECT().Next(ctx).Return(false)
+				cursor.EXPECT().Decode(gomock.Any()).Times(2).DoAndReturn(decode)
+				cursor.EXPECT().Err().Return(mongodriver.CommandError{Code: 43, Name: "CursorNotFound"})
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+				resumedCursor.EXPECT().Next(ctx).Times(2).DoAndReturn(next)
+				resumedCursor.EXPECT().Decode(gomock.Any()).DoAndReturn(decode)
+				resumedCursor.EXPECT().Err().Return(nil)
+				resumedCursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{})
+				var outputData []map[string]interface{}
+				doneRoutine := make(chan bool)
+				go func() {
+					for data := range stream {
+						outputData = append(outputData, data)
+					}
+					doneRoutine <- true
+				}()
+				err = mongoService.StreamData(ctx, stream)
+				<-doneRoutine
+				Expect(err).To(BeNil())
+				Expect(outputData).To(Equal(testData))
+			})
+			It("text indexes are read with their fields and language", func() {
+				textIndexes := []repo.Indexes{
+					{
+						Name:            "category_1_title_text_body_text",
+						Key:             bson.D{{Key: "category", Value: 1}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: 1}},
+						Weights:         bson.D{{Key: "body", Value: int32(1)}, {Key: "title", Value: int32(10)}},
+						DefaultLanguage: "french",
+					},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(textIndexes, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init([]mongo.Index{
+					{
+						Name: "category_1_title_text_body_text",
+						Keys: []mongo.Key{{Field: "category", Order: 1}},
+						Text: &mongo.TextIndex{
+							Fields:          []mongo.TextField{{Field: "body", Weight: 1}, {Field: "title", Weight: 10}},
+							DefaultLanguage: "french",
+						},
+					},
+				}, nil, mongo.AnalyzerOptions{}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+			})
+			It("hashed and wildcard indexes are read", func() {
+				indexes := []repo.Indexes{
+					{Name: "k1_hashed", Key: bson.D{{Key: "k1", Value: "hashed"}}},
+					{
+						Name:               "$**_1",
+						Key:                bson.D{{Key: "$**", Value: int32(1)}},
+						WildcardProjection: bson.D{{Key: "_id", Value: int32(0)}, {Key: "a", Value: int32(1)}, {Key: "b.c", Value: true}},
+					},
+					{Name: "a.$**_1", Key: bson.D{{Key: "a.$**", Value: int32(1)}}},
+					{Name: "t_1_$**_1", Key: bson.D{{Key: "t", Value: int32(1)}, {Key: "$**", Value: int32(1)}}},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(indexes, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init([]mongo.Index{
+					{Name: "k1_hashed", Keys: []mongo.Key{{Field: "k1", Order: 1}}, HashedField: "k1"},
+					{Name: "$**_1", Wildcard: &mongo.WildcardIndex{Fields: []string{"a", "b"}}},
+					{Name: "a.$**_1", Wildcard: &mongo.WildcardIndex{Path: "a"}},
+					{
+						Name:  "t_1_$**_1",
+						Keys:  []mongo.Key{{Field: "t", Order: 1}},
+						Error: cliErrors.NewMongoNotSupportedError("compound wildcard index not supported"),
+					},
+				}, nil, mongo.AnalyzerOptions{}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+			})
+			It("case insensitive collation indexes are read and the other collations are rejected", func() {
+				indexes := []repo.Indexes{
+					{Name: "email_1", Key: bson.D{{Key: "email", Value: int32(1)}}, Collation: &repo.Collation{Locale: "en", Strength: 2}},
+					{Name: "name_1", Key: bson.D{{Key: "name", Value: int32(1)}}, Collation: &repo.Collation{Locale: "simple"}},
+					{Name: "code_1", Key: bson.D{{Key: "code", Value: int32(1)}}, Collation: &repo.Collation{Locale: "fr", Strength: 3}},
+					{Name: "city_1", Key: bson.D{{Key: "city", Value: int32(1)}}, Collation: &repo.Collation{Locale: "tr", Strength: 1}},
+					{Name: "num_1", Key: bson.D{{Key: "num", Value: int32(1)}}, Collation: &repo.Collation{Locale: "en", Strength: 2, NumericOrdering: true}},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(indexes, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init([]mongo.Index{
+					{Name: "email_1", Keys: []mongo.Key{{Field: "email", Order: 1}}, CaseInsensitive: true},
+					{Name: "name_1", Keys: []mongo.Key{{Field: "name", Order: 1}}},
+					{Name: "code_1", Error: cliErrors.NewMongoNotSupportedError("index with a case sensitive collation (locale fr) not " +
+						"supported, only the case insensitive collations (strength 1 or 2) are translated")},
+					{Name: "city_1", Error: cliErrors.NewMongoNotSupportedError("index with a collation of locale tr not supported, " +
+						"its case folding differs from LOWER()")},
+					{Name: "num_1", Error: cliErrors.NewMongoNotSupportedError("index with a numericOrdering collation not supported")},
+				}, nil, mongo.AnalyzerOptions{}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+			})
+			It("GeoJSON points of the geospatial index fields are converted", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, ConvertGeoJSONPoints: true}
+				ctx := context.Background()
+				geoIndexes := []repo.Indexes{
+					{Name: "places.location_2dsphere", Key: bson.D{{Key: "places.location", Value: "2dsphere"}}},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(geoIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				polygon := map[string]interface{}{"type": "Polygon", "coordinates": bson.A{bson.A{bson.A{0.0, 0.0}}}}
+				doc := map[string]interface{}{"places": bson.A{
+					map[string]interface{}{"location": map[string]interface{}{"type": "Point", "coordinates": bson.A{2.35, 48.85}}},
+					map[string]interface{}{"location": polygon},
+				}}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				gomock.InOrder(
+					cursor.EXPECT().Next(ctx).Return(true),
+					cursor.EXPECT().Next(ctx).Return(false),
+				)
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(doc))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, 1)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				Expect(<-stream).To(Equal(map[string]interface{}{"places": bson.A{
+					map[string]interface{}{"location": map[string]interface{}{"lat": 48.85, "lon": 2.35}},
+					map[string]interface{}{"location": polygon},
+				}}))
+			})
+			It("documents of a TTL index expire after their date", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}}
+				ctx := context.Background()
+				ttlIndexes := []repo.Indexes{
+					{Name: "created_1", Key: bson.D{{Key: "created", Value: int32(1)}}, ExpireAfterSeconds: int32(3600)},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(ttlIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+				testData := []map[string]interface{}{
+					{"_id": 1, "created": primitive.NewDateTimeFromTime(created)},
+					{"_id": 2, "created": bson.A{primitive.NewDateTimeFromTime(created.Add(time.Hour)), primitive.NewDateTimeFromTime(created)}},
+					{"_id": 3, "created": "not a date"},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				n := -1
+				cursor.EXPECT().Next(ctx).Times(len(testData) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode(gomock.Any()).Times(len(testData)).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, len(testData))
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				Expect((<-stream)[common.ExpiryField]).To(BeTemporally("==", created.Add(time.Hour)))
+				Expect((<-stream)[common.ExpiryField]).To(BeTemporally("==", created.Add(time.Hour)))
+				Expect(<-stream).NotTo(HaveKey(common.ExpiryField))
+			})
+			It("lookup documents are created for the first document of every unique value", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, UniqueLookup: true}
+				ctx := context.Background()
+				uniqueIndexes := []repo.Indexes{
+					{Name: "email_1", Key: bson.D{{Key: "email", Value: int32(1)}}, Unique: true},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(uniqueIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				testData := []map[string]interface{}{
+					{"_id": 1, "email": "a@example.com"},
+					{"_id": 2, "email": "b@example.com"},
+					{"_id": 3, "email": "a@example.com"},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				n := -1
+				cursor.EXPECT().Next(ctx).Times(len(testData) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode(gomock.Any()).Times(len(testData)).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, 10)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var outputData []map[string]interface{}
+				for data := range stream {
+					outputData = append(outputData, data)
+				}
+				Expect(outputData).To(Equal([]map[string]interface{}{
+					testData[0],
+					{common.KeyField: "unique::email_1::a@example.com", "uniqueIndex": "email_1", "values": []interface{}{"a@example.com"}, "documentId": 1},
+					testData[1],
+					{common.KeyField: "unique::email_1::b@example.com", "uniqueIndex": "email_1", "values": []interface{}{"b@example.com"}, "documentId": 2},
+					testData[2],
+				}))
+			})
+			It("values past the tracking limit are not checked for duplicates", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, UniqueLookup: true,
+					UniqueTrackingLimit: 1}
+				ctx := context.Background()
+				uniqueIndexes := []repo.Indexes{
+					{Name: "email_1", Key: bson.D{{Key: "email", Value: int32(1)}}, Unique: true},
+				}
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(uniqueIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				testData := []map[string]interface{}{
+					{"_id": 1, "email": "a@example.com"},
+					{"_id": 2, "email": "b@example.com"},
+					{"_id": 3, "email": "b@example.com"},
+					{"_id": 4, "email": "a@example.com"},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				n := -1
+				cursor.EXPECT().Next(ctx).Times(len(testData) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode(gomock.Any()).Times(len(testData)).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, 10)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var lookups []interface{}
+				for data := range stream {
+					if data["uniqueIndex"] != nil {
+						lookups = append(lookups, data["documentId"])
+					}
+				}
+				// b@example.com is not tracked, every document holding it gets the lookup document
+				Expect(lookups).To(Equal([]interface{}{1, 2, 3}))
+			})
+			It("lookup keys of long values are hashed", func() {
+				key := mongo.UniqueLookupKey("name_1_city_1", []interface{}{strings.Repeat("a", 300), nil})
+				Expect(key).To(HavePrefix("unique::name_1_city_1::"))
+				Expect(len(key)).To(Equal(len("unique::name_1_city_1::") + 64))
+				Expect(mongo.UniqueLookupKey("name_1_city_1", []interface{}{"ann", nil})).To(Equal("unique::name_1_city_1::ann::null"))
+			})
+			It("references are rewritten into the couchbase key of the referenced document", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{DB: "shop", Collection: "orders"}, References: mongo.ReferenceKey,
+					ReferenceFields: map[string]string{"items.product": "products"}, ReferenceCacheSize: 10}
+				ctx := context.Background()
+				documentKey := common.NewCBDocumentKey()
+				documentKey.Set([]common.DocumentKeyPart{
+					{Value: "user", Kind: common.DkString},
+					{Value: "email", Kind: common.DkField},
+				})
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(repoIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, documentKey)
+				Expect(err).To(BeNil())
+
+				testData := []map[string]interface{}{
+					{"_id": 1, "user": map[string]interface{}{"$ref": "users", "$id": 7}, "items": bson.A{
+						map[string]interface{}{"product": 10}, map[string]interface{}{"product": bson.A{11}},
+					}},
+					{"_id": 2, "user": map[string]interface{}{"$ref": "users", "$id": 7},
+						"other": map[string]interface{}{"$ref": "users", "$id": 8, "$db": "crm"}},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				n := -1
+				cursor.EXPECT().Next(ctx).Times(len(testData) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode(gomock.Any()).Times(len(testData)).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				// the referenced documents are read once, the missing product is kept as it is
+				referenced := map[interface{}]map[string]interface{}{
+					7:  {"_id": int32(7), "email": "ann@example.com"},
+					10: {"_id": int32(10), "email": "p10"},
+				}
+				db.EXPECT().Find(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3).DoAndReturn(
+					func(collection string, ctx context.Context, filter interface{}, opts ...*options.FindOptions) (repo.ICursor, error) {
+						doc, ok := referenced[filter.(bson.D)[0].Value]
+						refCursor := mocktest.NewMockMongoICursor(ctrl)
+						refCursor.EXPECT().Next(gomock.Any()).Return(ok)
+						if ok {
+							refCursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+								reflect.ValueOf(val).Elem().Set(reflect.ValueOf(doc))
+								return nil
+							})
+						} else {
+							refCursor.EXPECT().Err().Return(nil)
+						}
+						refCursor.EXPECT().Close(gomock.Any()).Return(nil)
+						return refCursor, nil
+					})
+
+				stream := make(chan map[string]interface{}, len(testData))
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				Expect(<-stream).To(Equal(map[string]interface{}{"_id": 1,
+					"user":  map[string]interface{}{mongo.DBRefCollectionField: "users", mongo.DBRefKeyField: "user::ann@example.com"},
+					"items": bson.A{map[string]interface{}{"product": "user::p10"}, map[string]interface{}{"product": bson.A{11}}},
+				}))
+				Expect(<-stream).To(Equal(map[string]interface{}{"_id": 2,
+					"user":  map[string]interface{}{mongo.DBRefCollectionField: "users", mongo.DBRefKeyField: "user::ann@example.com"},
+					"other": map[string]interface{}{"$ref": "users", "$id": 8, "$db": "crm"},
+				}))
+			})
+			It("referenced documents are embedded up to the embed depth", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{DB: "shop", Collection: "orders"}, References: mongo.ReferenceEmbed,
+					ReferenceFields: map[string]string{"customer": "customers", "address": "addresses"}, EmbedDepth: 1}
+				ctx := context.Background()
+				documentKey := common.NewCBDocumentKey()
+				documentKey.Set([]common.DocumentKeyPart{{Value: "_id", Kind: common.DkField}})
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(repoIndexes, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, documentKey)
+				Expect(err).To(BeNil())
+
+				// the referenced documents are read with the context of the stream
+				ctx, cancel := context.WithCancel(ctx)
+				defer cancel()
+				doc := map[string]interface{}{"_id": 1, "customer": "c1"}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				gomock.InOrder(
+					cursor.EXPECT().Next(ctx).Return(true),
+					cursor.EXPECT().Next(ctx).Return(false),
+				)
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(doc))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				refCursor := mocktest.NewMockMongoICursor(ctrl)
+				db.EXPECT().Find("customers", ctx, bson.D{{Key: "_id", Value: "c1"}}, gomock.Any()).Return(refCursor, nil)
+				refCursor.EXPECT().Next(gomock.Any()).Return(true)
+				refCursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(map[string]interface{}{"_id": "c1", "name": "Ann", "address": "a1"}))
+					return nil
+				})
+				refCursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, 1)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				// the address of the embedded customer is beyond the embed depth, it is rewritten into its key
+				Expect(<-stream).To(Equal(map[string]interface{}{"_id": 1,
+					"customer": map[string]interface{}{"_id": "c1", "name": "Ann", "address": "a1"},
+				}))
+			})
+			It("measurements of a time series collection are grouped into bucket documents", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "weather"}, CopyIndexes: true, TimeSeriesBuckets: true}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{
+					Name: "weather",
+					Type: "timeseries",
+					Options: repo.CollectionOptions{
+						ExpireAfterSeconds: 3600,
+						TimeSeries:         &repo.TimeSeriesOptions{TimeField: "ts", MetaField: "sensor", Granularity: "seconds"},
+					},
+				}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				at := func(minutes int) primitive.DateTime {
+					return primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 10, minutes, 0, 0, time.UTC))
+				}
+				testData := []map[string]interface{}{
+					{"_id": 1, "sensor": "a", "ts": at(0), "temp": 20},
+					{"_id": 2, "sensor": "a", "ts": at(30), "temp": 21, "hum": 40},
+					{"_id": 3, "sensor": "a", "ts": at(65), "temp": 22},
+					{"_id": 4, "sensor": "b", "ts": at(10), "temp": 18},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).DoAndReturn(
+					func(collection string, ctx context.Context, filter interface{}, opts ...*options.FindOptions) (repo.ICursor, error) {
+						Expect(opts[0].Sort).To(Equal(bson.D{{Key: "sensor", Value: 1}, {Key: "ts", Value: 1}}))
+						return cursor, nil
+					})
+				n := -1
+				cursor.EXPECT().Next(ctx).Times(len(testData) + 1).DoAndReturn(func(ctx context.Context) bool {
+					n++
+					return n < len(testData)
+				})
+				cursor.EXPECT().Decode(gomock.Any()).Times(len(testData)).DoAndReturn(func(val interface{}) error {
+					reflect.ValueOf(val).Elem().Set(reflect.ValueOf(testData[n]))
+					return nil
+				})
+				cursor.EXPECT().Err().Return(nil)
+				cursor.EXPECT().Close(gomock.Any()).Return(nil)
+
+				stream := make(chan map[string]interface{}, len(testData))
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var outputData []map[string]interface{}
+				for data := range stream {
+					outputData = append(outputData, data)
+				}
+				hour := int64(time.Hour / time.Millisecond)
+				start := int64(at(0))
+				Expect(outputData).To(Equal([]map[string]interface{}{
+					{
+						common.KeyField: "a::" + strconv.FormatInt(start, 10), "sensor": "a",
+						mongo.TimeSeriesStartField: start, mongo.TimeSeriesEndField: start + hour - 1,
+						mongo.TimeSeriesKeysField: bson.A{"ts", "hum", "temp"},
+						mongo.TimeSeriesDataField: bson.A{bson.A{int64(at(0)), nil, 20}, bson.A{int64(at(30)), 40, 21}},
+						common.ExpiryField:        at(90).Time(),
+					},
+					{
+						common.KeyField: "a::" + strconv.FormatInt(start+hour, 10), "sensor": "a",
+						mongo.TimeSeriesStartField: start + hour, mongo.TimeSeriesEndField: start + 2*hour - 1,
+						mongo.TimeSeriesKeysField: bson.A{"ts", "temp"},
+						mongo.TimeSeriesDataField: bson.A{bson.A{int64(at(65)), 22}},
+						common.ExpiryField:        at(125).Time(),
+					},
+					{
+						common.KeyField: "b::" + strconv.FormatInt(start, 10), "sensor": "b",
+						mongo.TimeSeriesStartField: start, mongo.TimeSeriesEndField: start + hour - 1,
+						mongo.TimeSeriesKeysField: bson.A{"ts", "temp"},
+						mongo.TimeSeriesDataField: bson.A{bson.A{int64(at(10)), 18}},
+						common.ExpiryField:        at(70).Time(),
+					},
+				}))
+			})
+			It("query filter, projection, limit and skip are passed to find", func() {
+				opts := &mOpts.Options{
+					Namespace: &mOpts.Namespace{Collection: "test_col"},
+					QueryOptions: mOpts.QueryOptions{
+						Query:      `{"tenant": "acme", "age": {"$gte": 18}}`,
+						Projection: `{"audit": 0}`,
+						Limit:      10,
+						Skip:       5,
+					},
+				}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				expectedFilter := bson.D{
+					{Key: "tenant", Value: "acme"},
+					{Key: "age", Value: bson.D{{Key: "$gte", Value: int32(18)}}},
+				}
+				db.EXPECT().Find(opts.Collection, ctx, expectedFilter, gomock.Any()).DoAndReturn(
+					func(collection string, ctx context.Context, filter interface{}, findOpts ...*options.FindOptions) (repo.ICursor, error) {
+						Expect(findOpts).To(HaveLen(1))
+						Expect(findOpts[0].Projection).To(Equal(bson.D{{Key: "audit", Value: int32(0)}}))
+						Expect(*findOpts[0].Limit).To(Equal(int64(10)))
+						Expect(*findOpts[0].Skip).To(Equal(int64(5)))
+						return cursor, nil
+					})
+				cursor.EXPECT().Close(ctx).Return(nil)
+				cursor.EXPECT().Next(ctx).Return(false)
+				cursor.EXPECT().Err().Return(nil)
+
+				stream := make(chan map[string]interface{})
+				go func() {
+					for range stream {
+					}
+				}()
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+			})
+			It("documents are read from the aggregation pipeline", func() {
+				pipelineFile := filepath.Join(GinkgoT().TempDir(), "pipeline.json")
+				Expect(os.WriteFile(pipelineFile, []byte(`[{"$unwind": "$items"}, {"$project": {"items": 1}}]`), 0o600)).To(Succeed())
+				opts := &mOpts.Options{
+					Namespace:    &mOpts.Namespace{Collection: "test_col"},
+					QueryOptions: mOpts.QueryOptions{Pipeline: pipelineFile},
+				}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				expectedPipeline := []bson.D{
+					{{Key: "$unwind", Value: "$items"}},
+					{{Key: "$project", Value: bson.D{{Key: "items", Value: int32(1)}}}},
+				}
+				db.EXPECT().Aggregate(opts.Collection, ctx, expectedPipeline, gomock.Any()).DoAndReturn(
+					func(collection string, ctx context.Context, pipeline interface{}, aggOpts ...*options.AggregateOptions) (repo.ICursor, error) {
+						Expect(aggOpts).To(HaveLen(1))
+						Expect(*aggOpts[0].AllowDiskUse).To(BeTrue())
+						return cursor, nil
+					})
+				cursor.EXPECT().Close(ctx).Return(nil)
+				cursor.EXPECT().Next(ctx).Return(true)
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					*val.(*map[string]interface{}) = map[string]interface{}{"_id": "1", "items": "a"}
+					return nil
+				})
+				cursor.EXPECT().Next(ctx).Return(false)
+				cursor.EXPECT().Err().Return(nil)
+
+				stream := make(chan map[string]interface{}, 10)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				Expect(<-stream).To(Equal(map[string]interface{}{"_id": "1", "items": "a"}))
+			})
+			It("a sample is analyzed before the migration instead of the migrated documents", func() {
+				opts := &mOpts.Options{
+					Namespace:              &mOpts.Namespace{Collection: "test_col"},
+					QueryOptions:           mOpts.QueryOptions{Query: `{"tenant": "acme"}`},
+					CopyIndexes:            true,
+					AnalyzerSamplePercent:  50,
+					AnalyzerMaxOccurrences: 10,
+					AnalyzerSampleSize:     2,
+					References:             mongo.ReferenceEmbed,
+					ReferenceFields:        map[string]string{"customer": "customers"},
+					EmbedDepth:             1,
+				}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{SamplePercent: 50, MaxOccurrences: 10}).Return()
+				err := mongoService.Init(opts, nil)
+				Expect(err).To(BeNil())
+
+				sample := mocktest.NewMockMongoICursor(ctrl)
+				expectedPipeline := bson.A{
+					bson.D{{Key: "$match", Value: bson.D{{Key: "tenant", Value: "acme"}}}},
+					bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: 2}}}},
+				}
+				db.EXPECT().Aggregate(opts.Collection, ctx, expectedPipeline, gomock.Any()).Return(sample, nil)
+				sample.EXPECT().Close(ctx).Return(nil)
+				sample.EXPECT().Next(ctx).Return(true).Times(2)
+				sample.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					*val.(*map[string]interface{}) = map[string]interface{}{"_id": "s1", "customer": "c1"}
+					return nil
+				}).Times(2)
+				sample.EXPECT().Next(ctx).Return(false)
+				sample.EXPECT().Err().Return(nil)
+				// only the sampled documents are analyzed, their references are not resolved
+				analyzer.EXPECT().AnalyzeData(map[string]interface{}{"_id": "s1", "customer": "c1"}).Times(2)
+
+				db.EXPECT().Find(opts.Collection, ctx, gomock.Any(), gomock.Any()).Return(cursor, nil)
+				cursor.EXPECT().Close(ctx).Return(nil)
+				cursor.EXPECT().Next(ctx).Return(true)
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(func(val interface{}) error {
+					*val.(*map[string]interface{}) = map[string]interface{}{"_id": "1"}
+					return nil
+				})
+				cursor.EXPECT().Next(ctx).Return(false)
+				cursor.EXPECT().Err().Return(nil)
+
+				stream := make(chan map[string]interface{}, 10)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				Expect(<-stream).To(Equal(map[string]interface{}{"_id": "1"}))
+			})
+			It("files of a GridFS bucket are streamed in parts as their chunks are read", func() {
+				opts := &mOpts.Options{
+					Namespace:      &mOpts.Namespace{Collection: mongo.GridFSFilesCollection("fs")},
+					GridFSBucket:   "fs",
+					GridFSPartSize: 3,
+				}
+				ctx := context.Background()
+				documentKey := common.NewCBDocumentKey()
+				documentKey.Set([]common.DocumentKeyPart{{Value: "_id", Kind: common.DkField}})
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(context.Background(), "fs.files").Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(context.Background(), "fs.files").Return(repo.CollectionInfo{}, nil)
+				err := mongoService.Init(opts, documentKey)
+				Expect(err).To(BeNil())
+
+				decodeTo := func(doc bson.D) func(val interface{}) error {
+					return func(val interface{}) error {
+						raw, err := bson.Marshal(doc)
+						Expect(err).To(BeNil())
+						return bson.Unmarshal(raw, val)
+					}
+				}
+				complete := mocktest.NewMockMongoICursor(ctrl)
+				corrupted := mocktest.NewMockMongoICursor(ctrl)
+				db.EXPECT().Find("fs.files", ctx, bson.M{}, gomock.Any()).Return(cursor, nil)
+				cursor.EXPECT().Close(ctx).Return(nil)
+				cursor.EXPECT().Next(ctx).Return(true).Times(2)
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{
+					{Key: "_id", Value: "f1"}, {Key: "filename", Value: "a.txt"}, {Key: "length", Value: int64(5)},
+				}))
+				cursor.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{
+					{Key: "_id", Value: "f2"}, {Key: "filename", Value: "b.txt"}, {Key: "length", Value: int32(6)},
+				}))
+				cursor.EXPECT().Next(ctx).Return(false)
+				cursor.EXPECT().Err().Return(nil)
+
+				// the chunks are read by the context of the files in flight
+				db.EXPECT().Find("fs.chunks", gomock.Any(), bson.D{{Key: "files_id", Value: "f1"}}, gomock.Any()).Return(complete, nil)
+				complete.EXPECT().Close(ctx).Return(nil)
+				complete.EXPECT().Next(gomock.Any()).Return(true).Times(2)
+				complete.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{{Key: "n", Value: int32(0)}, {Key: "data", Value: []byte("ab")}}))
+				complete.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{{Key: "n", Value: int32(1)}, {Key: "data", Value: []byte("cde")}}))
+				complete.EXPECT().Next(gomock.Any()).Return(false)
+				complete.EXPECT().Err().Return(nil)
+
+				// the second chunk of f2 is missing, the file is skipped and its part already sent is removed
+				db.EXPECT().Find("fs.chunks", gomock.Any(), bson.D{{Key: "files_id", Value: "f2"}}, gomock.Any()).Return(corrupted, nil)
+				corrupted.EXPECT().Close(ctx).Return(nil)
+				corrupted.EXPECT().Next(gomock.Any()).Return(true).Times(2)
+				corrupted.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{{Key: "n", Value: int32(0)}, {Key: "data", Value: []byte("abcd")}}))
+				corrupted.EXPECT().Decode(gomock.Any()).DoAndReturn(decodeTo(bson.D{{Key: "n", Value: int32(2)}, {Key: "data", Value: []byte("ef")}}))
+
+				stream := make(chan map[string]interface{}, 10)
+				err = mongoService.StreamData(ctx, stream)
+				Expect(err).To(BeNil())
+				var docs []map[string]interface{}
+				for doc := range stream {
+					docs = append(docs, doc)
+				}
+				Expect(docs).To(Equal([]map[string]interface{}{
+					{common.KeyField: "f1::content[0]", common.BinaryField: common.Binary{Data: []byte("abc")}},
+					{common.KeyField: "f1::content[1]", common.BinaryField: common.Binary{Data: []byte("de")}},
+					{
+						"_id":      "f1",
+						"filename": "a.txt",
+						"length":   int64(5),
+						mongo.GridFSContentField: primitive.A{
+							map[string]interface{}{"key": "f1::content[0]", "size": 3, "subtype": bson.TypeBinaryGeneric},
+							map[string]interface{}{"key": "f1::content[1]", "size": 2, "subtype": bson.TypeBinaryGeneric},
+						},
+					},
+					{common.KeyField: "f2::content[0]", common.BinaryField: common.Binary{Data: []byte("abc")}},
+					{common.KeyField: "f2::content[0]", common.OperationField: common.OperationDelete},
+				}))
+			})
 		})
+
 		Context("failure", func() {
+			It("time series collections can not be followed", func() {
+				opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "weather"}, Follow: true,
+					ResumeTokenFile: filepath.Join(GinkgoT().TempDir(), "resume-token")}
+				ctx := context.Background()
+				db.EXPECT().Init(opts).Return(nil)
+				db.EXPECT().GetIndexes(ctx, opts.Collection).Return(nil, nil)
+				db.EXPECT().GetCollectionInfo(ctx, opts.Collection).Return(repo.CollectionInfo{
+					Type:    "timeseries",
+					Options: repo.CollectionOptions{TimeSeries: &repo.TimeSeriesOptions{TimeField: "ts"}},
+				}, nil)
+				err := mongoService.Init(opts, nil)
+				Expect(err).NotTo(BeNil())
+			})
+			It("invalid query filter", func() {
+				opts := &mOpts.Options{
+					Namespace:    &mOpts.Namespace{Collection: "test_col"},
+					QueryOptions: mOpts.QueryOptions{Query: `{"tenant": `},
+				}
+				err := mongoService.Init(opts, nil)
+				Expect(err).NotTo(BeNil())
+			})
 			It("error in connection initialization", func() {
 				dbConInitError := errors.New("error in initializing db connection")
 				db.EXPECT().Init(opts).Return(dbConInitError)
@@ -150,7 +951,8 @@ var _ = Describe("mongo service", func() {
 				dbFindError := errors.New("error in finding the document")
 				db.EXPECT().Init(opts).Return(nil)
 				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
-				analyzer.EXPECT().Init(mongoIndexes, nil).Return()
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{}).Return()
 				err := mongoService.Init(opts, nil)
 				Expect(err).To(BeNil())
 				ctx := context.Background()
@@ -165,7 +967,8 @@ var _ = Describe("mongo service", func() {
 				decodeError := errors.New("error in decoding the document")
 				db.EXPECT().Init(opts).Return(nil)
 				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
-				analyzer.EXPECT().Init(mongoIndexes, nil).Return()
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{}).Return()
 				err := mongoService.Init(opts, nil)
 				Expect(err).To(BeNil())
 				ctx := context.Background()
@@ -208,7 +1011,8 @@ var _ = Describe("mongo service", func() {
 				cursorError := errors.New("error in cursor")
 				db.EXPECT().Init(opts).Return(nil)
 				db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(repoIndexes, nil)
-				analyzer.EXPECT().Init(mongoIndexes, nil).Return()
+				db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+				analyzer.EXPECT().Init(mongoIndexes, nil, mongo.AnalyzerOptions{}).Return()
 				err := mongoService.Init(opts, nil)
 				Expect(err).To(BeNil())
 				ctx := context.Background()
@@ -250,4 +1054,183 @@ var _ = Describe("mongo service", func() {
 			})
 		})
 	})
+	Describe("test mongoService follow", func() {
+		var (
+			ctrl         *gomock.Controller
+			db           *mocktest.MockMongoIRepo
+			cursor       *mocktest.MockMongoICursor
+			changeStream *mocktest.MockMongoIChangeStream
+			mongoService common.ISource[mOpts.Options]
+			tokenFile    string
+		)
+		token := func(data string) bson.Raw {
+			raw, _ := bson.Marshal(bson.D{{Key: "_data", Value: data}})
+			return raw
+		}
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			db = mocktest.NewMockMongoIRepo(ctrl)
+			cursor = mocktest.NewMockMongoICursor(ctrl)
+			changeStream = mocktest.NewMockMongoIChangeStream(ctrl)
+			mongoService = mongo.NewMongo(db, mocktest.NewMockAnalyzer(ctrl))
+			tokenFile = filepath.Join(GinkgoT().TempDir(), "db.col.resume-token")
+		})
+		AfterEach(func() {
+			ctrl.Finish()
+		})
+		It("changes are applied after the initial load and the resume token is persisted", func() {
+			opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, Follow: true, ResumeTokenFile: tokenFile}
+			ctx := context.Background()
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+			db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+			err := mongoService.Init(opts, nil)
+			Expect(err).To(BeNil())
+
+			gomock.InOrder(
+				db.EXPECT().Watch(ctx, opts.Collection, gomock.Any(), gomock.Any()).Return(changeStream, nil),
+				db.EXPECT().Find(opts.Collection, ctx, bson.M{}, gomock.Any()).Return(cursor, nil),
+			)
+			changeStream.EXPECT().ResumeToken().Return(token("start"))
+			cursor.EXPECT().Close(ctx).Return(nil)
+			cursor.EXPECT().Next(ctx).Return(false)
+			cursor.EXPECT().Err().Return(nil)
+			stream := make(chan map[string]interface{})
+			go func() {
+				for range stream {
+				}
+			}()
+			err = mongoService.StreamData(ctx, stream)
+			Expect(err).To(BeNil())
+
+			events := []bson.M{
+				{"operationType": "insert", "fullDocument": bson.M{"_id": 1, "a": 1}},
+				{"operationType": "update", "fullDocument": bson.M{"_id": 1, "a": 2}},
+				{"operationType": "delete", "documentKey": bson.M{"_id": 1}},
+			}
+			n := -1
+			changeStream.EXPECT().Next(gomock.Any()).Times(len(events) + 1).DoAndReturn(func(ctx context.Context) bool {
+				n++
+				return n < len(events)
+			})
+			changeStream.EXPECT().Decode(gomock.Any()).Times(len(events)).DoAndReturn(func(val interface{}) error {
+				raw, _ := bson.Marshal(events[n])
+				return bson.Unmarshal(raw, val)
+			})
+			changeStream.EXPECT().ResumeToken().Times(len(events)).DoAndReturn(func() bson.Raw {
+				return token("event" + strconv.Itoa(n))
+			})
+			changeStream.EXPECT().Err().Return(nil)
+			changeStream.EXPECT().Close(gomock.Any()).Return(nil)
+
+			var applied []map[string]interface{}
+			err = mongoService.(common.IFollowSource).Follow(ctx, func(data map[string]interface{}) error {
+				applied = append(applied, data)
+				return nil
+			})
+			Expect(err).To(BeNil())
+			Expect(applied).To(Equal([]map[string]interface{}{
+				{"_id": int32(1), "a": int32(1)},
+				{"_id": int32(1), "a": int32(2)},
+				{"_id": int32(1), common.OperationField: common.OperationDelete},
+			}))
+			content, err := os.ReadFile(tokenFile)
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(Equal(`{"_data":"event2"}`))
+		})
+		It("the initial load is skipped when a resume token is persisted", func() {
+			Expect(os.WriteFile(tokenFile, []byte(`{"_data":"event2"}`), 0o600)).To(Succeed())
+			opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, Follow: true, ResumeTokenFile: tokenFile}
+			ctx := context.Background()
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+			db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+			err := mongoService.Init(opts, nil)
+			Expect(err).To(BeNil())
+
+			db.EXPECT().Watch(ctx, opts.Collection, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, collection string, pipeline interface{}, csOpts ...*options.ChangeStreamOptions) (repo.IChangeStream, error) {
+					Expect(csOpts[0].StartAfter).To(Equal(bson.D{{Key: "_data", Value: "event2"}}))
+					return changeStream, nil
+				})
+			changeStream.EXPECT().ResumeToken().Return(token("event2"))
+			stream := make(chan map[string]interface{})
+			err = mongoService.StreamData(ctx, stream)
+			Expect(err).To(BeNil())
+			_, open := <-stream
+			Expect(open).To(BeFalse())
+			indexes, err := mongoService.GetCouchbaseIndexesQuery("bucket", "scope", "collection")
+			Expect(err).To(BeNil())
+			Expect(indexes).To(BeEmpty())
+		})
+		It("the initial load reads a snapshot and the changes are followed from its operation time", func() {
+			opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, Snapshot: true, Follow: true,
+				ResumeTokenFile: tokenFile}
+			ctx := context.Background()
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+			db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+			err := mongoService.Init(opts, nil)
+			Expect(err).To(BeNil())
+
+			snapshotTime := primitive.Timestamp{T: 100, I: 2}
+			gomock.InOrder(
+				db.EXPECT().SnapshotTime(ctx, opts.Collection).Return(snapshotTime, nil),
+				db.EXPECT().Watch(ctx, opts.Collection, gomock.Any(), gomock.Any()).DoAndReturn(
+					func(ctx context.Context, collection string, pipeline interface{}, csOpts ...*options.ChangeStreamOptions) (repo.IChangeStream, error) {
+						Expect(*csOpts[0].StartAtOperationTime).To(Equal(snapshotTime))
+						return changeStream, nil
+					}),
+				db.EXPECT().FindAt(opts.Collection, ctx, snapshotTime, bson.M{}, gomock.Any()).Return(cursor, nil),
+			)
+			changeStream.EXPECT().ResumeToken().Return(token("start"))
+			cursor.EXPECT().Close(ctx).Return(nil)
+			cursor.EXPECT().Next(ctx).Return(false)
+			cursor.EXPECT().Err().Return(nil)
+			stream := make(chan map[string]interface{})
+			go func() {
+				for range stream {
+				}
+			}()
+			err = mongoService.StreamData(ctx, stream)
+			Expect(err).To(BeNil())
+			operationTime := `{"operationTime":{"$timestamp":{"t":100,"i":2}}}`
+			Expect(mongoService.(common.ISnapshotSource).SnapshotTime()).To(Equal(operationTime))
+
+			changeStream.EXPECT().Next(gomock.Any()).Return(false)
+			changeStream.EXPECT().Err().Return(nil)
+			changeStream.EXPECT().Close(gomock.Any()).Return(nil)
+			err = mongoService.(common.IFollowSource).Follow(ctx, func(data map[string]interface{}) error {
+				return nil
+			})
+			Expect(err).To(BeNil())
+			content, err := os.ReadFile(tokenFile)
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(Equal(operationTime))
+		})
+		It("the change stream starts at the operation time of the resume token file", func() {
+			Expect(os.WriteFile(tokenFile, []byte(`{"operationTime":{"$timestamp":{"t":100,"i":2}}}`), 0o600)).To(Succeed())
+			opts := &mOpts.Options{Namespace: &mOpts.Namespace{Collection: "test_col"}, Snapshot: true, Follow: true,
+				ResumeTokenFile: tokenFile}
+			ctx := context.Background()
+			db.EXPECT().Init(opts).Return(nil)
+			db.EXPECT().GetIndexes(context.Background(), opts.Collection).Return(nil, nil)
+			db.EXPECT().GetCollectionInfo(context.Background(), opts.Collection).Return(repo.CollectionInfo{}, nil)
+			err := mongoService.Init(opts, nil)
+			Expect(err).To(BeNil())
+
+			db.EXPECT().Watch(ctx, opts.Collection, gomock.Any(), gomock.Any()).DoAndReturn(
+				func(ctx context.Context, collection string, pipeline interface{}, csOpts ...*options.ChangeStreamOptions) (repo.IChangeStream, error) {
+					Expect(*csOpts[0].StartAtOperationTime).To(Equal(primitive.Timestamp{T: 100, I: 2}))
+					Expect(csOpts[0].StartAfter).To(BeNil())
+					return changeStream, nil
+				})
+			changeStream.EXPECT().ResumeToken().Return(nil)
+			stream := make(chan map[string]interface{})
+			err = mongoService.StreamData(ctx, stream)
+			Expect(err).To(BeNil())
+			_, open := <-stream
+			Expect(open).To(BeFalse())
+		})
+	})
 })
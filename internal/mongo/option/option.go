@@ -42,10 +42,69 @@ type Options struct {
 	// RetryWrites, if specified, sets the client default.
 	RetryWrites *bool
 
+	// DumpPath is the mongodump output (a dump directory, a .bson file or an --archive file, optionally compressed
+	// with gzip) read instead of connecting to a server.
+	DumpPath string
+
 	// Query options
 	QueryOptions
 
 	CopyIndexes bool
+
+	// ConvertGeoJSONPoints rewrites the GeoJSON points of the geospatial index fields as {lat, lon} objects, so they are
+	// indexed as couchbase search geopoints.
+	ConvertGeoJSONPoints bool
+
+	// UniqueLookup creates a lookup document for every value of the unique indexes, keyed by the value.
+	UniqueLookup bool
+	// UniqueTrackingLimit is the number of values of a unique index checked for duplicates (without limit when 0).
+	UniqueTrackingLimit int
+
+	// AnalyzerSamplePercent is the percentage of the documents the index fields are analyzed in, and
+	// AnalyzerMaxOccurrences the number of documents a field is analyzed in (without limit when 0). With
+	// AnalyzerSampleSize, a $sample of that size is analyzed before the migration instead of the migrated documents.
+	AnalyzerSamplePercent  int
+	AnalyzerMaxOccurrences int
+	AnalyzerSampleSize     int
+
+	// References resolves the DBRefs and the ReferenceFields (field path to referenced collection) of the documents,
+	// either by rewriting them into the couchbase key of the referenced document (the key is generated like the keys
+	// of the migrated documents, and hashed with HashDocumentKey) or by embedding the referenced document, up to
+	// EmbedDepth levels. At most ReferenceCacheSize referenced documents are cached.
+	References         string
+	ReferenceFields    map[string]string
+	EmbedDepth         int
+	ReferenceCacheSize int
+	HashDocumentKey    string
+
+	// TimeSeriesBuckets groups the measurements of a time series collection into a document per meta value and time
+	// window of TimeSeriesWindow seconds (by default the bucket span of the collection).
+	TimeSeriesBuckets bool
+	TimeSeriesWindow  int
+
+	// GridFSBucket migrates the files of the GridFS bucket (the Collection is the files collection of the bucket), with
+	// their content reassembled from the chunks and split into parts of at most GridFSPartSize bytes. At most
+	// GridFSFilesInFlight files are read at the same time.
+	GridFSBucket        string
+	GridFSPartSize      int
+	GridFSFilesInFlight int
+
+	// Partitions is the number of _id ranges the collection is split into, every range is read by its own cursor.
+	Partitions int
+
+	// RetryAttempts is the number of times a cursor failing with a network error or a cursor timeout is re-issued
+	// after the last _id read, NoCursorTimeout stops the server from timing out idle cursors.
+	RetryAttempts   int
+	NoCursorTimeout bool
+
+	// Snapshot reads the collection at a single cluster time, with readConcern snapshot, so the documents migrated are
+	// a point in time copy of a collection being written.
+	Snapshot bool
+
+	// Follow keeps applying the changes of the collection after the initial load, the position of the change stream
+	// is persisted in ResumeTokenFile.
+	Follow          bool
+	ResumeTokenFile string
 }
 
 // NormalizeOptionsAndURI syncs the connection string and toolOptions objects.
@@ -617,6 +676,7 @@ type WriteConcern struct {
 // QueryOptions defines the set of options to use in retrieving data from the server.
 type QueryOptions struct {
 	Query          string
+	Projection     string
 	QueryFile      string
 	SlaveOk        bool
 	ReadPreference string
@@ -625,6 +685,9 @@ type QueryOptions struct {
 	Limit          int64
 	Sort           string
 	AssertExists   bool
+	// Pipeline is an aggregation pipeline, as an extended JSON array or the path of a file holding it, whose output
+	// documents are migrated instead of the documents of the collection.
+	Pipeline string
 }
 
 func ConflictingArgsErrorFormat(optionName, uriValue, cliValue, cliOptionName string) error {
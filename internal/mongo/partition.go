@@ -0,0 +1,112 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// partitionSampleSize is the number of documents sampled per partition to find the _id split points.
+const partitionSampleSize = 100
+
+type bucket struct {
+	ID struct {
+		Min interface{} `bson:"min"`
+		Max interface{} `bson:"max"`
+	} `bson:"_id"`
+}
+
+// splitPoints returns the _id values splitting the documents matching the filter into (at most) m.partitions
+// ranges of about the same size. The boundaries are computed by the server with $bucketAuto on a $sample of the
+// _ids, so that the _ids are compared with the BSON ordering.
+func (m *Mongo) splitPoints(ctx context.Context) ([]interface{}, error) {
+	var pipeline bson.A
+	if !isEmptyFilter(m.filter) {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: m.filter}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: m.partitions * partitionSampleSize}}}},
+		bson.D{{Key: "$bucketAuto", Value: bson.D{
+			{Key: "groupBy", Value: "$_id"},
+			{Key: "buckets", Value: m.partitions},
+		}}},
+	)
+	cursor, err := m.db.Aggregate(m.collection, ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error computing the partitions: %w", err)
+	}
+	defer cursor.Close(context.Background())
+	var points []interface{}
+	first := true
+	for cursor.Next(ctx) {
+		var b bucket
+		if err = cursor.Decode(&b); err != nil {
+			return nil, fmt.Errorf("error computing the partitions: %w", err)
+		}
+		// the first range has no lower bound, so that the _ids lower than the sampled ones are read too
+		if !first {
+			points = append(points, b.ID.Min)
+		}
+		first = false
+	}
+	return points, cursor.Err()
+}
+
+func isEmptyFilter(filter interface{}) bool {
+	switch f := filter.(type) {
+	case bson.M:
+		return len(f) == 0
+	case bson.D:
+		return len(f) == 0
+	}
+	return filter == nil
+}
+
+// partitionFilters returns the filters of the _id ranges delimited by the split points, each of them combined with
+// the query filter.
+func (m *Mongo) partitionFilters(points []interface{}) []interface{} {
+	filters := make([]interface{}, 0, len(points)+1)
+	for i := 0; i <= len(points); i++ {
+		var idRange bson.D
+		if i > 0 {
+			idRange = append(idRange, bson.E{Key: "$gte", Value: points[i-1]})
+		}
+		if i < len(points) {
+			idRange = append(idRange, bson.E{Key: "$lt", Value: points[i]})
+		}
+		var filter = m.filter
+		if len(idRange) > 0 {
+			filter = bson.D{{Key: "_id", Value: idRange}}
+			if !isEmptyFilter(m.filter) {
+				filter = bson.D{{Key: "$and", Value: bson.A{m.filter, filter}}}
+			}
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// streamPartitions reads every _id range with its own cursor. The first error stops the other cursors.
+func (m *Mongo) streamPartitions(ctx context.Context, opts *options.FindOptions, analyseChan chan map[string]interface{}) error {
+	points, err := m.splitPoints(ctx)
+	if err != nil {
+		return err
+	}
+	filters := m.partitionFilters(points)
+	zap.S().Infof("reading the collection %s in %d partitions", m.collection, len(filters))
+	g, gCtx := errgroup.WithContext(ctx)
+	for i, filter := range filters {
+		g.Go(func() error {
+			err := m.streamRange(gCtx, filter, opts, analyseChan)
+			if err != nil {
+				return fmt.Errorf("error reading partition %d: %w", i, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
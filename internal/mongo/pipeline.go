@@ -0,0 +1,56 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// setPipeline parses the aggregation pipeline, given inline as an extended JSON array of stages or as the path of a
+// file holding it.
+func (m *Mongo) setPipeline(pipeline string) error {
+	text := strings.TrimSpace(pipeline)
+	if !strings.HasPrefix(text, "[") {
+		content, err := os.ReadFile(text)
+		if err != nil {
+			return fmt.Errorf("error reading the pipeline file %s: %w", text, err)
+		}
+		text = string(content)
+	}
+	// extended JSON can only be unmarshalled into a document
+	var wrapper struct {
+		Pipeline []bson.D `bson:"pipeline"`
+	}
+	if err := bson.UnmarshalExtJSON([]byte(`{"pipeline": `+text+`}`), false, &wrapper); err != nil {
+		return fmt.Errorf("invalid pipeline %s: %w", pipeline, err)
+	}
+	if len(wrapper.Pipeline) == 0 {
+		return fmt.Errorf("the pipeline %s has no stage", pipeline)
+	}
+	m.pipeline = wrapper.Pipeline
+	return nil
+}
+
+// streamPipeline sends the documents output by the aggregation pipeline to analyseChan. The stages may use more
+// memory than the server allows per stage, so they are allowed to write to temporary files. The output of a pipeline
+// is not sorted by _id, so a failed cursor can not be resumed.
+func (m *Mongo) streamPipeline(ctx context.Context, analyseChan chan map[string]interface{}) error {
+	cursor, err := m.aggregate(ctx, m.pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(ctx) {
+		var data map[string]interface{}
+		if err = cursor.Decode(&data); err != nil {
+			return err
+		}
+		analyseChan <- data
+	}
+	return cursor.Err()
+}
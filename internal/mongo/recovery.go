@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	mongodriver "go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	unauthorizedCode   = 13
+	cursorNotFoundCode = 43
+)
+
+// cursorProgress tracks how far a cursor sorted by _id has been read.
+type cursorProgress struct {
+	count  int64
+	lastID interface{}
+}
+
+func (p *cursorProgress) read(data map[string]interface{}) {
+	p.count++
+	p.lastID = data["_id"]
+}
+
+// isRecoverable reports whether the query can be re-issued after err, that is when the connection has been lost or
+// the cursor has been closed by the server.
+func isRecoverable(err error) bool {
+	if mongodriver.IsNetworkError(err) || mongodriver.IsTimeout(err) {
+		return true
+	}
+	var se mongodriver.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(cursorNotFoundCode)
+}
+
+// isUnauthorized reports whether err is the refusal of a command the user has no privilege for.
+func isUnauthorized(err error) bool {
+	var se mongodriver.ServerError
+	return errors.As(err, &se) && se.HasErrorCode(unauthorizedCode)
+}
+
+// streamRange sends the documents matching filter to analyseChan. As the documents are sorted by _id, a cursor
+// failing with a recoverable error is re-issued for the documents after the last _id read, up to m.retryAttempts
+// times in a row.
+func (m *Mongo) streamRange(ctx context.Context, filter interface{}, opts *options.FindOptions, analyseChan chan map[string]interface{}) error {
+	progress := &cursorProgress{}
+	attempt := 0
+	rFilter, rOpts := filter, opts
+	for {
+		readBefore := progress.count
+		err := m.readCursor(ctx, rFilter, rOpts, analyseChan, progress)
+		if err == nil {
+			return nil
+		}
+		if opts.Limit != nil && *opts.Limit > 0 && progress.count >= *opts.Limit {
+			// every document has been read before the failure
+			return nil
+		}
+		if progress.count > readBefore {
+			// the attempts are only counted while the cursor makes no progress
+			attempt = 0
+		}
+		if ctx.Err() != nil || !isRecoverable(err) || attempt >= m.retryAttempts {
+			return err
+		}
+		if progress.count > 0 && progress.lastID == nil {
+			return fmt.Errorf("the cursor can not be resumed without the _id of the documents: %w", err)
+		}
+		attempt++
+		zap.S().Warnf("cursor of the collection %s failed with %v, resuming after _id %v (attempt %d of %d)",
+			m.collection, err, progress.lastID, attempt, m.retryAttempts)
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-ctx.Done():
+			return err
+		}
+		rFilter, rOpts = resumeQuery(filter, opts, progress)
+	}
+}
+
+// resumeQuery returns the query reading the documents of filter after the ones already read.
+func resumeQuery(filter interface{}, opts *options.FindOptions, progress *cursorProgress) (interface{}, *options.FindOptions) {
+	rOpts := options.MergeFindOptions(opts)
+	if progress.count == 0 {
+		return filter, rOpts
+	}
+	// the skipped documents are before the last _id read
+	rOpts.Skip = nil
+	if opts.Limit != nil {
+		rOpts.SetLimit(*opts.Limit - progress.count)
+	}
+	after := bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: progress.lastID}}}}
+	if isEmptyFilter(filter) {
+		return after, rOpts
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, after}}}, rOpts
+}
@@ -0,0 +1,259 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	// ReferenceKey rewrites the references into the couchbase key of the referenced document
+	ReferenceKey = "key"
+	// ReferenceEmbed replaces the references by the referenced document
+	ReferenceEmbed = "embed"
+)
+
+// DBRefCollectionField and DBRefKeyField are the fields of the object a DBRef is rewritten into with ReferenceKey.
+const (
+	DBRefCollectionField = "collection"
+	DBRefKeyField        = "key"
+)
+
+type references struct {
+	mode string
+	// fields are the paths of the fields holding the _id of a document of another collection, and that collection
+	fields      map[string]string
+	depth       int
+	db          string
+	documentKey common.ICBDocumentKey
+	hash        string
+	cache       *referenceCache
+	unresolved  int
+}
+
+func newReferences(opts *option.Options, documentKey common.ICBDocumentKey) *references {
+	if opts.References == "" {
+		return nil
+	}
+	return &references{
+		mode:        opts.References,
+		fields:      opts.ReferenceFields,
+		depth:       opts.EmbedDepth,
+		db:          opts.Namespace.DB,
+		documentKey: documentKey,
+		hash:        opts.HashDocumentKey,
+		cache:       newReferenceCache(opts.ReferenceCacheSize),
+	}
+}
+
+// referenceCache holds the referenced documents fetched last, a nil document is a reference to a missing document.
+type referenceCache struct {
+	size  int
+	docs  map[string]bson.Raw
+	order []string
+}
+
+func newReferenceCache(size int) *referenceCache {
+	return &referenceCache{size: size, docs: make(map[string]bson.Raw)}
+}
+
+func (c *referenceCache) get(key string) (bson.Raw, bool) {
+	doc, ok := c.docs[key]
+	return doc, ok
+}
+
+// add caches the document, the oldest document is evicted when the cache is full.
+func (c *referenceCache) add(key string, doc bson.Raw) {
+	if c.size <= 0 {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.docs, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.docs[key] = doc
+	c.order = append(c.order, key)
+}
+
+// resolveReferences rewrites or embeds the references of the document.
+func (m *Mongo) resolveReferences(ctx context.Context, data map[string]interface{}) {
+	if m.references == nil {
+		return
+	}
+	m.resolveDocument(ctx, data, "", 0)
+}
+
+func (m *Mongo) resolveDocument(ctx context.Context, doc map[string]interface{}, prefix string, level int) {
+	for k, v := range doc {
+		if prefix == "" && k == "_id" {
+			continue
+		}
+		doc[k] = m.resolveValue(ctx, v, joinPath(prefix, k), level)
+	}
+}
+
+func joinPath(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	return prefix + "." + field
+}
+
+// resolveValue returns the value with its references resolved, path is the path of the value in the document, the
+// array indexes excluded.
+func (m *Mongo) resolveValue(ctx context.Context, value interface{}, path string, level int) interface{} {
+	if collection, id, db, ok := dbRef(value); ok {
+		if db != "" && db != m.references.db {
+			// only the collections of the migrated database are read
+			m.references.unresolved++
+			return value
+		}
+		return m.resolveReference(ctx, collection, id, level, true, value)
+	}
+	if collection, ok := m.references.fields[path]; ok {
+		if values, isArray := value.(primitive.A); isArray {
+			for i, v := range values {
+				values[i] = m.resolveReference(ctx, collection, v, level, false, v)
+			}
+			return values
+		}
+		return m.resolveReference(ctx, collection, value, level, false, value)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m.resolveDocument(ctx, v, path, level)
+	case primitive.D:
+		for i := range v {
+			v[i].Value = m.resolveValue(ctx, v[i].Value, joinPath(path, v[i].Key), level)
+		}
+	case primitive.A:
+		for i := range v {
+			v[i] = m.resolveValue(ctx, v[i], path, level)
+		}
+	}
+	return value
+}
+
+// dbRef returns the collection, the _id and the database of a DBRef.
+func dbRef(value interface{}) (string, interface{}, string, bool) {
+	var ref map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		ref = v
+	case primitive.D:
+		ref = v.Map()
+	default:
+		return "", nil, "", false
+	}
+	collection, ok := ref["$ref"].(string)
+	id, hasID := ref["$id"]
+	if !ok || !hasID {
+		return "", nil, "", false
+	}
+	db, _ := ref["$db"].(string)
+	return collection, id, db, true
+}
+
+// resolveReference returns the referenced document when it is embedded, or the couchbase key of the document (as an
+// object also naming the collection for a DBRef). The original value is kept when the document can not be found.
+func (m *Mongo) resolveReference(ctx context.Context, collection string, id interface{}, level int, isDBRef bool, original interface{}) interface{} {
+	r := m.references
+	if r.mode == ReferenceEmbed && level < r.depth {
+		doc := m.referencedDocument(ctx, collection, id)
+		if doc == nil {
+			r.unresolved++
+			return original
+		}
+		m.resolveDocument(ctx, doc, "", level+1)
+		return doc
+	}
+	key, ok := m.referenceKey(ctx, collection, id)
+	if !ok {
+		r.unresolved++
+		return original
+	}
+	if isDBRef {
+		return map[string]interface{}{DBRefCollectionField: collection, DBRefKeyField: key}
+	}
+	return key
+}
+
+// referenceKey returns the couchbase key of the referenced document. The document is only read when the key is
+// generated from other fields than _id.
+func (m *Mongo) referenceKey(ctx context.Context, collection string, id interface{}) (string, bool) {
+	parts := m.references.documentKey.GetKey()
+	data := map[string]interface{}{"_id": id}
+	for _, part := range parts {
+		if part.Kind == common.DkField && part.Value != "_id" {
+			data = m.referencedDocument(ctx, collection, id)
+			break
+		}
+	}
+	if data == nil {
+		return "", false
+	}
+	key := common.GenerateKey(parts, data)
+	if m.references.hash != "" {
+		hashed, err := common.ComputeHash([]byte(key), m.references.hash)
+		if err != nil {
+			zap.S().Warnf("the reference to %v of %s is not rewritten: %v", id, collection, err)
+			return "", false
+		}
+		key = hashed
+	}
+	return key, true
+}
+
+// referencedDocument returns a copy of the referenced document, or nil when it does not exist.
+func (m *Mongo) referencedDocument(ctx context.Context, collection string, id interface{}) map[string]interface{} {
+	cacheKey, err := bson.MarshalExtJSON(bson.D{{Key: collection, Value: id}}, true, false)
+	if err != nil {
+		return nil
+	}
+	raw, ok := m.references.cache.get(string(cacheKey))
+	if !ok {
+		raw, err = m.fetchReference(ctx, collection, id)
+		if err != nil {
+			zap.S().Warnf("error reading the document %v of %s referenced by %s: %v", id, collection, m.collection, err)
+			return nil
+		}
+		m.references.cache.add(string(cacheKey), raw)
+	}
+	if raw == nil {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+func (m *Mongo) fetchReference(ctx context.Context, collection string, id interface{}) (bson.Raw, error) {
+	cursor, err := m.db.Find(collection, ctx, bson.D{{Key: "_id", Value: id}}, options.Find().SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if !cursor.Next(ctx) {
+		return nil, cursor.Err()
+	}
+	var doc map[string]interface{}
+	if err = cursor.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return bson.Marshal(doc)
+}
+
+func (m *Mongo) reportReferences() {
+	if m.references != nil && m.references.unresolved > 0 {
+		zap.S().Warnf("%d references of %s could not be resolved (missing document or other database), they are "+
+			"migrated as they are", m.references.unresolved, m.collection)
+		m.references.unresolved = 0
+	}
+}
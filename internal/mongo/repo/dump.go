@@ -0,0 +1,535 @@
+package repo
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// archiveMagicNumber starts the archives written by mongodump --archive
+	archiveMagicNumber = 0x8199e26d
+	// archiveTerminator ends the prelude and every namespace block of an archive
+	archiveTerminator = -1
+	maxBSONSize       = 48 * 1024 * 1024
+)
+
+var errDumpNotSupported = errors.New("not supported on a mongodump output")
+
+// dumpCollection is a collection of a mongodump output, the documents of a directory dump are in bsonFile and its
+// metadata (the indexes) in metadataFile, those of an archive are read from the archive.
+type dumpCollection struct {
+	bsonFile     string
+	metadataFile string
+	metadata     string
+	kind         string
+}
+
+// DumpRepo reads the collections of a mongodump output instead of a server: a directory written by mongodump (the
+// dump root or the directory of the database), a single .bson file, or an archive written with --archive. The files
+// and the archive can be compressed with gzip (--gzip).
+type DumpRepo struct {
+	mu          sync.Mutex
+	path        string
+	db          string
+	archive     bool
+	collections map[string]*dumpCollection
+}
+
+func NewDumpRepo() IRepo {
+	return &DumpRepo{}
+}
+
+// Init lists the collections of the dump. The repo can be shared by several sources, so it only reads the dump once.
+func (d *DumpRepo) Init(opts *option.Options) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.collections != nil {
+		return nil
+	}
+	d.path = opts.DumpPath
+	d.db = opts.Namespace.DB
+	d.collections = make(map[string]*dumpCollection)
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("error reading the dump: %w", err)
+	}
+	if info.IsDir() {
+		return d.initDirectory()
+	}
+	r, closer, err := openDumpFile(d.path)
+	if err != nil {
+		return err
+	}
+	defer closer()
+	if isArchive(r) {
+		d.archive = true
+		return d.initArchive(r)
+	}
+	// a single collection file, <collection>.bson[.gz], with its metadata file next to it
+	if d.db == "" {
+		d.db = filepath.Base(filepath.Dir(d.path))
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(d.path), ".gz"), ".bson")
+	d.collections[name] = &dumpCollection{bsonFile: d.path, kind: "collection"}
+	for _, ext := range []string{".metadata.json", ".metadata.json.gz"} {
+		if f := filepath.Join(filepath.Dir(d.path), name+ext); fileExists(f) {
+			d.collections[name].metadataFile = f
+		}
+	}
+	return nil
+}
+
+// initDirectory lists the collections of the database directory, which is the dump directory itself when it holds
+// collection files, or its sub directory of the database.
+func (d *DumpRepo) initDirectory() error {
+	dir := d.path
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading the dump: %w", err)
+	}
+	if !hasCollectionFiles(entries) {
+		if d.db == "" {
+			return fmt.Errorf("the database of the dump %s must be given", d.path)
+		}
+		dir = filepath.Join(d.path, d.db)
+		if entries, err = os.ReadDir(dir); err != nil {
+			return fmt.Errorf("error reading the database %s of the dump: %w", d.db, err)
+		}
+	} else if d.db == "" {
+		d.db = filepath.Base(dir)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		var collection string
+		var metadata bool
+		switch {
+		case strings.HasSuffix(name, ".bson"), strings.HasSuffix(name, ".bson.gz"):
+			collection = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".bson")
+		case strings.HasSuffix(name, ".metadata.json"), strings.HasSuffix(name, ".metadata.json.gz"):
+			collection = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".metadata.json")
+			metadata = true
+		default:
+			continue
+		}
+		c, ok := d.collections[collection]
+		if !ok {
+			// a view only has a metadata file
+			c = &dumpCollection{kind: "view"}
+			d.collections[collection] = c
+		}
+		if metadata {
+			c.metadataFile = filepath.Join(dir, name)
+			continue
+		}
+		c.bsonFile = filepath.Join(dir, name)
+		c.kind = "collection"
+	}
+	return nil
+}
+
+func hasCollectionFiles(entries []os.DirEntry) bool {
+	for _, entry := range entries {
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".bson") || strings.HasSuffix(entry.Name(), ".bson.gz")) {
+			return true
+		}
+	}
+	return false
+}
+
+type archivePrelude struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	Metadata   string `bson:"metadata"`
+	Type       string `bson:"type"`
+}
+
+// initArchive reads the metadata of the collections of the database from the prelude of the archive.
+func (d *DumpRepo) initArchive(r *bufio.Reader) error {
+	if _, err := r.Discard(4); err != nil {
+		return fmt.Errorf("error reading the dump archive: %w", err)
+	}
+	// the archive header
+	if _, err := readBSON(r, false); err != nil {
+		return fmt.Errorf("error reading the dump archive: %w", err)
+	}
+	databases := make(map[string]bool)
+	var collections []archivePrelude
+	for {
+		raw, err := readBSON(r, true)
+		if err != nil {
+			return fmt.Errorf("error reading the dump archive: %w", err)
+		}
+		if raw == nil {
+			break
+		}
+		var prelude archivePrelude
+		if err = bson.Unmarshal(raw, &prelude); err != nil {
+			return fmt.Errorf("error reading the dump archive: %w", err)
+		}
+		databases[prelude.Database] = true
+		collections = append(collections, prelude)
+	}
+	if d.db == "" {
+		if len(databases) != 1 {
+			return fmt.Errorf("the dump archive %s holds %d databases, the database must be given", d.path, len(databases))
+		}
+		for db := range databases {
+			d.db = db
+		}
+	}
+	for _, c := range collections {
+		if c.Database != d.db {
+			continue
+		}
+		kind := c.Type
+		if kind == "" {
+			kind = "collection"
+		}
+		d.collections[c.Collection] = &dumpCollection{metadata: c.Metadata, kind: kind}
+	}
+	return nil
+}
+
+func (d *DumpRepo) collection(name string) (*dumpCollection, error) {
+	c, ok := d.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("collection %s not found in the dump of the database %s", name, d.db)
+	}
+	return c, nil
+}
+
+// Find reads the documents of the collection in the order of the dump. The dump can not be queried, only the limit
+// and the skip of opts are applied.
+func (d *DumpRepo) Find(collection string, ctx context.Context, filter interface{}, opts ...*options.FindOptions) (ICursor, error) {
+	c, err := d.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+	if !isEmptyDocument(filter) {
+		return nil, fmt.Errorf("query filter: %w", errDumpNotSupported)
+	}
+	o := options.MergeFindOptions(opts...)
+	if o.Projection != nil {
+		return nil, fmt.Errorf("projection: %w", errDumpNotSupported)
+	}
+	cursor := &dumpCursor{}
+	if o.Skip != nil {
+		cursor.skip = *o.Skip
+	}
+	if o.Limit != nil {
+		cursor.limit = *o.Limit
+	}
+	path := c.bsonFile
+	if d.archive {
+		path = d.path
+		cursor.db, cursor.collection = d.db, collection
+	}
+	if path == "" {
+		// a view has no documents
+		return cursor, nil
+	}
+	cursor.reader, cursor.closer, err = openDumpFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if d.archive {
+		if err = skipArchivePrelude(cursor.reader); err != nil {
+			cursor.closer()
+			return nil, err
+		}
+	}
+	return cursor, nil
+}
+
+func isEmptyDocument(filter interface{}) bool {
+	switch f := filter.(type) {
+	case bson.M:
+		return len(f) == 0
+	case bson.D:
+		return len(f) == 0
+	}
+	return filter == nil
+}
+
+func (d *DumpRepo) Aggregate(collection string, ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	return nil, fmt.Errorf("aggregation pipeline: %w", errDumpNotSupported)
+}
+
+// dumpMetadata is the content of the metadata.json of a collection.
+type dumpMetadata struct {
+	Type    string            `bson:"type"`
+	Options CollectionOptions `bson:"options"`
+	Indexes []Indexes         `bson:"indexes"`
+}
+
+// readMetadata returns the metadata of the collection, from its metadata.json file or from the archive.
+func (d *DumpRepo) readMetadata(collection string) (dumpMetadata, error) {
+	var parsed dumpMetadata
+	c, err := d.collection(collection)
+	if err != nil {
+		return parsed, err
+	}
+	metadata := []byte(c.metadata)
+	if c.metadataFile != "" {
+		r, closer, err := openDumpFile(c.metadataFile)
+		if err != nil {
+			return parsed, err
+		}
+		metadata, err = io.ReadAll(r)
+		closer()
+		if err != nil {
+			return parsed, fmt.Errorf("error reading the metadata of the collection %s: %w", collection, err)
+		}
+	}
+	if len(metadata) == 0 {
+		return parsed, nil
+	}
+	if err = bson.UnmarshalExtJSON(metadata, false, &parsed); err != nil {
+		return parsed, fmt.Errorf("invalid metadata of the collection %s: %w", collection, err)
+	}
+	return parsed, nil
+}
+
+// GetIndexes returns the indexes of the metadata.json of the collection.
+func (d *DumpRepo) GetIndexes(ctx context.Context, collection string) ([]Indexes, error) {
+	metadata, err := d.readMetadata(collection)
+	return metadata.Indexes, err
+}
+
+// GetCollectionInfo returns the type and the options of the metadata.json of the collection.
+func (d *DumpRepo) GetCollectionInfo(ctx context.Context, collection string) (CollectionInfo, error) {
+	metadata, err := d.readMetadata(collection)
+	if err != nil {
+		return CollectionInfo{}, err
+	}
+	kind := metadata.Type
+	if kind == "" {
+		kind = d.collections[collection].kind
+	}
+	return CollectionInfo{Name: collection, Type: kind, Options: metadata.Options}, nil
+}
+
+// ListCollections returns the collections and the views of the database in the dump by name, the system collections
+// excluded.
+func (d *DumpRepo) ListCollections(ctx context.Context) ([]CollectionInfo, error) {
+	var collections []CollectionInfo
+	for name, c := range d.collections {
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+		collections = append(collections, CollectionInfo{Name: name, Type: c.kind})
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+func (d *DumpRepo) Watch(ctx context.Context, collection string, pipeline interface{}, opts ...*options.ChangeStreamOptions) (IChangeStream, error) {
+	return nil, fmt.Errorf("change stream: %w", errDumpNotSupported)
+}
+
+func (d *DumpRepo) SnapshotTime(ctx context.Context, collection string) (primitive.Timestamp, error) {
+	return primitive.Timestamp{}, fmt.Errorf("snapshot read: %w", errDumpNotSupported)
+}
+
+func (d *DumpRepo) FindAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, filter interface{}, opts ...*options.FindOptions) (ICursor, error) {
+	return nil, fmt.Errorf("snapshot read: %w", errDumpNotSupported)
+}
+
+func (d *DumpRepo) AggregateAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	return nil, fmt.Errorf("snapshot read: %w", errDumpNotSupported)
+}
+
+// dumpCursor reads the documents of a .bson file, or those of a collection of an archive, where the documents of the
+// collections are written in blocks, each made of a namespace header, the documents and a terminator.
+type dumpCursor struct {
+	reader     *bufio.Reader
+	closer     func()
+	db         string
+	collection string
+	// inBlock is set while the documents of a block are read, inCollection when the block is of the collection
+	inBlock      bool
+	inCollection bool
+	skip         int64
+	limit        int64
+	read         int64
+	current      bson.Raw
+	err          error
+}
+
+type archiveNamespace struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"collection"`
+	EOF        bool   `bson:"EOF"`
+}
+
+func (c *dumpCursor) Next(ctx context.Context) bool {
+	for c.reader != nil && c.err == nil {
+		if c.err = ctx.Err(); c.err != nil {
+			return false
+		}
+		if c.limit > 0 && c.read >= c.limit {
+			return false
+		}
+		raw, err := c.nextDocument()
+		if err != nil || raw == nil {
+			c.err = err
+			return false
+		}
+		if c.skip > 0 {
+			c.skip--
+			continue
+		}
+		c.current = raw
+		c.read++
+		return true
+	}
+	return false
+}
+
+// nextDocument returns the next document of the collection, or nil at the end of the dump.
+func (c *dumpCursor) nextDocument() (bson.Raw, error) {
+	if c.collection == "" {
+		raw, err := readBSON(c.reader, false)
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return raw, err
+	}
+	for {
+		if !c.inBlock {
+			raw, err := readBSON(c.reader, false)
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading the dump archive: %w", err)
+			}
+			var ns archiveNamespace
+			if err = bson.Unmarshal(raw, &ns); err != nil {
+				return nil, fmt.Errorf("error reading the dump archive: %w", err)
+			}
+			c.inBlock = true
+			c.inCollection = ns.Database == c.db && ns.Collection == c.collection && !ns.EOF
+			continue
+		}
+		raw, err := readBSON(c.reader, true)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the dump archive: %w", err)
+		}
+		if raw == nil {
+			c.inBlock = false
+			continue
+		}
+		if c.inCollection {
+			return raw, nil
+		}
+	}
+}
+
+func (c *dumpCursor) Decode(val interface{}) error {
+	return bson.Unmarshal(c.current, val)
+}
+
+func (c *dumpCursor) Err() error {
+	return c.err
+}
+
+func (c *dumpCursor) Close(ctx context.Context) error {
+	if c.closer != nil {
+		c.closer()
+		c.closer = nil
+	}
+	return nil
+}
+
+// openDumpFile opens a file of the dump, decompressing it when it is compressed with gzip.
+func openDumpFile(path string) (*bufio.Reader, func(), error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading the dump: %w", err)
+	}
+	r := bufio.NewReader(f)
+	magic, _ := r.Peek(2)
+	if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return r, func() { _ = f.Close() }, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("error reading the dump %s: %w", path, err)
+	}
+	return bufio.NewReader(gz), func() {
+		_ = gz.Close()
+		_ = f.Close()
+	}, nil
+}
+
+func isArchive(r *bufio.Reader) bool {
+	magic, err := r.Peek(4)
+	return err == nil && binary.LittleEndian.Uint32(magic) == archiveMagicNumber
+}
+
+// skipArchivePrelude positions the reader on the first namespace block of the archive.
+func skipArchivePrelude(r *bufio.Reader) error {
+	if !isArchive(r) {
+		return fmt.Errorf("the dump archive has changed since it was opened")
+	}
+	_, _ = r.Discard(4)
+	if _, err := readBSON(r, false); err != nil {
+		return fmt.Errorf("error reading the dump archive: %w", err)
+	}
+	for {
+		raw, err := readBSON(r, true)
+		if err != nil {
+			return fmt.Errorf("error reading the dump archive: %w", err)
+		}
+		if raw == nil {
+			return nil
+		}
+	}
+}
+
+// readBSON reads the next BSON document, or returns nil on an archive terminator when terminator is set. io.EOF is
+// returned at the end of the input, io.ErrUnexpectedEOF when it ends within a document.
+func readBSON(r *bufio.Reader, terminator bool) (bson.Raw, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := int32(binary.LittleEndian.Uint32(header[:]))
+	if terminator && size == archiveTerminator {
+		return nil, nil
+	}
+	if size < 5 || size > maxBSONSize {
+		return nil, fmt.Errorf("invalid BSON document size %d", size)
+	}
+	raw := make([]byte, size)
+	copy(raw, header[:])
+	if _, err := io.ReadFull(r, raw[4:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return raw, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
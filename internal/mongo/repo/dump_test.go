@@ -0,0 +1,186 @@
+package repo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const usersMetadata = `{"indexes":[{"v":{"$numberInt":"2"},"key":{"_id":{"$numberInt":"1"}},"name":"_id_"},` +
+	`{"v":{"$numberInt":"2"},"key":{"email":{"$numberInt":"1"}},"name":"email_1","unique":true}],` +
+	`"uuid":"0123","collectionName":"users","type":"collection"}`
+
+func bsonDocs(docs ...bson.D) []byte {
+	var buf bytes.Buffer
+	for _, doc := range docs {
+		raw, err := bson.Marshal(doc)
+		Expect(err).To(BeNil())
+		buf.Write(raw)
+	}
+	return buf.Bytes()
+}
+
+func gzipped(content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(content)
+	Expect(err).To(BeNil())
+	Expect(w.Close()).To(Succeed())
+	return buf.Bytes()
+}
+
+func terminator() []byte {
+	return []byte{0xff, 0xff, 0xff, 0xff}
+}
+
+// archive builds a mongodump --archive of the users and orders collections of the shop database, with the blocks of
+// the collections interleaved.
+func archive() []byte {
+	var buf bytes.Buffer
+	magic := make([]byte, 4)
+	binary.LittleEndian.PutUint32(magic, 0x8199e26d)
+	buf.Write(magic)
+	buf.Write(bsonDocs(bson.D{{Key: "concurrent_collections", Value: int32(4)}, {Key: "version", Value: "0.1"}}))
+	buf.Write(bsonDocs(
+		bson.D{{Key: "db", Value: "shop"}, {Key: "collection", Value: "users"}, {Key: "metadata", Value: usersMetadata},
+			{Key: "size", Value: int32(0)}, {Key: "type", Value: "collection"}},
+		bson.D{{Key: "db", Value: "shop"}, {Key: "collection", Value: "orders"}, {Key: "metadata", Value: ""},
+			{Key: "size", Value: int32(0)}, {Key: "type", Value: "collection"}},
+	))
+	buf.Write(terminator())
+	block := func(collection string, eof bool, docs ...bson.D) {
+		buf.Write(bsonDocs(bson.D{{Key: "db", Value: "shop"}, {Key: "collection", Value: collection},
+			{Key: "EOF", Value: eof}, {Key: "CRC", Value: int64(0)}}))
+		buf.Write(bsonDocs(docs...))
+		buf.Write(terminator())
+	}
+	block("users", false, bson.D{{Key: "_id", Value: int32(1)}})
+	block("orders", false, bson.D{{Key: "_id", Value: "o1"}})
+	block("users", false, bson.D{{Key: "_id", Value: int32(2)}}, bson.D{{Key: "_id", Value: int32(3)}})
+	block("users", true)
+	block("orders", true)
+	return buf.Bytes()
+}
+
+func readAll(cursor repo.ICursor) []map[string]interface{} {
+	var docs []map[string]interface{}
+	for cursor.Next(context.Background()) {
+		var doc map[string]interface{}
+		Expect(cursor.Decode(&doc)).To(Succeed())
+		docs = append(docs, doc)
+	}
+	Expect(cursor.Err()).To(BeNil())
+	Expect(cursor.Close(context.Background())).To(Succeed())
+	return docs
+}
+
+var _ = Describe("mongodump repo", func() {
+	var dir string
+	opts := func(path, db string) *option.Options {
+		return &option.Options{DumpPath: path, Namespace: &option.Namespace{DB: db}}
+	}
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+	})
+	It("reads the collections and the indexes of a dump directory", func() {
+		db := filepath.Join(dir, "shop")
+		Expect(os.Mkdir(db, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(db, "users.bson"), bsonDocs(
+			bson.D{{Key: "_id", Value: int32(1)}, {Key: "email", Value: "a@example.com"}},
+			bson.D{{Key: "_id", Value: int32(2)}, {Key: "email", Value: "b@example.com"}},
+		), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(db, "users.metadata.json"), []byte(usersMetadata), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(db, "orders.bson.gz"), gzipped(bsonDocs(bson.D{{Key: "_id", Value: "o1"}})), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(db, "recent.metadata.json"), []byte(`{"type":"view"}`), 0o600)).To(Succeed())
+
+		r := repo.NewDumpRepo()
+		Expect(r.Init(opts(dir, "shop"))).To(Succeed())
+		collections, err := r.ListCollections(context.Background())
+		Expect(err).To(BeNil())
+		Expect(collections).To(Equal([]repo.CollectionInfo{
+			{Name: "orders", Type: "collection"},
+			{Name: "recent", Type: "view"},
+			{Name: "users", Type: "collection"},
+		}))
+
+		indexes, err := r.GetIndexes(context.Background(), "users")
+		Expect(err).To(BeNil())
+		Expect(indexes).To(HaveLen(2))
+		Expect(indexes[1].Name).To(Equal("email_1"))
+		Expect(indexes[1].Key).To(Equal(bson.D{{Key: "email", Value: int32(1)}}))
+		Expect(indexes[1].Unique).To(BeTrue())
+
+		cursor, err := r.Find("users", context.Background(), bson.M{}, options.Find().SetSkip(1))
+		Expect(err).To(BeNil())
+		Expect(readAll(cursor)).To(Equal([]map[string]interface{}{{"_id": int32(2), "email": "b@example.com"}}))
+		cursor, err = r.Find("orders", context.Background(), bson.M{}, options.Find())
+		Expect(err).To(BeNil())
+		Expect(readAll(cursor)).To(Equal([]map[string]interface{}{{"_id": "o1"}}))
+	})
+	It("reads the type and the options of the collections", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "logs.bson"), nil, 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "logs.metadata.json"), []byte(`{"options":{"capped":true,`+
+			`"size":{"$numberInt":"4096"},"max":{"$numberInt":"100"}},"indexes":[],"type":"collection"}`), 0o600)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "weather.metadata.json"), []byte(`{"options":{"timeseries":`+
+			`{"timeField":"ts","metaField":"sensor","granularity":"minutes","bucketMaxSpanSeconds":{"$numberInt":"86400"}},`+
+			`"expireAfterSeconds":{"$numberLong":"3600"}},"indexes":[],"type":"timeseries"}`), 0o600)).To(Succeed())
+
+		r := repo.NewDumpRepo()
+		Expect(r.Init(opts(dir, "shop"))).To(Succeed())
+		info, err := r.GetCollectionInfo(context.Background(), "logs")
+		Expect(err).To(BeNil())
+		Expect(info).To(Equal(repo.CollectionInfo{Name: "logs", Type: "collection",
+			Options: repo.CollectionOptions{Capped: true, Size: 4096, Max: 100}}))
+		info, err = r.GetCollectionInfo(context.Background(), "weather")
+		Expect(err).To(BeNil())
+		Expect(info.IsTimeSeries()).To(BeTrue())
+		Expect(info.Options.ExpireAfterSeconds).To(Equal(int64(3600)))
+		Expect(*info.Options.TimeSeries).To(Equal(repo.TimeSeriesOptions{TimeField: "ts", MetaField: "sensor",
+			Granularity: "minutes", BucketMaxSpanSeconds: 86400}))
+	})
+	It("reads a collection of a gzip archive", func() {
+		path := filepath.Join(dir, "shop.archive.gz")
+		Expect(os.WriteFile(path, gzipped(archive()), 0o600)).To(Succeed())
+
+		r := repo.NewDumpRepo()
+		Expect(r.Init(opts(path, ""))).To(Succeed())
+		collections, err := r.ListCollections(context.Background())
+		Expect(err).To(BeNil())
+		Expect(collections).To(Equal([]repo.CollectionInfo{
+			{Name: "orders", Type: "collection"},
+			{Name: "users", Type: "collection"},
+		}))
+
+		indexes, err := r.GetIndexes(context.Background(), "users")
+		Expect(err).To(BeNil())
+		Expect(indexes).To(HaveLen(2))
+		indexes, err = r.GetIndexes(context.Background(), "orders")
+		Expect(err).To(BeNil())
+		Expect(indexes).To(BeEmpty())
+
+		cursor, err := r.Find("users", context.Background(), bson.M{}, options.Find().SetLimit(2))
+		Expect(err).To(BeNil())
+		Expect(readAll(cursor)).To(Equal([]map[string]interface{}{{"_id": int32(1)}, {"_id": int32(2)}}))
+	})
+	It("rejects the queries of a dump", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "users.bson"), bsonDocs(bson.D{{Key: "_id", Value: int32(1)}}), 0o600)).To(Succeed())
+		r := repo.NewDumpRepo()
+		Expect(r.Init(opts(filepath.Join(dir, "users.bson"), "shop"))).To(Succeed())
+		_, err := r.Find("users", context.Background(), bson.D{{Key: "a", Value: 1}})
+		Expect(err).NotTo(BeNil())
+		_, err = r.Aggregate("users", context.Background(), bson.A{})
+		Expect(err).NotTo(BeNil())
+		_, err = r.Find("orders", context.Background(), bson.M{})
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -1,21 +1,33 @@
 package repo
 
-//go:generate mockgen -source=repo.go -destination=../../../testhelper/mock/mongo_repo.go -package=mock -mock_names=IRepo=MockMongoIRepo,ICursor=MockMongoICursor IRepo ICursor
+//go:generate mockgen -source=repo.go -destination=../../../testhelper/mock/mongo_repo.go -package=mock -mock_names=IRepo=MockMongoIRepo,ICursor=MockMongoICursor,IChangeStream=MockMongoIChangeStream IRepo ICursor IChangeStream
 
 import (
 	"context"
+	"fmt"
 	mongodb "github.com/couchbaselabs/cbmigrate/internal/db/mongo"
 	"github.com/couchbaselabs/cbmigrate/internal/errors"
 	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type IRepo interface {
 	Init(opts *option.Options) error
 	Find(collection string, ctx context.Context, filter interface{}, opts ...*options.FindOptions) (ICursor, error)
+	Aggregate(collection string, ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error)
 	GetIndexes(ctx context.Context, collection string) ([]Indexes, error)
+	ListCollections(ctx context.Context) ([]CollectionInfo, error)
+	GetCollectionInfo(ctx context.Context, collection string) (CollectionInfo, error)
+	Watch(ctx context.Context, collection string, pipeline interface{}, opts ...*options.ChangeStreamOptions) (IChangeStream, error)
+	SnapshotTime(ctx context.Context, collection string) (primitive.Timestamp, error)
+	FindAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, filter interface{}, opts ...*options.FindOptions) (ICursor, error)
+	AggregateAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error)
 }
 
 type ICursor interface {
@@ -25,17 +37,65 @@ type ICursor interface {
 	Err() error
 }
 
+type IChangeStream interface {
+	Close(ctx context.Context) error
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	ResumeToken() bson.Raw
+	Err() error
+}
+
 type Indexes struct {
 	Name                    string      `bson:"name"`
 	TwoDSphereIndexVersion  interface{} `bson:"2dsphereIndexVersion"`
 	Key                     bson.D      `bson:"key"`
 	PartialFilterExpression bson.D      `bson:"partialFilterExpression"`
 	Sparse                  bool        `bson:"sparse"`
-	Weights                 interface{} `bson:"weights"`
-	Collation               interface{} `bson:"collation"`
+	Unique                  bool        `bson:"unique"`
+	Weights                 bson.D      `bson:"weights"`
+	DefaultLanguage         string      `bson:"default_language"`
+	WildcardProjection      bson.D      `bson:"wildcardProjection"`
+	Collation               *Collation  `bson:"collation"`
 	ExpireAfterSeconds      interface{} `bson:"expireAfterSeconds"`
 }
 
+// CollectionInfo is the type ("collection", "timeseries" or "view") and the options of a collection, as listed by
+// listCollections.
+type CollectionInfo struct {
+	Name    string            `bson:"name"`
+	Type    string            `bson:"type"`
+	Options CollectionOptions `bson:"options"`
+}
+
+type CollectionOptions struct {
+	Capped bool  `bson:"capped"`
+	Size   int64 `bson:"size"`
+	Max    int64 `bson:"max"`
+	// ExpireAfterSeconds removes the measurements of a time series collection that long after their time
+	ExpireAfterSeconds int64              `bson:"expireAfterSeconds"`
+	TimeSeries         *TimeSeriesOptions `bson:"timeseries"`
+}
+
+type TimeSeriesOptions struct {
+	TimeField            string `bson:"timeField"`
+	MetaField            string `bson:"metaField"`
+	Granularity          string `bson:"granularity"`
+	BucketMaxSpanSeconds int64  `bson:"bucketMaxSpanSeconds"`
+}
+
+func (c CollectionInfo) IsTimeSeries() bool {
+	return c.Type == "timeseries" && c.Options.TimeSeries != nil
+}
+
+type Collation struct {
+	Locale          string `bson:"locale"`
+	CaseLevel       bool   `bson:"caseLevel"`
+	Strength        int    `bson:"strength"`
+	NumericOrdering bool   `bson:"numericOrdering"`
+	Alternate       string `bson:"alternate"`
+	Backwards       bool   `bson:"backwards"`
+}
+
 func (i *Indexes) GetName() string {
 	return i.Name
 }
@@ -51,35 +111,94 @@ func (i *Indexes) IsSparse() bool {
 	return i.Sparse
 }
 
+func (i *Indexes) IsUnique() bool {
+	return i.Unique
+}
+
 func (i *Indexes) IsText() bool {
 	return i.Weights != nil
 }
 
+func (i *Indexes) GetWeights() bson.D {
+	return i.Weights
+}
+
+func (i *Indexes) GetWildcardProjection() bson.D {
+	return i.WildcardProjection
+}
+
+func (i *Indexes) GetDefaultLanguage() string {
+	return i.DefaultLanguage
+}
+
 func (i *Indexes) IsGeoSpatial() bool {
-	return i.TwoDSphereIndexVersion != nil
+	for _, k := range i.Key {
+		if k.Value == "2dsphere" || k.Value == "2d" {
+			return true
+		}
+	}
+	return false
 }
 
 func (i *Indexes) IsTTL() bool {
 	return i.ExpireAfterSeconds != nil
 }
 
+// GetExpireAfterSeconds returns the expireAfterSeconds of a TTL index, the server stores it as an int32, int64 or double.
+func (i *Indexes) GetExpireAfterSeconds() int64 {
+	switch v := i.ExpireAfterSeconds.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
 func (i *Indexes) IsCustomCollationEnabled() bool {
-	return i.Collation != nil
+	return i.Collation != nil && i.Collation.Locale != "simple"
+}
+
+// IsCaseInsensitive reports whether the index has a case insensitive collation, which is translated on LOWER() of the
+// keys. The other collations are not supported.
+func (i *Indexes) IsCaseInsensitive() bool {
+	return i.IsCustomCollationEnabled() && i.collationNotSupported() == nil
+}
+
+func (i *Indexes) collationNotSupported() error {
+	c := i.Collation
+	switch {
+	case c.Strength == 0 || c.Strength > 2:
+		// the default strength is 3
+		return errors.NewMongoNotSupportedError(fmt.Sprintf("index with a case sensitive collation (locale %s) not "+
+			"supported, only the case insensitive collations (strength 1 or 2) are translated", c.Locale))
+	case c.Locale == "tr" || c.Locale == "az" || strings.HasPrefix(c.Locale, "tr_") || strings.HasPrefix(c.Locale, "az_"):
+		return errors.NewMongoNotSupportedError(fmt.Sprintf("index with a collation of locale %s not supported, "+
+			"its case folding differs from LOWER()", c.Locale))
+	case c.CaseLevel:
+		return errors.NewMongoNotSupportedError("index with a caseLevel collation not supported")
+	case c.NumericOrdering:
+		return errors.NewMongoNotSupportedError("index with a numericOrdering collation not supported")
+	case c.Alternate == "shifted":
+		return errors.NewMongoNotSupportedError("index with a collation ignoring whitespace and punctuation (alternate shifted) not supported")
+	case c.Backwards:
+		return errors.NewMongoNotSupportedError("index with a backwards collation not supported")
+	}
+	return nil
 }
 
 func (i *Indexes) NotSupported() error {
 	switch {
-	case i.IsText():
-		return errors.NewMongoNotSupportedError("text index not supported")
-	case i.IsGeoSpatial():
-		return errors.NewMongoNotSupportedError("geo spatial index not supported")
 	case i.IsCustomCollationEnabled():
-		return errors.NewMongoNotSupportedError("index with collation settings not supported")
+		return i.collationNotSupported()
 	}
 	return nil
 }
 
 type Repo struct {
+	mu sync.Mutex
 	db *mongodb.DB
 }
 
@@ -89,7 +208,13 @@ func NewRepo() IRepo {
 	}
 }
 
+// Init connects to the database. The repo can be shared by several sources, so it only connects once.
 func (r *Repo) Init(opts *option.Options) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db.Database != nil {
+		return nil
+	}
 	return r.db.Init(opts)
 }
 
@@ -102,6 +227,15 @@ func (r *Repo) Find(collection string, ctx context.Context, filter interface{},
 	return &Cursor{cursor: c}, nil
 }
 
+func (r *Repo) Aggregate(collection string, ctx context.Context, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	col := r.db.Collection(collection)
+	c, err := col.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{cursor: c}, nil
+}
+
 func (r *Repo) GetIndexes(ctx context.Context, collection string) ([]Indexes, error) {
 	col := r.db.Collection(collection)
 	cursor, err := col.Indexes().List(ctx)
@@ -115,6 +249,52 @@ func (r *Repo) GetIndexes(ctx context.Context, collection string) ([]Indexes, er
 	return results, nil
 }
 
+// ListCollections returns the collections and the views of the database by name, the system collections excluded.
+func (r *Repo) ListCollections(ctx context.Context) ([]CollectionInfo, error) {
+	cursor, err := r.db.ListCollections(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var infos []CollectionInfo
+	if err = cursor.All(ctx, &infos); err != nil {
+		return nil, err
+	}
+	var collections []CollectionInfo
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name, "system.") {
+			continue
+		}
+		collections = append(collections, info)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+// GetCollectionInfo returns the type and the options of the collection, the zero CollectionInfo when it does not
+// exist.
+func (r *Repo) GetCollectionInfo(ctx context.Context, collection string) (CollectionInfo, error) {
+	var info CollectionInfo
+	cursor, err := r.db.ListCollections(ctx, bson.D{{Key: "name", Value: collection}})
+	if err != nil {
+		return info, err
+	}
+	defer cursor.Close(ctx)
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&info)
+		return info, err
+	}
+	return info, cursor.Err()
+}
+
+// Watch opens a change stream on the collection, *mongo.ChangeStream implements IChangeStream.
+func (r *Repo) Watch(ctx context.Context, collection string, pipeline interface{}, opts ...*options.ChangeStreamOptions) (IChangeStream, error) {
+	cs, err := r.db.Collection(collection).Watch(ctx, pipeline, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
 type Cursor struct {
 	cursor *mongo.Cursor
 }
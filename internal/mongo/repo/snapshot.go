@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const snapshotTooOldCode = 239
+
+// ExplainSnapshotTooOld explains the SnapshotTooOld error of a snapshot read, which fails once the snapshot is older
+// than the history kept by the server. The other errors are returned as they are.
+func ExplainSnapshotTooOld(err error) error {
+	var se mongo.ServerError
+	if errors.As(err, &se) && se.HasErrorCode(snapshotTooOldCode) {
+		return fmt.Errorf("the snapshot is older than the history the server keeps for minSnapshotHistoryWindowInSeconds "+
+			"(300 seconds by default), raise the parameter on the server above the duration of the migration: %w", err)
+	}
+	return err
+}
+
+// snapshotCursor explains the SnapshotTooOld errors of the getMore commands of a snapshot read.
+type snapshotCursor struct {
+	*Cursor
+}
+
+func (c snapshotCursor) Err() error {
+	return ExplainSnapshotTooOld(c.Cursor.Err())
+}
+
+// SnapshotTime returns the cluster time of a snapshot of the collection, it is chosen by the server for a snapshot
+// read. Only replica sets and sharded clusters support snapshot reads.
+func (r *Repo) SnapshotTime(ctx context.Context, collection string) (primitive.Timestamp, error) {
+	sess, err := r.db.Client().StartSession()
+	if err != nil {
+		return primitive.Timestamp{}, err
+	}
+	defer sess.EndSession(context.Background())
+	err = mongo.WithSession(ctx, sess, func(sc mongo.SessionContext) error {
+		return r.db.RunCommand(sc, bson.D{
+			{Key: "find", Value: collection},
+			{Key: "limit", Value: 1},
+			{Key: "singleBatch", Value: true},
+			{Key: "readConcern", Value: bson.D{{Key: "level", Value: "snapshot"}}},
+		}).Err()
+	})
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("snapshot read failed, snapshot reads need a replica set or a sharded cluster: %w", err)
+	}
+	// the operation time of a snapshot read is the time of its snapshot
+	t := sess.OperationTime()
+	if t == nil {
+		return primitive.Timestamp{}, fmt.Errorf("the server did not return the time of the snapshot, snapshot reads need a replica set or a sharded cluster")
+	}
+	return *t, nil
+}
+
+// FindAt runs the find on the snapshot of the collection at atClusterTime. The driver only sets the time of a
+// snapshot read from the first read of a session, so the find command is built from the options.
+func (r *Repo) FindAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, filter interface{}, opts ...*options.FindOptions) (ICursor, error) {
+	o := options.MergeFindOptions(opts...)
+	if filter == nil {
+		filter = bson.D{}
+	}
+	cmd := bson.D{{Key: "find", Value: collection}, {Key: "filter", Value: filter}}
+	if o.Sort != nil {
+		cmd = append(cmd, bson.E{Key: "sort", Value: o.Sort})
+	}
+	if o.Projection != nil {
+		cmd = append(cmd, bson.E{Key: "projection", Value: o.Projection})
+	}
+	if o.Skip != nil {
+		cmd = append(cmd, bson.E{Key: "skip", Value: *o.Skip})
+	}
+	if o.Limit != nil {
+		cmd = append(cmd, bson.E{Key: "limit", Value: *o.Limit})
+	}
+	if o.BatchSize != nil {
+		cmd = append(cmd, bson.E{Key: "batchSize", Value: *o.BatchSize})
+	}
+	if o.NoCursorTimeout != nil {
+		cmd = append(cmd, bson.E{Key: "noCursorTimeout", Value: *o.NoCursorTimeout})
+	}
+	return r.runCursorAt(ctx, cmd, atClusterTime)
+}
+
+// AggregateAt runs the aggregation on the snapshot of the collection at atClusterTime.
+func (r *Repo) AggregateAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, pipeline interface{}, opts ...*options.AggregateOptions) (ICursor, error) {
+	o := options.MergeAggregateOptions(opts...)
+	cmd := bson.D{
+		{Key: "aggregate", Value: collection},
+		{Key: "pipeline", Value: pipeline},
+		{Key: "cursor", Value: bson.D{}},
+	}
+	if o.AllowDiskUse != nil {
+		cmd = append(cmd, bson.E{Key: "allowDiskUse", Value: *o.AllowDiskUse})
+	}
+	return r.runCursorAt(ctx, cmd, atClusterTime)
+}
+
+func (r *Repo) runCursorAt(ctx context.Context, cmd bson.D, atClusterTime primitive.Timestamp) (ICursor, error) {
+	cmd = append(cmd, bson.E{Key: "readConcern", Value: bson.D{
+		{Key: "level", Value: "snapshot"},
+		{Key: "atClusterTime", Value: atClusterTime},
+	}})
+	c, err := r.db.RunCommandCursor(ctx, cmd)
+	if err != nil {
+		return nil, ExplainSnapshotTooOld(err)
+	}
+	return snapshotCursor{&Cursor{cursor: c}}, nil
+}
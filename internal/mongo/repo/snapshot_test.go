@@ -0,0 +1,25 @@
+package repo_test
+
+import (
+	"errors"
+
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ = Describe("snapshot reads", func() {
+	It("the SnapshotTooOld error names the history window of the server", func() {
+		tooOld := mongo.CommandError{Code: 239, Name: "SnapshotTooOld", Message: "Read timestamp is older than the oldest available timestamp"}
+		err := repo.ExplainSnapshotTooOld(tooOld)
+		Expect(err).To(MatchError(ContainSubstring("minSnapshotHistoryWindowInSeconds")))
+		var se mongo.ServerError
+		Expect(errors.As(err, &se) && se.HasErrorCode(239)).To(BeTrue())
+	})
+	It("the other errors are returned as they are", func() {
+		other := mongo.CommandError{Code: 13, Name: "Unauthorized"}
+		Expect(repo.ExplainSnapshotTooOld(other)).To(Equal(other))
+		Expect(repo.ExplainSnapshotTooOld(nil)).To(BeNil())
+	})
+})
@@ -0,0 +1,52 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// analyzeSample analyzes a $sample of the documents to migrate before the migration, so the index fields are not
+// analyzed while the documents are migrated. The sample is taken from the output of the pipeline, or from the
+// documents matching the query filter.
+func (m *Mongo) analyzeSample(ctx context.Context) error {
+	var pipeline bson.A
+	for _, stage := range m.pipeline {
+		pipeline = append(pipeline, stage)
+	}
+	if !isEmptyFilter(m.filter) {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: m.filter}})
+	}
+	if m.projection != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$project", Value: m.projection}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sample", Value: bson.D{{Key: "size", Value: m.analyzerSampleSize}}}})
+	cursor, err := m.db.Aggregate(m.collection, ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("error sampling the documents for the index analysis: %w", err)
+	}
+	defer cursor.Close(context.Background())
+
+	count := 0
+	for cursor.Next(ctx) {
+		var data map[string]interface{}
+		if err = cursor.Decode(&data); err != nil {
+			return fmt.Errorf("error sampling the documents for the index analysis: %w", err)
+		}
+		// the references are not resolved, the referenced documents are only read while the documents are migrated
+		m.convertGeoJSONPoints(data)
+		m.setExpiry(data)
+		convertBinaries(data)
+		m.analyzer.AnalyzeData(data)
+		count++
+	}
+	if err = cursor.Err(); err != nil {
+		return fmt.Errorf("error sampling the documents for the index analysis: %w", err)
+	}
+	zap.S().Infof("the index fields of %s are analyzed in a sample of %d documents", m.collection, count)
+	m.prePassDone = true
+	return nil
+}
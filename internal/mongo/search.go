@@ -0,0 +1,192 @@
+package mongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TextIndex holds the text part of a mongo text index. The other keys of a compound text index are kept in Index.Keys.
+type TextIndex struct {
+	Fields          []TextField
+	DefaultLanguage string
+}
+
+type TextField struct {
+	Field  string
+	Weight int
+}
+
+// GeoField is a key of a 2dsphere or 2d index.
+type GeoField struct {
+	Field string
+	// Shape maps the field as a geoshape, for GeoJSON objects, instead of a geopoint.
+	Shape bool
+}
+
+// searchAnalyzers maps the mongo text search languages, by name and by ISO code, to the couchbase search analyzers.
+var searchAnalyzers = map[string]string{
+	"none":       "simple",
+	"da":         "da",
+	"danish":     "da",
+	"de":         "de",
+	"german":     "de",
+	"en":         "en",
+	"english":    "en",
+	"es":         "es",
+	"spanish":    "es",
+	"fi":         "fi",
+	"finnish":    "fi",
+	"fr":         "fr",
+	"french":     "fr",
+	"hu":         "hu",
+	"hungarian":  "hu",
+	"it":         "it",
+	"italian":    "it",
+	"nb":         "no",
+	"norwegian":  "no",
+	"nl":         "nl",
+	"dutch":      "nl",
+	"pt":         "pt",
+	"portuguese": "pt",
+	"ro":         "ro",
+	"romanian":   "ro",
+	"ru":         "ru",
+	"russian":    "ru",
+	"sv":         "sv",
+	"swedish":    "sv",
+	"tr":         "tr",
+	"turkish":    "tr",
+}
+
+var (
+	invalidSearchIndexChars = regexp.MustCompile(`[^0-9A-Za-z_-]`)
+	searchIndexNameStart    = regexp.MustCompile(`^[A-Za-z]`)
+)
+
+// SearchIndexName returns the name of the search index of a mongo text or geospatial index. Search indexes are named per scope, so
+// the collection is part of the name.
+func SearchIndexName(collection, index string) string {
+	name := invalidSearchIndexChars.ReplaceAllString(collection+"_"+index, "_")
+	if !searchIndexNameStart.MatchString(name) {
+		name = "idx_" + name
+	}
+	return name
+}
+
+func searchAnalyzer(language string) (string, error) {
+	if language == "" {
+		return "en", nil
+	}
+	analyzer, ok := searchAnalyzers[strings.ToLower(language)]
+	if !ok {
+		return "", fmt.Errorf("text search language %s is not supported by couchbase search", language)
+	}
+	return analyzer, nil
+}
+
+// CreateSearchIndexDefinition returns the JSON definition of the couchbase search index equivalent to the text or
+// geospatial index. The text fields are mapped with the analyzer of the default language, and the geo fields as
+// geoshape or geopoint fields. The other keys of a compound index are mapped with the keyword analyzer, so they can be
+// used for exact matches.
+func CreateSearchIndexDefinition(bucket, scope, collection string, index Index) ([]byte, error) {
+	var textFields []TextField
+	language := ""
+	if index.Text != nil {
+		textFields = index.Text.Fields
+		language = index.Text.DefaultLanguage
+	}
+	analyzer, err := searchAnalyzer(language)
+	if err != nil {
+		return nil, err
+	}
+	typeMapping := map[string]interface{}{
+		"enabled": true,
+		"dynamic": false,
+	}
+	for _, f := range index.Geo {
+		fieldType := "geopoint"
+		if f.Shape {
+			fieldType = "geoshape"
+		}
+		addSearchField(typeMapping, f.Field, map[string]interface{}{
+			"type":  fieldType,
+			"index": true,
+		})
+	}
+	for _, f := range textFields {
+		if f.Field == wildcardField {
+			// every string field of the documents is indexed
+			typeMapping["dynamic"] = true
+			continue
+		}
+		addSearchField(typeMapping, f.Field, map[string]interface{}{
+			"type":                 "text",
+			"analyzer":             analyzer,
+			"index":                true,
+			"include_in_all":       true,
+			"include_term_vectors": true,
+		})
+	}
+	for _, k := range index.Keys {
+		addSearchField(typeMapping, k.Field, map[string]interface{}{
+			"type":     "text",
+			"analyzer": "keyword",
+			"index":    true,
+		})
+	}
+	definition := map[string]interface{}{
+		"type":       "fulltext-index",
+		"name":       SearchIndexName(collection, index.Name),
+		"sourceType": "gocbcore",
+		"sourceName": bucket,
+		"planParams": map[string]interface{}{
+			"indexPartitions": 1,
+		},
+		"params": map[string]interface{}{
+			"doc_config": map[string]interface{}{
+				"mode":       "scope.collection.type_field",
+				"type_field": "type",
+			},
+			"mapping": map[string]interface{}{
+				"default_analyzer": analyzer,
+				"default_mapping": map[string]interface{}{
+					"enabled": false,
+				},
+				"index_dynamic": true,
+				"types": map[string]interface{}{
+					scope + "." + collection: typeMapping,
+				},
+			},
+			"store": map[string]interface{}{
+				"indexType": "scorch",
+			},
+		},
+	}
+	return json.Marshal(definition)
+}
+
+// addSearchField adds the field mapping at the dotted path, creating the child mappings of the embedded documents.
+func addSearchField(mapping map[string]interface{}, path string, field map[string]interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		properties, ok := mapping["properties"].(map[string]interface{})
+		if !ok {
+			properties = make(map[string]interface{})
+			mapping["properties"] = properties
+		}
+		child, ok := properties[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{
+				"enabled": true,
+				"dynamic": false,
+			}
+			properties[part] = child
+		}
+		mapping = child
+	}
+	field["name"] = parts[len(parts)-1]
+	fields, _ := mapping["fields"].([]interface{})
+	mapping["fields"] = append(fields, field)
+}
@@ -0,0 +1,90 @@
+package mongo_test
+
+import (
+	"encoding/json"
+	"github.com/couchbaselabs/cbmigrate/internal/mongo"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("mongo text index to couchbase search index", func() {
+	index := mongo.Index{
+		Name: "category_1_title_text_author.name_text",
+		Keys: []mongo.Key{{Field: "category", Order: 1}},
+		Text: &mongo.TextIndex{
+			Fields:          []mongo.TextField{{Field: "title", Weight: 10}, {Field: "author.name", Weight: 1}},
+			DefaultLanguage: "french",
+		},
+	}
+	It("maps the text fields with the analyzer of the default language", func() {
+		definition, err := mongo.CreateSearchIndexDefinition("b", "s", "c", index)
+		Expect(err).To(BeNil())
+		var output map[string]interface{}
+		Expect(json.Unmarshal(definition, &output)).To(Succeed())
+		Expect(output["name"]).To(Equal("c_category_1_title_text_author_name_text"))
+		Expect(output["type"]).To(Equal("fulltext-index"))
+		Expect(output["sourceName"]).To(Equal("b"))
+
+		mapping := output["params"].(map[string]interface{})["mapping"].(map[string]interface{})
+		Expect(mapping["default_analyzer"]).To(Equal("fr"))
+		typeMapping := mapping["types"].(map[string]interface{})["s.c"].(map[string]interface{})
+		Expect(typeMapping["dynamic"]).To(BeFalse())
+		properties := typeMapping["properties"].(map[string]interface{})
+		Expect(properties).To(HaveKey("title"))
+		Expect(properties).To(HaveKey("category"))
+
+		author := properties["author"].(map[string]interface{})
+		name := author["properties"].(map[string]interface{})["name"].(map[string]interface{})
+		Expect(name["fields"]).To(Equal([]interface{}{map[string]interface{}{
+			"name":                 "name",
+			"type":                 "text",
+			"analyzer":             "fr",
+			"index":                true,
+			"include_in_all":       true,
+			"include_term_vectors": true,
+		}}))
+		category := properties["category"].(map[string]interface{})["fields"].([]interface{})[0]
+		Expect(category).To(HaveKeyWithValue("analyzer", "keyword"))
+	})
+	It("maps every field of a wildcard text index", func() {
+		wildcard := mongo.Index{
+			Name: "$**_text",
+			Text: &mongo.TextIndex{Fields: []mongo.TextField{{Field: "$**", Weight: 1}}},
+		}
+		definition, err := mongo.CreateSearchIndexDefinition("b", "s", "c", wildcard)
+		Expect(err).To(BeNil())
+		var output map[string]interface{}
+		Expect(json.Unmarshal(definition, &output)).To(Succeed())
+		mapping := output["params"].(map[string]interface{})["mapping"].(map[string]interface{})
+		Expect(mapping["default_analyzer"]).To(Equal("en"))
+		typeMapping := mapping["types"].(map[string]interface{})["s.c"].(map[string]interface{})
+		Expect(typeMapping["dynamic"]).To(BeTrue())
+		Expect(typeMapping).NotTo(HaveKey("properties"))
+	})
+	It("maps 2dsphere fields as geoshapes and 2d fields as geopoints", func() {
+		geo := mongo.Index{
+			Name: "location_2dsphere_legacy_2d",
+			Geo:  []mongo.GeoField{{Field: "address.location", Shape: true}, {Field: "legacy"}},
+		}
+		definition, err := mongo.CreateSearchIndexDefinition("b", "s", "c", geo)
+		Expect(err).To(BeNil())
+		var output map[string]interface{}
+		Expect(json.Unmarshal(definition, &output)).To(Succeed())
+		mapping := output["params"].(map[string]interface{})["mapping"].(map[string]interface{})
+		properties := mapping["types"].(map[string]interface{})["s.c"].(map[string]interface{})["properties"].(map[string]interface{})
+		address := properties["address"].(map[string]interface{})["properties"].(map[string]interface{})
+		location := address["location"].(map[string]interface{})["fields"].([]interface{})[0]
+		Expect(location).To(HaveKeyWithValue("type", "geoshape"))
+		legacy := properties["legacy"].(map[string]interface{})["fields"].([]interface{})[0]
+		Expect(legacy).To(HaveKeyWithValue("type", "geopoint"))
+	})
+	It("rejects the languages couchbase search has no analyzer for", func() {
+		unsupported := index
+		unsupported.Text = &mongo.TextIndex{Fields: index.Text.Fields, DefaultLanguage: "esperanto"}
+		_, err := mongo.CreateSearchIndexDefinition("b", "s", "c", unsupported)
+		Expect(err).NotTo(BeNil())
+	})
+	It("search index names start with a letter", func() {
+		Expect(mongo.SearchIndexName("1col", "$**_text")).To(Equal("idx_1col_____text"))
+	})
+})
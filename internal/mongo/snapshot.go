@@ -0,0 +1,70 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+// operationTimeField is the key of a resume token file holding the operation time the change stream starts at,
+// instead of a resume token.
+const operationTimeField = "operationTime"
+
+// takeSnapshot sets the cluster time the initial load reads the collection at, every cursor of the load reads the
+// same snapshot of the collection.
+func (m *Mongo) takeSnapshot(ctx context.Context) error {
+	t, err := m.db.SnapshotTime(ctx, m.collection)
+	if err != nil {
+		return err
+	}
+	m.snapshotTime = &t
+	zap.S().Infof("the collection %s is read at the snapshot of operation time %s", m.collection, m.SnapshotTime())
+	return nil
+}
+
+// SnapshotTime returns the operation time of the snapshot the collection was read at, as the content of a resume
+// token file starting a change stream right after the snapshot. It is empty without --mongodb-snapshot.
+func (m *Mongo) SnapshotTime() string {
+	if m.snapshotTime == nil {
+		return ""
+	}
+	content, err := bson.MarshalExtJSON(operationTimeToken(*m.snapshotTime), false, false)
+	if err != nil {
+		return fmt.Sprintf("%v", *m.snapshotTime)
+	}
+	return string(content)
+}
+
+func operationTimeToken(t primitive.Timestamp) bson.D {
+	return bson.D{{Key: operationTimeField, Value: t}}
+}
+
+// tokenOperationTime returns the operation time of a resume token file holding one.
+func tokenOperationTime(token bson.D) (primitive.Timestamp, bool) {
+	if len(token) != 1 || token[0].Key != operationTimeField {
+		return primitive.Timestamp{}, false
+	}
+	t, ok := token[0].Value.(primitive.Timestamp)
+	return t, ok
+}
+
+// find reads the snapshot of the initial load when there is one.
+func (m *Mongo) find(ctx context.Context, collection string, filter interface{}, opts *options.FindOptions) (repo.ICursor, error) {
+	if m.snapshotTime != nil {
+		return m.db.FindAt(collection, ctx, *m.snapshotTime, filter, opts)
+	}
+	return m.db.Find(collection, ctx, filter, opts)
+}
+
+// aggregate runs the pipeline on the snapshot of the initial load when there is one.
+func (m *Mongo) aggregate(ctx context.Context, pipeline interface{}, opts *options.AggregateOptions) (repo.ICursor, error) {
+	if m.snapshotTime != nil {
+		return m.db.AggregateAt(m.collection, ctx, *m.snapshotTime, pipeline, opts)
+	}
+	return m.db.Aggregate(m.collection, ctx, pipeline, opts)
+}
@@ -0,0 +1,248 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/option"
+	"github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// The fields of a time series bucket document, in the format read by the couchbase _TIMESERIES function: every
+// element of TimeSeriesDataField is an array of the time of a measurement (epoch milliseconds) followed by its values,
+// named by TimeSeriesKeysField.
+const (
+	TimeSeriesStartField = "ts_start"
+	TimeSeriesEndField   = "ts_end"
+	TimeSeriesKeysField  = "ts_keys"
+	TimeSeriesDataField  = "ts_data"
+)
+
+// MaxTimeSeriesBucketSize is the maximum number of measurements of a bucket document, like the buckets of mongo. The
+// measurements of a window beyond it are written to further part documents.
+const MaxTimeSeriesBucketSize = 1000
+
+// timeSeriesWindows are the bucket spans of mongo for the granularities of the time series collections.
+var timeSeriesWindows = map[string]time.Duration{
+	"seconds": time.Hour,
+	"minutes": 24 * time.Hour,
+	"hours":   30 * 24 * time.Hour,
+}
+
+// timeSeries groups the measurements of a time series collection, sorted by meta value and time, into a bucket
+// document per meta value and time window.
+type timeSeries struct {
+	timeField string
+	metaField string
+	window    time.Duration
+	bucket    *timeSeriesBucket
+}
+
+type timeSeriesBucket struct {
+	meta    interface{}
+	metaKey string
+	start   int64
+	part    int
+	rows    []map[string]interface{}
+	times   []int64
+	expiry  time.Time
+}
+
+// setCollectionType logs the capped collections, and sets up the migration of the time series collections: their
+// measurements expire expireAfterSeconds after their time, and they are grouped into bucket documents with the
+// TimeSeriesBuckets option.
+func (m *Mongo) setCollectionType(info repo.CollectionInfo, opts *option.Options) error {
+	if info.Options.Capped {
+		limit := fmt.Sprintf("%d bytes", info.Options.Size)
+		if info.Options.Max > 0 {
+			limit += fmt.Sprintf(" and %d documents", info.Options.Max)
+		}
+		zap.S().Warnf("%s is a capped collection of at most %s, the couchbase collection is not capped: the oldest "+
+			"documents are not removed once the limit is reached", m.collection, limit)
+	}
+	if !info.IsTimeSeries() {
+		return nil
+	}
+	ts := info.Options.TimeSeries
+	switch {
+	case opts.DumpPath != "":
+		return fmt.Errorf("the time series collection %s can not be read from a dump, mongodump only holds its "+
+			"internal buckets", m.collection)
+	case m.follow:
+		return fmt.Errorf("the changes of the time series collection %s can not be followed, time series "+
+			"collections have no change stream", m.collection)
+	}
+	zap.S().Infof("%s is a time series collection of time field %s, meta field %q and granularity %q", m.collection,
+		ts.TimeField, ts.MetaField, ts.Granularity)
+	if expireAfter := info.Options.ExpireAfterSeconds; expireAfter > 0 {
+		zap.S().Infof("the measurements of %s expire %d seconds after their %s", m.collection, expireAfter,
+			ts.TimeField)
+		m.ttlFields = append(m.ttlFields, ttlField{
+			path:        strings.Split(ts.TimeField, "."),
+			expireAfter: time.Duration(expireAfter) * time.Second,
+		})
+	}
+	if !opts.TimeSeriesBuckets {
+		return nil
+	}
+	if m.partitions > 1 || m.pipeline != nil {
+		return fmt.Errorf("the measurements of the time series collection %s can not be grouped into buckets when "+
+			"read by partitions or by an aggregation pipeline", m.collection)
+	}
+	window := time.Duration(opts.TimeSeriesWindow) * time.Second
+	if window == 0 {
+		window = timeSeriesWindow(ts)
+	}
+	m.timeSeries = &timeSeries{timeField: ts.TimeField, metaField: ts.MetaField, window: window}
+	zap.S().Infof("the measurements of %s are grouped into a document per %s and window of %s", m.collection,
+		ts.MetaField, window)
+	if m.CopyIndexes {
+		zap.S().Warnf("the indexes of the time series collection %s are not copied, they do not apply to the bucket "+
+			"documents", m.collection)
+		m.CopyIndexes = false
+	}
+	if m.retryAttempts > 0 {
+		// the cursor is resumed after the last _id read, and the measurements are not sorted by _id
+		zap.S().Warnf("the cursor of the time series collection %s is not resumed when it fails", m.collection)
+		m.retryAttempts = 0
+	}
+	return nil
+}
+
+// timeSeriesWindow returns the bucket span of the collection, set by its bucketMaxSpanSeconds or its granularity.
+func timeSeriesWindow(ts *repo.TimeSeriesOptions) time.Duration {
+	if ts.BucketMaxSpanSeconds > 0 {
+		return time.Duration(ts.BucketMaxSpanSeconds) * time.Second
+	}
+	if window, ok := timeSeriesWindows[ts.Granularity]; ok {
+		return window
+	}
+	return timeSeriesWindows["seconds"]
+}
+
+// sort returns the sort of the measurements by meta value and time, so the measurements of a bucket are read together.
+func (ts *timeSeries) sort() bson.D {
+	if ts.metaField == "" {
+		return bson.D{{Key: ts.timeField, Value: 1}}
+	}
+	return bson.D{{Key: ts.metaField, Value: 1}, {Key: ts.timeField, Value: 1}}
+}
+
+// add adds the measurement to its bucket, and returns the bucket documents completed by it. A measurement without a
+// time is returned as it is.
+func (ts *timeSeries) add(data map[string]interface{}) []map[string]interface{} {
+	date, ok := fieldValue(data, strings.Split(ts.timeField, ".")).(primitive.DateTime)
+	if !ok {
+		return []map[string]interface{}{data}
+	}
+	t := int64(date)
+	window := ts.window.Milliseconds()
+	start := t - ((t%window)+window)%window
+	var meta interface{}
+	if ts.metaField != "" {
+		meta = data[ts.metaField]
+	}
+	metaKey := uniqueKeyPart(meta)
+
+	var docs []map[string]interface{}
+	b := ts.bucket
+	switch {
+	case b == nil:
+	case b.metaKey != metaKey || b.start != start:
+		docs = append(docs, ts.flush()...)
+	case len(b.rows) >= MaxTimeSeriesBucketSize:
+		part := b.part + 1
+		docs = append(docs, ts.flush()...)
+		ts.bucket = &timeSeriesBucket{meta: meta, metaKey: metaKey, start: start, part: part}
+	}
+	if ts.bucket == nil {
+		ts.bucket = &timeSeriesBucket{meta: meta, metaKey: metaKey, start: start}
+	}
+	b = ts.bucket
+	if expiry, ok := data[common.ExpiryField].(time.Time); ok && expiry.After(b.expiry) {
+		// the bucket expires with its latest measurement
+		b.expiry = expiry
+	}
+	delete(data, common.ExpiryField)
+	delete(data, "_id")
+	delete(data, ts.timeField)
+	if ts.metaField != "" {
+		delete(data, ts.metaField)
+	}
+	b.rows = append(b.rows, data)
+	b.times = append(b.times, t)
+	return docs
+}
+
+// flush returns the document of the current bucket.
+func (ts *timeSeries) flush() []map[string]interface{} {
+	b := ts.bucket
+	ts.bucket = nil
+	if b == nil || len(b.rows) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var fields []string
+	for _, row := range b.rows {
+		for field := range row {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	data := make(bson.A, len(b.rows))
+	for i, row := range b.rows {
+		values := bson.A{b.times[i]}
+		for _, field := range fields {
+			values = append(values, row[field])
+		}
+		data[i] = values
+	}
+	doc := map[string]interface{}{
+		common.KeyField:      timeSeriesBucketKey(b),
+		TimeSeriesStartField: b.start,
+		TimeSeriesEndField:   b.start + ts.window.Milliseconds() - 1,
+		TimeSeriesKeysField:  append(bson.A{ts.timeField}, toA(fields)...),
+		TimeSeriesDataField:  data,
+	}
+	if ts.metaField != "" {
+		doc[ts.metaField] = b.meta
+	}
+	if !b.expiry.IsZero() {
+		doc[common.ExpiryField] = b.expiry
+	}
+	return []map[string]interface{}{doc}
+}
+
+func toA(values []string) bson.A {
+	a := make(bson.A, len(values))
+	for i, v := range values {
+		a[i] = v
+	}
+	return a
+}
+
+// timeSeriesBucketKey returns the key of a bucket document, "<meta value>::<window start>[::<part>]".
+func timeSeriesBucketKey(b *timeSeriesBucket) string {
+	meta := b.metaKey
+	if len(meta) > maxUniqueKeyLength {
+		hash := sha256.Sum256([]byte(meta))
+		meta = hex.EncodeToString(hash[:])
+	}
+	key := meta + "::" + strconv.FormatInt(b.start, 10)
+	if b.part > 0 {
+		key += "::" + strconv.Itoa(b.part)
+	}
+	return key
+}
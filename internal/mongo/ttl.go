@@ -0,0 +1,77 @@
+package mongo
+
+import (
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type ttlField struct {
+	path        []string
+	expireAfter time.Duration
+}
+
+// setTTLFields keeps the date fields of the TTL indexes, the documents expire expireAfterSeconds after their date.
+func (m *Mongo) setTTLFields(indexes []Index) {
+	for _, index := range indexes {
+		if !index.TTL || len(index.Keys) != 1 {
+			continue
+		}
+		zap.S().Infof("the documents of the TTL index %s expire %d seconds after their %s date", index.Name,
+			index.ExpireAfterSeconds, index.Keys[0].Field)
+		m.ttlFields = append(m.ttlFields, ttlField{
+			path:        strings.Split(index.Keys[0].Field, "."),
+			expireAfter: time.Duration(index.ExpireAfterSeconds) * time.Second,
+		})
+	}
+}
+
+// setExpiry sets the expiry of the document the way mongo does: from the earliest date of the TTL field, and when the
+// document has several TTL fields from the earliest expiry. Documents without a date never expire.
+func (m *Mongo) setExpiry(data map[string]interface{}) {
+	var expiry time.Time
+	for _, f := range m.ttlFields {
+		date, ok := earliestDate(fieldValue(data, f.path))
+		if !ok {
+			continue
+		}
+		if e := date.Add(f.expireAfter); expiry.IsZero() || e.Before(expiry) {
+			expiry = e
+		}
+	}
+	if !expiry.IsZero() {
+		data[common.ExpiryField] = expiry
+	}
+}
+
+func fieldValue(data map[string]interface{}, path []string) interface{} {
+	var value interface{} = data
+	for _, p := range path {
+		doc, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = doc[p]
+	}
+	return value
+}
+
+// earliestDate returns the date of a date field, or the earliest date of an array of dates.
+func earliestDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return v.Time(), true
+	case primitive.A:
+		var earliest time.Time
+		for _, item := range v {
+			if date, ok := item.(primitive.DateTime); ok && (earliest.IsZero() || date.Time().Before(earliest)) {
+				earliest = date.Time()
+			}
+		}
+		return earliest, !earliest.IsZero()
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,154 @@
+package mongo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/couchbaselabs/cbmigrate/internal/common"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+const (
+	uniqueKeyPrefix = "unique"
+	// maxUniqueKeyLength keeps the lookup keys under the 250 bytes limit of the couchbase keys
+	maxUniqueKeyLength = 200
+	// maxReportedDuplicates is the number of duplicates logged per index, the others are only counted
+	maxReportedDuplicates = 10
+)
+
+// uniqueIndex tracks the values of a unique index. Couchbase has no unique constraint, so the duplicates the source
+// holds (e.g. written before the index was created with a partial filter) or the ones made during the migration of a
+// live collection are reported.
+type uniqueIndex struct {
+	name   string
+	fields [][]string
+	sparse bool
+	// caseInsensitive compares the lowercase strings, for an index with a case insensitive collation
+	caseInsensitive bool
+	// seen maps the hash of the values to the _id of the first document holding them, for at most the tracking limit
+	// of values
+	seen       map[[sha256.Size]byte]interface{}
+	full       bool
+	duplicates int
+}
+
+func (m *Mongo) setUniqueIndexes(indexes []Index) {
+	for _, index := range indexes {
+		if !index.Unique || index.Error != nil || index.Text != nil || index.Geo != nil {
+			continue
+		}
+		if index.PartialExpression != nil {
+			zap.S().Infof("the values of the unique index %s are not tracked, its partial filter expression is not evaluated", index.Name)
+			continue
+		}
+		u := &uniqueIndex{
+			name:            index.Name,
+			sparse:          index.Sparse,
+			caseInsensitive: index.CaseInsensitive,
+			seen:            make(map[[sha256.Size]byte]interface{}),
+		}
+		for _, k := range index.Keys {
+			u.fields = append(u.fields, strings.Split(k.Field, "."))
+		}
+		m.uniqueIndexes = append(m.uniqueIndexes, u)
+	}
+}
+
+// trackUnique records the values of the unique indexes of the document and returns the lookup documents of the values
+// seen for the first time, when the lookup documents are enabled.
+func (m *Mongo) trackUnique(data map[string]interface{}) []map[string]interface{} {
+	var lookups []map[string]interface{}
+	id := data["_id"]
+	for _, u := range m.uniqueIndexes {
+		values := make([]interface{}, len(u.fields))
+		missing := true
+		for i, path := range u.fields {
+			values[i] = fieldValue(data, path)
+			if s, ok := values[i].(string); ok && u.caseInsensitive {
+				values[i] = strings.ToLower(s)
+			}
+			missing = missing && values[i] == nil
+		}
+		// a sparse index only holds the documents with at least one of the fields
+		if u.sparse && missing {
+			continue
+		}
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%#v", values)))
+		if first, ok := u.seen[hash]; ok {
+			if !reflect.DeepEqual(first, id) {
+				u.duplicates++
+				if u.duplicates <= maxReportedDuplicates {
+					zap.S().Warnf("unique index %s: the document %v has the same value as the document %v", u.name, id, first)
+				}
+			}
+			continue
+		}
+		if m.uniqueTrackingLimit > 0 && len(u.seen) >= m.uniqueTrackingLimit {
+			if !u.full {
+				u.full = true
+				zap.S().Warnf("unique index %s: %d values are tracked, the duplicates of the next values are not "+
+					"detected", u.name, m.uniqueTrackingLimit)
+			}
+		} else {
+			u.seen[hash] = id
+		}
+		if m.uniqueLookup {
+			lookups = append(lookups, map[string]interface{}{
+				common.KeyField: UniqueLookupKey(u.name, values),
+				"uniqueIndex":   u.name,
+				"values":        values,
+				"documentId":    id,
+			})
+		}
+	}
+	return lookups
+}
+
+func (m *Mongo) reportUnique() {
+	for _, u := range m.uniqueIndexes {
+		if u.full {
+			zap.S().Warnf("unique index %s: only the first %d values are checked for duplicates", u.name,
+				m.uniqueTrackingLimit)
+		}
+		if u.duplicates > 0 {
+			zap.S().Warnf("unique index %s: %d documents have the value of another document, couchbase does not "+
+				"enforce the uniqueness", u.name, u.duplicates)
+			continue
+		}
+		zap.S().Infof("unique index %s: no duplicate value", u.name)
+	}
+}
+
+// UniqueLookupKey returns the key of the lookup document of the values of a unique index:
+// "unique::<index name>::<value>::<value>...", or the hex sha256 of the values part when the key is too long.
+func UniqueLookupKey(index string, values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = uniqueKeyPart(v)
+	}
+	key := strings.Join(parts, "::")
+	if len(key) > maxUniqueKeyLength {
+		hash := sha256.Sum256([]byte(key))
+		key = hex.EncodeToString(hash[:])
+	}
+	return uniqueKeyPrefix + "::" + index + "::" + key
+}
+
+func uniqueKeyPart(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case primitive.ObjectID:
+		return val.Hex()
+	case primitive.DateTime:
+		return val.Time().UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprintf("%v", v)
+}
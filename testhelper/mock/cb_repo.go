@@ -83,6 +83,20 @@ func (mr *MockCouchbaseIRepoMockRecorder) CreateScope(name any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScope", reflect.TypeOf((*MockCouchbaseIRepo)(nil).CreateScope), name)
 }
 
+// CreateSearchIndex mocks base method.
+func (m *MockCouchbaseIRepo) CreateSearchIndex(scope string, definition []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSearchIndex", scope, definition)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSearchIndex indicates an expected call of CreateSearchIndex.
+func (mr *MockCouchbaseIRepoMockRecorder) CreateSearchIndex(scope, definition any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSearchIndex", reflect.TypeOf((*MockCouchbaseIRepo)(nil).CreateSearchIndex), scope, definition)
+}
+
 // GetAllScopes mocks base method.
 func (m *MockCouchbaseIRepo) GetAllScopes() ([]gocb.ScopeSpec, error) {
 	m.ctrl.T.Helper()
@@ -98,6 +112,21 @@ func (mr *MockCouchbaseIRepoMockRecorder) GetAllScopes() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllScopes", reflect.TypeOf((*MockCouchbaseIRepo)(nil).GetAllScopes))
 }
 
+// GetDocument mocks base method.
+func (m *MockCouchbaseIRepo) GetDocument(scope, collection, id string) (map[string]any, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDocument", scope, collection, id)
+	ret0, _ := ret[0].(map[string]any)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDocument indicates an expected call of GetDocument.
+func (mr *MockCouchbaseIRepoMockRecorder) GetDocument(scope, collection, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDocument", reflect.TypeOf((*MockCouchbaseIRepo)(nil).GetDocument), scope, collection, id)
+}
+
 // Init mocks base method.
 func (m *MockCouchbaseIRepo) Init(uri string, opts *option.Options) error {
 	m.ctrl.T.Helper()
@@ -112,6 +141,20 @@ func (mr *MockCouchbaseIRepoMockRecorder) Init(uri, opts any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockCouchbaseIRepo)(nil).Init), uri, opts)
 }
 
+// UpsertBinaryData mocks base method.
+func (m *MockCouchbaseIRepo) UpsertBinaryData(scope, collection string, docs []gocb.BulkOp) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertBinaryData", scope, collection, docs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertBinaryData indicates an expected call of UpsertBinaryData.
+func (mr *MockCouchbaseIRepoMockRecorder) UpsertBinaryData(scope, collection, docs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertBinaryData", reflect.TypeOf((*MockCouchbaseIRepo)(nil).UpsertBinaryData), scope, collection, docs)
+}
+
 // UpsertData mocks base method.
 func (m *MockCouchbaseIRepo) UpsertData(scope, collection string, docs []gocb.BulkOp) error {
 	m.ctrl.T.Helper()
@@ -125,3 +168,17 @@ func (mr *MockCouchbaseIRepoMockRecorder) UpsertData(scope, collection, docs any
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertData", reflect.TypeOf((*MockCouchbaseIRepo)(nil).UpsertData), scope, collection, docs)
 }
+
+// UpsertDataInTransaction mocks base method.
+func (m *MockCouchbaseIRepo) UpsertDataInTransaction(scope, collection string, docs []gocb.BulkOp) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertDataInTransaction", scope, collection, docs)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertDataInTransaction indicates an expected call of UpsertDataInTransaction.
+func (mr *MockCouchbaseIRepoMockRecorder) UpsertDataInTransaction(scope, collection, docs any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertDataInTransaction", reflect.TypeOf((*MockCouchbaseIRepo)(nil).UpsertDataInTransaction), scope, collection, docs)
+}
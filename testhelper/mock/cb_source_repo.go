@@ -0,0 +1,184 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: repo.go
+//
+// Generated by this command:
+//
+//	mockgen -source=repo.go -destination=../../../../testhelper/mock/cb_source_repo.go -package=mock -mock_names=IRepo=MockCouchbaseSourceIRepo,ICursor=MockCouchbaseSourceICursor IRepo ICursor
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gocb "github.com/couchbase/gocb/v2"
+	option "github.com/couchbaselabs/cbmigrate/internal/couchbase/option"
+	repo "github.com/couchbaselabs/cbmigrate/internal/couchbase/source/repo"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCouchbaseSourceIRepo is a mock of IRepo interface.
+type MockCouchbaseSourceIRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockCouchbaseSourceIRepoMockRecorder
+	isgomock struct{}
+}
+
+// MockCouchbaseSourceIRepoMockRecorder is the mock recorder for MockCouchbaseSourceIRepo.
+type MockCouchbaseSourceIRepoMockRecorder struct {
+	mock *MockCouchbaseSourceIRepo
+}
+
+// NewMockCouchbaseSourceIRepo creates a new mock instance.
+func NewMockCouchbaseSourceIRepo(ctrl *gomock.Controller) *MockCouchbaseSourceIRepo {
+	mock := &MockCouchbaseSourceIRepo{ctrl: ctrl}
+	mock.recorder = &MockCouchbaseSourceIRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCouchbaseSourceIRepo) EXPECT() *MockCouchbaseSourceIRepoMockRecorder {
+	return m.recorder
+}
+
+// GetIndexes mocks base method.
+func (m *MockCouchbaseSourceIRepo) GetIndexes(scope, collection string) ([]gocb.QueryIndex, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIndexes", scope, collection)
+	ret0, _ := ret[0].([]gocb.QueryIndex)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIndexes indicates an expected call of GetIndexes.
+func (mr *MockCouchbaseSourceIRepoMockRecorder) GetIndexes(scope, collection any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIndexes", reflect.TypeOf((*MockCouchbaseSourceIRepo)(nil).GetIndexes), scope, collection)
+}
+
+// Init mocks base method.
+func (m *MockCouchbaseSourceIRepo) Init(uri string, opts *option.Options) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Init", uri, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Init indicates an expected call of Init.
+func (mr *MockCouchbaseSourceIRepoMockRecorder) Init(uri, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockCouchbaseSourceIRepo)(nil).Init), uri, opts)
+}
+
+// Query mocks base method.
+func (m *MockCouchbaseSourceIRepo) Query(ctx context.Context, scope, collection string) (repo.ICursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Query", ctx, scope, collection)
+	ret0, _ := ret[0].(repo.ICursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Query indicates an expected call of Query.
+func (mr *MockCouchbaseSourceIRepoMockRecorder) Query(ctx, scope, collection any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockCouchbaseSourceIRepo)(nil).Query), ctx, scope, collection)
+}
+
+// Scan mocks base method.
+func (m *MockCouchbaseSourceIRepo) Scan(ctx context.Context, scope, collection string) (repo.ICursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Scan", ctx, scope, collection)
+	ret0, _ := ret[0].(repo.ICursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockCouchbaseSourceIRepoMockRecorder) Scan(ctx, scope, collection any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockCouchbaseSourceIRepo)(nil).Scan), ctx, scope, collection)
+}
+
+// MockCouchbaseSourceICursor is a mock of ICursor interface.
+type MockCouchbaseSourceICursor struct {
+	ctrl     *gomock.Controller
+	recorder *MockCouchbaseSourceICursorMockRecorder
+	isgomock struct{}
+}
+
+// MockCouchbaseSourceICursorMockRecorder is the mock recorder for MockCouchbaseSourceICursor.
+type MockCouchbaseSourceICursorMockRecorder struct {
+	mock *MockCouchbaseSourceICursor
+}
+
+// NewMockCouchbaseSourceICursor creates a new mock instance.
+func NewMockCouchbaseSourceICursor(ctrl *gomock.Controller) *MockCouchbaseSourceICursor {
+	mock := &MockCouchbaseSourceICursor{ctrl: ctrl}
+	mock.recorder = &MockCouchbaseSourceICursorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCouchbaseSourceICursor) EXPECT() *MockCouchbaseSourceICursorMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockCouchbaseSourceICursor) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockCouchbaseSourceICursorMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockCouchbaseSourceICursor)(nil).Close))
+}
+
+// Decode mocks base method.
+func (m *MockCouchbaseSourceICursor) Decode() (repo.Document, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode")
+	ret0, _ := ret[0].(repo.Document)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decode indicates an expected call of Decode.
+func (mr *MockCouchbaseSourceICursorMockRecorder) Decode() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockCouchbaseSourceICursor)(nil).Decode))
+}
+
+// Err mocks base method.
+func (m *MockCouchbaseSourceICursor) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err.
+func (mr *MockCouchbaseSourceICursorMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*MockCouchbaseSourceICursor)(nil).Err))
+}
+
+// Next mocks base method.
+func (m *MockCouchbaseSourceICursor) Next() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Next indicates an expected call of Next.
+func (mr *MockCouchbaseSourceICursorMockRecorder) Next() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockCouchbaseSourceICursor)(nil).Next))
+}
@@ -96,3 +96,41 @@ func (mr *MockIDestinationMockRecorder) ProcessData(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessData", reflect.TypeOf((*MockIDestination)(nil).ProcessData), arg0)
 }
+
+// MockIAbortableDestination is a mock of IAbortableDestination interface.
+type MockIAbortableDestination struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAbortableDestinationMockRecorder
+	isgomock struct{}
+}
+
+// MockIAbortableDestinationMockRecorder is the mock recorder for MockIAbortableDestination.
+type MockIAbortableDestinationMockRecorder struct {
+	mock *MockIAbortableDestination
+}
+
+// NewMockIAbortableDestination creates a new mock instance.
+func NewMockIAbortableDestination(ctrl *gomock.Controller) *MockIAbortableDestination {
+	mock := &MockIAbortableDestination{ctrl: ctrl}
+	mock.recorder = &MockIAbortableDestinationMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIAbortableDestination) EXPECT() *MockIAbortableDestinationMockRecorder {
+	return m.recorder
+}
+
+// Abort mocks base method.
+func (m *MockIAbortableDestination) Abort(sourceErr error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Abort", sourceErr)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Abort indicates an expected call of Abort.
+func (mr *MockIAbortableDestinationMockRecorder) Abort(sourceErr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Abort", reflect.TypeOf((*MockIAbortableDestination)(nil).Abort), sourceErr)
+}
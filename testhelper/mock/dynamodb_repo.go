@@ -73,6 +73,52 @@ func (mr *MockDynamoDbIRepoMockRecorder) GetPrimaryIndex(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrimaryIndex", reflect.TypeOf((*MockDynamoDbIRepo)(nil).GetPrimaryIndex), ctx)
 }
 
+// GetRecords mocks base method.
+func (m *MockDynamoDbIRepo) GetRecords(ctx context.Context, iterator string) ([]repo.StreamRecord, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRecords", ctx, iterator)
+	ret0, _ := ret[0].([]repo.StreamRecord)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetRecords indicates an expected call of GetRecords.
+func (mr *MockDynamoDbIRepoMockRecorder) GetRecords(ctx, iterator any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRecords", reflect.TypeOf((*MockDynamoDbIRepo)(nil).GetRecords), ctx, iterator)
+}
+
+// GetShardIterator mocks base method.
+func (m *MockDynamoDbIRepo) GetShardIterator(ctx context.Context, streamArn, shardID, sequenceNumber string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetShardIterator", ctx, streamArn, shardID, sequenceNumber)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetShardIterator indicates an expected call of GetShardIterator.
+func (mr *MockDynamoDbIRepoMockRecorder) GetShardIterator(ctx, streamArn, shardID, sequenceNumber any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetShardIterator", reflect.TypeOf((*MockDynamoDbIRepo)(nil).GetShardIterator), ctx, streamArn, shardID, sequenceNumber)
+}
+
+// GetStream mocks base method.
+func (m *MockDynamoDbIRepo) GetStream(ctx context.Context) (repo.Stream, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStream", ctx)
+	ret0, _ := ret[0].(repo.Stream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStream indicates an expected call of GetStream.
+func (mr *MockDynamoDbIRepoMockRecorder) GetStream(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStream", reflect.TypeOf((*MockDynamoDbIRepo)(nil).GetStream), ctx)
+}
+
 // Init mocks base method.
 func (m *MockDynamoDbIRepo) Init(opts *option.Options) error {
 	m.ctrl.T.Helper()
@@ -101,6 +147,20 @@ func (mr *MockDynamoDbIRepoMockRecorder) NewPaginator(segment, totalSegments, li
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewPaginator", reflect.TypeOf((*MockDynamoDbIRepo)(nil).NewPaginator), segment, totalSegments, limit)
 }
 
+// NewQueryPaginator mocks base method.
+func (m *MockDynamoDbIRepo) NewQueryPaginator(partitionKey string, limit int32) repo.IPaginator {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewQueryPaginator", partitionKey, limit)
+	ret0, _ := ret[0].(repo.IPaginator)
+	return ret0
+}
+
+// NewQueryPaginator indicates an expected call of NewQueryPaginator.
+func (mr *MockDynamoDbIRepoMockRecorder) NewQueryPaginator(partitionKey, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewQueryPaginator", reflect.TypeOf((*MockDynamoDbIRepo)(nil).NewQueryPaginator), partitionKey, limit)
+}
+
 // MockDynamoDbIPaginator is a mock of IPaginator interface.
 type MockDynamoDbIPaginator struct {
 	ctrl     *gomock.Controller
@@ -68,13 +68,13 @@ func (mr *MockAnalyzerMockRecorder) GetCouchbaseQuery(bucket, scope, collection
 }
 
 // Init mocks base method.
-func (m *MockAnalyzer) Init(index []mongo.Index, documentKey common.ICBDocumentKey) {
+func (m *MockAnalyzer) Init(index []mongo.Index, documentKey common.ICBDocumentKey, opts mongo.AnalyzerOptions) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Init", index, documentKey)
+	m.ctrl.Call(m, "Init", index, documentKey, opts)
 }
 
 // Init indicates an expected call of Init.
-func (mr *MockAnalyzerMockRecorder) Init(index, documentKey any) *gomock.Call {
+func (mr *MockAnalyzerMockRecorder) Init(index, documentKey, opts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockAnalyzer)(nil).Init), index, documentKey)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockAnalyzer)(nil).Init), index, documentKey, opts)
 }
@@ -3,7 +3,7 @@
 //
 // Generated by this command:
 //
-//	mockgen -source=repo.go -destination=../../../testhelper/mock/mongo_repo.go -package=mock -mock_names=IRepo=MockMongoIRepo,ICursor=MockMongoICursor IRepo ICursor
+//	mockgen -source=repo.go -destination=../../../testhelper/mock/mongo_repo.go -package=mock -mock_names=IRepo=MockMongoIRepo,ICursor=MockMongoICursor,IChangeStream=MockMongoIChangeStream IRepo ICursor IChangeStream
 //
 
 // Package mock is a generated GoMock package.
@@ -15,6 +15,8 @@ import (
 
 	option "github.com/couchbaselabs/cbmigrate/internal/mongo/option"
 	repo "github.com/couchbaselabs/cbmigrate/internal/mongo/repo"
+	bson "go.mongodb.org/mongo-driver/bson"
+	primitive "go.mongodb.org/mongo-driver/bson/primitive"
 	options "go.mongodb.org/mongo-driver/mongo/options"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -43,6 +45,46 @@ func (m *MockMongoIRepo) EXPECT() *MockMongoIRepoMockRecorder {
 	return m.recorder
 }
 
+// Aggregate mocks base method.
+func (m *MockMongoIRepo) Aggregate(collection string, ctx context.Context, pipeline any, opts ...*options.AggregateOptions) (repo.ICursor, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{collection, ctx, pipeline}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Aggregate", varargs...)
+	ret0, _ := ret[0].(repo.ICursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Aggregate indicates an expected call of Aggregate.
+func (mr *MockMongoIRepoMockRecorder) Aggregate(collection, ctx, pipeline any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{collection, ctx, pipeline}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Aggregate", reflect.TypeOf((*MockMongoIRepo)(nil).Aggregate), varargs...)
+}
+
+// AggregateAt mocks base method.
+func (m *MockMongoIRepo) AggregateAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, pipeline any, opts ...*options.AggregateOptions) (repo.ICursor, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{collection, ctx, atClusterTime, pipeline}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AggregateAt", varargs...)
+	ret0, _ := ret[0].(repo.ICursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AggregateAt indicates an expected call of AggregateAt.
+func (mr *MockMongoIRepoMockRecorder) AggregateAt(collection, ctx, atClusterTime, pipeline any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{collection, ctx, atClusterTime, pipeline}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AggregateAt", reflect.TypeOf((*MockMongoIRepo)(nil).AggregateAt), varargs...)
+}
+
 // Find mocks base method.
 func (m *MockMongoIRepo) Find(collection string, ctx context.Context, filter any, opts ...*options.FindOptions) (repo.ICursor, error) {
 	m.ctrl.T.Helper()
@@ -63,6 +105,41 @@ func (mr *MockMongoIRepoMockRecorder) Find(collection, ctx, filter any, opts ...
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockMongoIRepo)(nil).Find), varargs...)
 }
 
+// FindAt mocks base method.
+func (m *MockMongoIRepo) FindAt(collection string, ctx context.Context, atClusterTime primitive.Timestamp, filter any, opts ...*options.FindOptions) (repo.ICursor, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{collection, ctx, atClusterTime, filter}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "FindAt", varargs...)
+	ret0, _ := ret[0].(repo.ICursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAt indicates an expected call of FindAt.
+func (mr *MockMongoIRepoMockRecorder) FindAt(collection, ctx, atClusterTime, filter any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{collection, ctx, atClusterTime, filter}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAt", reflect.TypeOf((*MockMongoIRepo)(nil).FindAt), varargs...)
+}
+
+// GetCollectionInfo mocks base method.
+func (m *MockMongoIRepo) GetCollectionInfo(ctx context.Context, collection string) (repo.CollectionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollectionInfo", ctx, collection)
+	ret0, _ := ret[0].(repo.CollectionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionInfo indicates an expected call of GetCollectionInfo.
+func (mr *MockMongoIRepoMockRecorder) GetCollectionInfo(ctx, collection any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionInfo", reflect.TypeOf((*MockMongoIRepo)(nil).GetCollectionInfo), ctx, collection)
+}
+
 // GetIndexes mocks base method.
 func (m *MockMongoIRepo) GetIndexes(ctx context.Context, collection string) ([]repo.Indexes, error) {
 	m.ctrl.T.Helper()
@@ -92,6 +169,56 @@ func (mr *MockMongoIRepoMockRecorder) Init(opts any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Init", reflect.TypeOf((*MockMongoIRepo)(nil).Init), opts)
 }
 
+// ListCollections mocks base method.
+func (m *MockMongoIRepo) ListCollections(ctx context.Context) ([]repo.CollectionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCollections", ctx)
+	ret0, _ := ret[0].([]repo.CollectionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCollections indicates an expected call of ListCollections.
+func (mr *MockMongoIRepoMockRecorder) ListCollections(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCollections", reflect.TypeOf((*MockMongoIRepo)(nil).ListCollections), ctx)
+}
+
+// SnapshotTime mocks base method.
+func (m *MockMongoIRepo) SnapshotTime(ctx context.Context, collection string) (primitive.Timestamp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SnapshotTime", ctx, collection)
+	ret0, _ := ret[0].(primitive.Timestamp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SnapshotTime indicates an expected call of SnapshotTime.
+func (mr *MockMongoIRepoMockRecorder) SnapshotTime(ctx, collection any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SnapshotTime", reflect.TypeOf((*MockMongoIRepo)(nil).SnapshotTime), ctx, collection)
+}
+
+// Watch mocks base method.
+func (m *MockMongoIRepo) Watch(ctx context.Context, collection string, pipeline any, opts ...*options.ChangeStreamOptions) (repo.IChangeStream, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, collection, pipeline}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Watch", varargs...)
+	ret0, _ := ret[0].(repo.IChangeStream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Watch indicates an expected call of Watch.
+func (mr *MockMongoIRepoMockRecorder) Watch(ctx, collection, pipeline any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, collection, pipeline}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Watch", reflect.TypeOf((*MockMongoIRepo)(nil).Watch), varargs...)
+}
+
 // MockMongoICursor is a mock of ICursor interface.
 type MockMongoICursor struct {
 	ctrl     *gomock.Controller
@@ -171,3 +298,97 @@ func (mr *MockMongoICursorMockRecorder) Next(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockMongoICursor)(nil).Next), ctx)
 }
+
+// MockMongoIChangeStream is a mock of IChangeStream interface.
+type MockMongoIChangeStream struct {
+	ctrl     *gomock.Controller
+	recorder *MockMongoIChangeStreamMockRecorder
+	isgomock struct{}
+}
+
+// MockMongoIChangeStreamMockRecorder is the mock recorder for MockMongoIChangeStream.
+type MockMongoIChangeStreamMockRecorder struct {
+	mock *MockMongoIChangeStream
+}
+
+// NewMockMongoIChangeStream creates a new mock instance.
+func NewMockMongoIChangeStream(ctrl *gomock.Controller) *MockMongoIChangeStream {
+	mock := &MockMongoIChangeStream{ctrl: ctrl}
+	mock.recorder = &MockMongoIChangeStreamMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMongoIChangeStream) EXPECT() *MockMongoIChangeStreamMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockMongoIChangeStream) Close(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockMongoIChangeStreamMockRecorder) Close(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockMongoIChangeStream)(nil).Close), ctx)
+}
+
+// Decode mocks base method.
+func (m *MockMongoIChangeStream) Decode(val any) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decode", val)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Decode indicates an expected call of Decode.
+func (mr *MockMongoIChangeStreamMockRecorder) Decode(val any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decode", reflect.TypeOf((*MockMongoIChangeStream)(nil).Decode), val)
+}
+
+// Err mocks base method.
+func (m *MockMongoIChangeStream) Err() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Err")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Err indicates an expected call of Err.
+func (mr *MockMongoIChangeStreamMockRecorder) Err() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Err", reflect.TypeOf((*MockMongoIChangeStream)(nil).Err))
+}
+
+// Next mocks base method.
+func (m *MockMongoIChangeStream) Next(ctx context.Context) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Next", ctx)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Next indicates an expected call of Next.
+func (mr *MockMongoIChangeStreamMockRecorder) Next(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Next", reflect.TypeOf((*MockMongoIChangeStream)(nil).Next), ctx)
+}
+
+// ResumeToken mocks base method.
+func (m *MockMongoIChangeStream) ResumeToken() bson.Raw {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeToken")
+	ret0, _ := ret[0].(bson.Raw)
+	return ret0
+}
+
+// ResumeToken indicates an expected call of ResumeToken.
+func (mr *MockMongoIChangeStreamMockRecorder) ResumeToken() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeToken", reflect.TypeOf((*MockMongoIChangeStream)(nil).ResumeToken))
+}
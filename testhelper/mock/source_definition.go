@@ -83,3 +83,55 @@ func (mr *MockISourceMockRecorder[Options]) StreamData(arg0, arg1 any) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamData", reflect.TypeOf((*MockISource[Options])(nil).StreamData), arg0, arg1)
 }
+
+// MockIFollowSource is a mock of IFollowSource interface.
+type MockIFollowSource struct {
+	ctrl     *gomock.Controller
+	recorder *MockIFollowSourceMockRecorder
+	isgomock struct{}
+}
+
+// MockIFollowSourceMockRecorder is the mock recorder for MockIFollowSource.
+type MockIFollowSourceMockRecorder struct {
+	mock *MockIFollowSource
+}
+
+// NewMockIFollowSource creates a new mock instance.
+func NewMockIFollowSource(ctrl *gomock.Controller) *MockIFollowSource {
+	mock := &MockIFollowSource{ctrl: ctrl}
+	mock.recorder = &MockIFollowSourceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIFollowSource) EXPECT() *MockIFollowSourceMockRecorder {
+	return m.recorder
+}
+
+// Follow mocks base method.
+func (m *MockIFollowSource) Follow(ctx context.Context, apply func(map[string]any) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Follow", ctx, apply)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Follow indicates an expected call of Follow.
+func (mr *MockIFollowSourceMockRecorder) Follow(ctx, apply any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Follow", reflect.TypeOf((*MockIFollowSource)(nil).Follow), ctx, apply)
+}
+
+// IsFollowing mocks base method.
+func (m *MockIFollowSource) IsFollowing() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsFollowing")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsFollowing indicates an expected call of IsFollowing.
+func (mr *MockIFollowSourceMockRecorder) IsFollowing() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsFollowing", reflect.TypeOf((*MockIFollowSource)(nil).IsFollowing))
+}